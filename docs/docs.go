@@ -25,6 +25,68 @@ const docTemplate = `{
     "basePath": "{{.BasePath}}",
     "paths": {
         "/auth/chats": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Окно истории от новых сообщений к старым. Без границ — самые новые; before_id и after_id — сообщения старше или новее заданного, around_id — вокруг него, включая его самого; cursor — next_cursor или prev_cursor из прошлого ответа. Граница задается только одна. Удаленные сообщения остаются в истории без текста, с deleted_at. В групповых чатах у сообщений есть seen_by — кто их прочитал.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Получение чата",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID чата",
+                        "name": "chat_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество (по умолчанию 50, не больше 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор из next_cursor или prev_cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сообщения старше этого",
+                        "name": "before_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сообщения новее этого",
+                        "name": "after_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сообщения вокруг этого",
+                        "name": "around_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.ChatHistoryResponse"
+                        }
+                    }
+                }
+            },
             "post": {
                 "security": [
                     {
@@ -63,33 +125,59 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/friends": {
+        "/auth/chats/unread": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Сколько чужих сообщений после курсора прочтения в каждом чате пользователя: chat_id -\u003e количество",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "friends"
+                    "chats"
                 ],
-                "summary": "Получение друзей",
-                "parameters": [
+                "summary": "Непрочитанные сообщения по чатам",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/chats/{chatId}/members": {
+            "get": {
+                "security": [
                     {
-                        "type": "string",
-                        "description": "Статус",
-                        "name": "status",
-                        "in": "query",
-                        "required": true
+                        "BearerAuth": []
                     }
                 ],
-                "responses": {}
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Участники комнаты или чата с ролями",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/store.Member"
+                            }
+                        }
+                    }
+                }
             },
             "post": {
                 "security": [
@@ -100,35 +188,29 @@ const docTemplate = `{
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "friends"
+                    "moderation"
                 ],
-                "summary": "Создание друга",
+                "summary": "Добавить участника в комнату или групповой чат",
                 "parameters": [
                     {
-                        "description": "Данные",
-                        "name": "data",
+                        "description": "Пользователь",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/routes.FriendRequest"
+                            "$ref": "#/definitions/routes.AddMemberRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/routes.FriendStatus"
-                        }
+                    "204": {
+                        "description": "No Content"
                     }
                 }
             }
         },
-        "/auth/friends/accepted": {
+        "/auth/chats/{chatId}/members/{userId}/ban": {
             "post": {
                 "security": [
                     {
@@ -138,68 +220,87 @@ const docTemplate = `{
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "friends"
+                    "moderation"
                 ],
-                "summary": "Принять друга",
+                "summary": "Заблокировать участника и отключить его",
                 "parameters": [
                     {
-                        "description": "Принять друга",
-                        "name": "data",
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Длительность и причина",
+                        "name": "request",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/routes.AcceptedFriendRequest"
+                            "$ref": "#/definitions/routes.SanctionRequest"
                         }
                     }
                 ],
-                "responses": {}
-            }
-        },
-        "/auth/profile": {
-            "get": {
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "берется из токена",
                 "tags": [
-                    "profile"
+                    "moderation"
                 ],
-                "summary": "Получить имя, id пользователя",
-                "responses": {}
+                "summary": "Снять блокировку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
             }
         },
-        "/auth/rooms": {
-            "get": {
+        "/auth/chats/{chatId}/members/{userId}/kick": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
                 "tags": [
-                    "rooms"
+                    "moderation"
+                ],
+                "summary": "Исключить участника и отключить его от активной сессии",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Получить все комнаты",
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/routes.Room"
-                            }
-                        }
+                    "204": {
+                        "description": "No Content"
                     }
                 }
-            },
+            }
+        },
+        "/auth/chats/{chatId}/members/{userId}/mute": {
             "post": {
                 "security": [
                     {
@@ -209,202 +310,2271 @@ const docTemplate = `{
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
-                    "rooms"
+                    "moderation"
                 ],
-                "summary": "Создать комнату",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                "summary": "Запретить участнику писать в чат",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Длительность и причина",
+                        "name": "request",
+                        "in": "body",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/routes.Room"
-                            }
+                            "$ref": "#/definitions/routes.SanctionRequest"
                         }
                     }
-                }
-            }
-        },
-        "/users": {
-            "get": {
-                "description": "Получить список всех пользователей",
-                "consumes": [
-                    "application/json"
                 ],
-                "produces": [
-                    "application/json"
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
                 "tags": [
-                    "users"
+                    "moderation"
+                ],
+                "summary": "Снять запрет писать",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Получить всех пользователей",
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/routes.User"
-                            }
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
+                    "204": {
+                        "description": "No Content"
                     }
                 }
             }
         },
-        "/users/login": {
-            "post": {
-                "consumes": [
-                    "application/json"
+        "/auth/chats/{chatId}/members/{userId}/role": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
-                "produces": [
+                "description": "owner назначает moderator, member и guest; moderator — member и guest участникам ниже себя",
+                "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "moderation"
                 ],
-                "summary": "Аутентификация",
+                "summary": "Назначить роль участнику",
                 "parameters": [
                     {
-                        "description": "Данные",
-                        "name": "data",
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новая роль",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/routes.LoginRequest"
+                            "$ref": "#/definitions/routes.RoleRequest"
                         }
                     }
                 ],
-                "responses": {}
-            }
-        },
-        "/users/register": {
-            "post": {
-                "description": "Зарегистрировать нового пользователя",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
                 "tags": [
-                    "users"
+                    "moderation"
                 ],
-                "summary": "Регистрация пользователя",
+                "summary": "Снять роль (участник становится member)",
                 "parameters": [
                     {
-                        "description": "Данные",
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/chats/{chatId}/messages/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Удалять может автор или модератор чата. Сообщение остается в истории отметкой без текста, история правок стирается; подключенные участники получают кадр delete.",
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Удалить сообщение",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID чата",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Править может автор или модератор чата. Прежний текст сохраняется в истории правок, подключенные участники получают кадр edit.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Изменить сообщение",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID чата",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новый текст",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/routes.RegisterRequest"
+                            "$ref": "#/definitions/routes.EditMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/store.Message"
+                        }
+                    },
+                    "413": {
+                        "description": "Текст длиннее предела кадра сокета",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/chats/{chatId}/messages/{id}/revisions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Прежние тексты от старых к новым; доступна участникам чата",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "История правок сообщения",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID чата",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/store.MessageRevision"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/chats/{chatId}/read": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Сдвигает курсор прочтения до message_id (назад не сдвигается). Подключенные участники получают кадр read.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Отметить чат прочитанным",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID чата",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Последнее прочитанное сообщение",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.MarkReadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.ReadStateResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/conversations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Все чаты пользователя от недавно активных к давним: участники с именами, последнее сообщение (текст обрезан до 100 символов) и число непрочитанных",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Список чатов",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Количество (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/store.ChatSummary"
+                            }
                         }
                     }
+                }
+            }
+        },
+        "/auth/friends": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "friends"
+                ],
+                "summary": "Получение друзей",
                 "responses": {}
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "friends"
+                ],
+                "summary": "Создание друга",
+                "parameters": [
+                    {
+                        "description": "Данные",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.FriendRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.FriendStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/friends/accepted": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "friends"
+                ],
+                "summary": "Принять друга",
+                "parameters": [
+                    {
+                        "description": "Принять друга",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.AcceptedFriendRequest"
+                        }
+                    }
+                ],
+                "responses": {}
+            }
+        },
+        "/auth/ice-servers": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Список для RTCConfiguration.iceServers. TURN-учетка временная и привязана к пользователю из токена; ее нужно запросить заново до истечения ttl.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ice"
+                ],
+                "summary": "Получить STUN/TURN-серверы",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.ICEServersResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/invites/{code}/accept": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Принять приглашение и вступить в комнату",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Код приглашения",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.Room"
+                        }
+                    },
+                    "410": {
+                        "description": "Приглашение отозвано, истекло или исчерпано, или комната в архиве",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/logout": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Отзывает текущий access-токен и цепочку refresh-токенов (или все сессии при all=true). Открытые по этому access-токену WebSocket-подключения закрываются с кодом 4401.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Выход",
+                "parameters": [
+                    {
+                        "description": "Refresh-токен текущей сессии",
+                        "name": "data",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/routes.LogoutRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/presence": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Статус (online, away, dnd, offline) и время последнего визита. ID передаются повторяющимся параметром или через запятую, не больше 100. Видно присутствие только свое, друзей и тех, с кем есть общий чат; остальные ID, как и несуществующие, пропускаются.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "presence"
+                ],
+                "summary": "Получить присутствие пользователей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователей",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/presence.Presence"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Ручной статус действует, пока пользователь в сети; без подключений он offline. Друзья получают изменение через сокет присутствия.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "presence"
+                ],
+                "summary": "Сменить свой статус",
+                "parameters": [
+                    {
+                        "description": "Статус",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.SetPresenceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/presence.Presence"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/profile": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "берется из токена",
+                "tags": [
+                    "profile"
+                ],
+                "summary": "Получить имя, id пользователя",
+                "responses": {}
+            }
+        },
+        "/auth/rooms": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Комнаты, в которых состоит пользователь",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/routes.Room"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создатель становится владельцем комнаты",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Создать комнату",
+                "parameters": [
+                    {
+                        "description": "Название и видимость",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.CreateRoomRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.Room"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/rooms/joinable": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Публичные комнаты, в которые можно войти",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Количество (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/routes.Room"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Доступно участникам; публичную комнату видят все",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Получить комнату",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.RoomResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Удаляет комнату с участниками, приглашениями и записями этого узла; только владелец. Активная сессия закрывается.",
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Удалить комнату",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Переименование, видимость, лимит участников, лобби, режим медиа и архивация; только владелец. Архивация отключает всех участников. Новый режим медиа действует со следующей сессии комнаты.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Изменить комнату",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменения",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.UpdateRoomRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.Room"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/invites": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Приглашения комнаты",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/store.RoomInvite"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Создать ссылку-приглашение",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Ограничения приглашения",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/routes.CreateInviteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/store.RoomInvite"
+                        }
+                    },
+                    "409": {
+                        "description": "Комната в архиве",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/invites/{code}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Отозвать может автор приглашения или модератор комнаты",
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Отозвать приглашение",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Код приглашения",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/join": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rooms"
+                ],
+                "summary": "Вступить в публичную комнату",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.Room"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/members": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Участники комнаты или чата с ролями",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/store.Member"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Добавить участника в комнату или групповой чат",
+                "parameters": [
+                    {
+                        "description": "Пользователь",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.AddMemberRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/members/{userId}/ban": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Заблокировать участника и отключить его",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Длительность и причина",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/routes.SanctionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Снять блокировку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/members/{userId}/kick": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Исключить участника и отключить его от активной сессии",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/members/{userId}/mute": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Запретить участнику писать в чат",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Длительность и причина",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/routes.SanctionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Снять запрет писать",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/members/{userId}/role": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "owner назначает moderator, member и guest; moderator — member и guest участникам ниже себя",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Назначить роль участнику",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новая роль",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.RoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "tags": [
+                    "moderation"
+                ],
+                "summary": "Снять роль (участник становится member)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/recording": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Только владелец. Комната должна быть открыта: запись идет, пока в ней есть участники, и останавливается вместе с сессией. Участникам рассылается индикатор recording.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "recordings"
+                ],
+                "summary": "Начать запись комнаты",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.RecordingResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Сессия не идет или запись уже включена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Только владелец. Файлы записи дописываются и становятся доступны для скачивания.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "recordings"
+                ],
+                "summary": "Остановить запись комнаты",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.RecordingResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Запись не идет",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/recordings": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Только владелец; новые первыми",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "recordings"
+                ],
+                "summary": "Записи комнаты",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/routes.RecordingManifest"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/recordings/{recordingId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Манифест записи: дорожки участников и их файлы",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "recordings"
+                ],
+                "summary": "Запись комнаты",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID записи",
+                        "name": "recordingId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.RecordingManifest"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Только владелец; идущую запись сначала нужно остановить",
+                "tags": [
+                    "recordings"
+                ],
+                "summary": "Удалить запись",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID записи",
+                        "name": "recordingId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "409": {
+                        "description": "Запись еще идет",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/rooms/{roomId}/recordings/{recordingId}/files/{file}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Файл дорожки из манифеста (ogg, ivf, h264) или manifest.json",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "recordings"
+                ],
+                "summary": "Скачать файл записи",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID комнаты",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID записи",
+                        "name": "recordingId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Имя файла",
+                        "name": "file",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/protocol": {
+            "get": {
+                "description": "Версия выбирается параметром v при подключении к /auth/ws/{roomId}; без него — версия 1",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "protocol"
+                ],
+                "summary": "Версии протокола сигнализации",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.ProtocolResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/protocol/schema/{name}": {
+            "get": {
+                "description": "Схемы кадров версии 2: client — кадры клиента, server — кадры сервера",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "protocol"
+                ],
+                "summary": "JSON Schema протокола сигнализации",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "client или server",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "404": {
+                        "description": "Неизвестная схема",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "description": "Получить список всех пользователей",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить всех пользователей",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/routes.User"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users/login": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Аутентификация",
+                "parameters": [
+                    {
+                        "description": "Данные",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {}
+            }
+        },
+        "/users/refresh": {
+            "post": {
+                "description": "Обменять refresh-токен на новую пару токенов. Старый refresh-токен становится недействительным",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Обновление токенов",
+                "parameters": [
+                    {
+                        "description": "Refresh-токен",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.AuthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/register": {
+            "post": {
+                "description": "Зарегистрировать нового пользователя",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Регистрация пользователя",
+                "parameters": [
+                    {
+                        "description": "Данные",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/routes.RegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {}
+            }
+        },
+        "/users/{name}": {
+            "get": {
+                "description": "Получить данные конкретного пользователя",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить пользователя по имени",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Имя пользователя",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.UserProfile"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "ice.Server": {
+            "type": "object",
+            "properties": {
+                "credential": {
+                    "type": "string"
+                },
+                "urls": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "presence.Presence": {
+            "type": "object",
+            "properties": {
+                "last_seen": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/store.PresenceStatus"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "recording.Track": {
+            "type": "object",
+            "properties": {
+                "codec": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "stopped_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.AcceptedFriendRequest": {
+            "type": "object",
+            "properties": {
+                "friend_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.AddMemberRequest": {
+            "type": "object",
+            "properties": {
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.AuthResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "user": {
+                    "$ref": "#/definitions/routes.User"
+                }
+            }
+        },
+        "routes.ChatHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "chat_id": {
+                    "type": "string"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/routes.Message"
+                    }
+                },
+                "next_cursor": {
+                    "description": "NextCursor ведет к более старым сообщениям, PrevCursor — к более новым;\nпустой — в эту сторону сообщений нет. Курсор в сторону границы запроса\nвыдается без проверки и может привести к пустому окну.",
+                    "type": "string"
+                },
+                "prev_cursor": {
+                    "type": "string"
+                },
+                "read_cursors": {
+                    "description": "ReadCursors — ID последнего прочитанного сообщения каждого участника",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "total_messages": {
+                    "description": "Total — всего сообщений в чате, включая удаленные",
+                    "type": "integer"
+                },
+                "unread": {
+                    "type": "integer"
+                }
+            }
+        },
+        "routes.CreateChatRequest": {
+            "type": "object",
+            "properties": {
+                "friend_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type_chat": {
+                    "$ref": "#/definitions/routes.TypeChat"
+                }
+            }
+        },
+        "routes.CreateChatResponse": {
+            "type": "object",
+            "properties": {
+                "chat_id": {
+                    "type": "string"
+                },
+                "created": {
+                    "type": "boolean"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.CreateInviteRequest": {
+            "type": "object",
+            "properties": {
+                "expires_in": {
+                    "description": "Срок действия в секундах; 0 — бессрочно",
+                    "type": "integer"
+                },
+                "max_uses": {
+                    "description": "0 — без ограничения",
+                    "type": "integer"
+                }
+            }
+        },
+        "routes.CreateRoomRequest": {
+            "type": "object",
+            "properties": {
+                "lobby": {
+                    "description": "Новые участники ждут допуска от владельца или модератора",
+                    "type": "boolean"
+                },
+                "max_participants": {
+                    "description": "Лимит участников сессии; 0 — общий лимит сервера",
+                    "type": "integer"
+                },
+                "media_mode": {
+                    "description": "mesh (по умолчанию) или sfu — медиа через сервер, если он включен",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/store.MediaMode"
+                        }
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                },
+                "visibility": {
+                    "description": "public (по умолчанию), invite_only или private",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/store.RoomVisibility"
+                        }
+                    ]
+                }
+            }
+        },
+        "routes.EditMessageRequest": {
+            "type": "object",
+            "properties": {
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.FriendProfile": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.FriendRequest": {
+            "type": "object",
+            "properties": {
+                "friend_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.FriendStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "accepted"
+            ],
+            "x-enum-varnames": [
+                "FriendStatusPending",
+                "FriendStatusAccepted"
+            ]
+        },
+        "routes.ICEServersResponse": {
+            "type": "object",
+            "properties": {
+                "iceServers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ice.Server"
+                    }
+                },
+                "ttl": {
+                    "description": "TTL — сколько секунд действует TURN-учетка",
+                    "type": "integer"
+                }
+            }
+        },
+        "routes.LoginRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.LogoutRequest": {
+            "type": "object",
+            "properties": {
+                "all": {
+                    "description": "All — выйти на всех устройствах",
+                    "type": "boolean"
+                },
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.MarkReadRequest": {
+            "type": "object",
+            "properties": {
+                "message_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "routes.Message": {
+            "type": "object",
+            "properties": {
+                "chat_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt — сообщение удалено; текст стерт, остается только отметка",
+                    "type": "string"
+                },
+                "edited_at": {
+                    "description": "EditedAt — время последней правки",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "is_read": {
+                    "description": "IsRead — сообщение прочитал кто-то из участников, кроме автора",
+                    "type": "boolean"
+                },
+                "message_text": {
+                    "type": "string"
+                },
+                "message_type": {
+                    "type": "string"
+                },
+                "seen_by": {
+                    "description": "SeenBy — кто из участников, кроме автора, прочитал сообщение; заполняется\nтолько в истории групповых чатов",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "sender_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "routes.ProtocolResponse": {
+            "type": "object",
+            "properties": {
+                "latest": {
+                    "type": "integer"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
             }
         },
-        "/users/{name}": {
-            "get": {
-                "description": "Получить данные конкретного пользователя",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "users"
-                ],
-                "summary": "Получить пользователя по имени",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Имя пользователя",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+        "routes.ReadStateResponse": {
+            "type": "object",
+            "properties": {
+                "chat_id": {
+                    "type": "string"
+                },
+                "last_read_message_id": {
+                    "type": "integer"
+                },
+                "unread": {
+                    "type": "integer"
+                }
+            }
+        },
+        "routes.RecordingManifest": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "started_by": {
+                    "type": "string"
+                },
+                "stopped_at": {
+                    "type": "string"
+                },
+                "tracks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/recording.Track"
                     }
-                ],
-                "responses": {}
+                }
             }
-        }
-    },
-    "definitions": {
-        "routes.AcceptedFriendRequest": {
+        },
+        "routes.RecordingResponse": {
             "type": "object",
             "properties": {
-                "friend_id": {
+                "id": {
                     "type": "string"
                 }
             }
         },
-        "routes.CreateChatRequest": {
+        "routes.RefreshRequest": {
             "type": "object",
             "properties": {
-                "friend_id": {
+                "refresh_token": {
                     "type": "string"
-                },
+                }
+            }
+        },
+        "routes.RegisterRequest": {
+            "type": "object",
+            "properties": {
                 "name": {
                     "type": "string"
                 },
-                "type_chat": {
-                    "$ref": "#/definitions/routes.TypeChat"
+                "password": {
+                    "type": "string"
                 }
             }
         },
-        "routes.CreateChatResponse": {
+        "routes.RoleRequest": {
             "type": "object",
             "properties": {
-                "chat_id": {
+                "role": {
+                    "$ref": "#/definitions/store.Role"
+                }
+            }
+        },
+        "routes.Room": {
+            "type": "object",
+            "properties": {
+                "archived_at": {
                     "type": "string"
                 },
-                "created": {
+                "created_by": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lobby": {
+                    "description": "Lobby — новые участники ждут допуска от владельца или модератора",
                     "type": "boolean"
                 },
-                "message": {
+                "max_participants": {
+                    "description": "MaxParticipants — лимит участников сессии; 0 — общий лимит сервера",
+                    "type": "integer"
+                },
+                "media_mode": {
+                    "description": "MediaMode применяется к следующей сессии комнаты",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/store.MediaMode"
+                        }
+                    ]
+                },
+                "name": {
                     "type": "string"
+                },
+                "visibility": {
+                    "$ref": "#/definitions/store.RoomVisibility"
                 }
             }
         },
-        "routes.FriendRequest": {
+        "routes.RoomResponse": {
             "type": "object",
             "properties": {
-                "friend_id": {
+                "archived_at": {
                     "type": "string"
                 },
-                "user_id": {
+                "created_by": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lobby": {
+                    "description": "Lobby — новые участники ждут допуска от владельца или модератора",
+                    "type": "boolean"
+                },
+                "max_participants": {
+                    "description": "MaxParticipants — лимит участников сессии; 0 — общий лимит сервера",
+                    "type": "integer"
+                },
+                "media_mode": {
+                    "description": "MediaMode применяется к следующей сессии комнаты",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/store.MediaMode"
+                        }
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                },
+                "role": {
+                    "$ref": "#/definitions/store.Role"
+                },
+                "visibility": {
+                    "$ref": "#/definitions/store.RoomVisibility"
+                }
+            }
+        },
+        "routes.SanctionRequest": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "description": "Длительность в секундах; 0 — бессрочно",
+                    "type": "integer"
+                },
+                "reason": {
                     "type": "string"
                 }
             }
         },
-        "routes.FriendStatus": {
+        "routes.SetPresenceRequest": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "$ref": "#/definitions/store.PresenceStatus"
+                }
+            }
+        },
+        "routes.TypeChat": {
             "type": "string",
             "enum": [
-                "pending",
-                "accepted"
+                "private",
+                "group"
             ],
             "x-enum-varnames": [
-                "StatusPending",
-                "StatusAccepted"
+                "ChatTypePrivate",
+                "ChatTypeGroup"
             ]
         },
-        "routes.LoginRequest": {
+        "routes.UpdateRoomRequest": {
+            "type": "object",
+            "properties": {
+                "archived": {
+                    "type": "boolean"
+                },
+                "lobby": {
+                    "type": "boolean"
+                },
+                "max_participants": {
+                    "type": "integer"
+                },
+                "media_mode": {
+                    "$ref": "#/definitions/store.MediaMode"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "visibility": {
+                    "$ref": "#/definitions/store.RoomVisibility"
+                }
+            }
+        },
+        "routes.User": {
             "type": "object",
             "properties": {
+                "id": {
+                    "description": "Пользователь",
+                    "type": "string"
+                },
+                "is_friend": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
@@ -413,60 +2583,241 @@ const docTemplate = `{
                 }
             }
         },
-        "routes.RegisterRequest": {
+        "routes.UserProfile": {
             "type": "object",
             "properties": {
-                "name": {
+                "avatar": {
                     "type": "string"
                 },
-                "password": {
+                "friends": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/routes.FriendProfile"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
                     "type": "string"
                 }
             }
         },
-        "routes.Room": {
+        "store.ChatParticipant": {
             "type": "object",
             "properties": {
-                "created_by": {
+                "name": {
                     "type": "string"
                 },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "store.ChatSummary": {
+            "type": "object",
+            "properties": {
                 "id": {
-                    "description": "Комната",
                     "type": "string"
                 },
+                "last_activity_at": {
+                    "description": "LastActivityAt — время последнего сообщения, а в пустом чате — вступления пользователя",
+                    "type": "string"
+                },
+                "last_message": {
+                    "description": "LastMessage — последнее сообщение чата, удаленное — без текста; nil — сообщений нет",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/store.Message"
+                        }
+                    ]
+                },
                 "name": {
                     "type": "string"
+                },
+                "participants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.ChatParticipant"
+                    }
+                },
+                "type": {
+                    "$ref": "#/definitions/store.ChatType"
+                },
+                "unread": {
+                    "type": "integer"
                 }
             }
         },
-        "routes.TypeChat": {
+        "store.ChatType": {
             "type": "string",
             "enum": [
                 "private",
                 "group"
             ],
             "x-enum-varnames": [
-                "TypeChatPrivate",
-                "TypeChatGroup"
+                "ChatTypePrivate",
+                "ChatTypeGroup"
             ]
         },
-        "routes.User": {
+        "store.MediaMode": {
+            "type": "string",
+            "enum": [
+                "mesh",
+                "sfu"
+            ],
+            "x-enum-varnames": [
+                "MediaMesh",
+                "MediaSFU"
+            ]
+        },
+        "store.Member": {
+            "type": "object",
+            "properties": {
+                "joined_at": {
+                    "type": "string"
+                },
+                "role": {
+                    "$ref": "#/definitions/store.Role"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "store.Message": {
+            "type": "object",
+            "properties": {
+                "chat_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt — сообщение удалено; текст стерт, остается только отметка",
+                    "type": "string"
+                },
+                "edited_at": {
+                    "description": "EditedAt — время последней правки",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "is_read": {
+                    "description": "IsRead — сообщение прочитал кто-то из участников, кроме автора",
+                    "type": "boolean"
+                },
+                "message_text": {
+                    "type": "string"
+                },
+                "message_type": {
+                    "type": "string"
+                },
+                "seen_by": {
+                    "description": "SeenBy — кто из участников, кроме автора, прочитал сообщение; заполняется\nтолько в истории групповых чатов",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "sender_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "store.MessageRevision": {
             "type": "object",
             "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "edited_by": {
+                    "type": "string"
+                },
                 "id": {
-                    "description": "Пользователь",
+                    "type": "integer"
+                },
+                "message_id": {
+                    "type": "integer"
+                },
+                "message_text": {
+                    "type": "string"
+                }
+            }
+        },
+        "store.PresenceStatus": {
+            "type": "string",
+            "enum": [
+                "online",
+                "away",
+                "dnd",
+                "offline"
+            ],
+            "x-enum-varnames": [
+                "PresenceOnline",
+                "PresenceAway",
+                "PresenceDND",
+                "PresenceOffline"
+            ]
+        },
+        "store.Role": {
+            "type": "string",
+            "enum": [
+                "owner",
+                "moderator",
+                "member",
+                "guest"
+            ],
+            "x-enum-varnames": [
+                "RoleOwner",
+                "RoleModerator",
+                "RoleMember",
+                "RoleGuest"
+            ]
+        },
+        "store.RoomInvite": {
+            "type": "object",
+            "properties": {
+                "code": {
                     "type": "string"
                 },
-                "is_friend": {
+                "created_at": {
                     "type": "string"
                 },
-                "name": {
+                "created_by": {
                     "type": "string"
                 },
-                "password": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "max_uses": {
+                    "type": "integer"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "room_id": {
                     "type": "string"
+                },
+                "uses": {
+                    "type": "integer"
                 }
             }
+        },
+        "store.RoomVisibility": {
+            "type": "string",
+            "enum": [
+                "public",
+                "invite_only",
+                "private"
+            ],
+            "x-enum-varnames": [
+                "RoomPublic",
+                "RoomInviteOnly",
+                "RoomPrivate"
+            ]
         }
     },
     "securityDefinitions": {
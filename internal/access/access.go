@@ -0,0 +1,159 @@
+// Package access — роли и права участников комнат и чатов
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"server/internal/auth"
+	"server/internal/store"
+	"time"
+)
+
+type Permission string
+
+const (
+	// PermJoin — подключаться к сокету и читать историю
+	PermJoin Permission = "join"
+	// PermSendMessage — писать в чат
+	PermSendMessage Permission = "send_message"
+	// PermInvite — добавлять участников
+	PermInvite Permission = "invite"
+	// PermModerate — mute, kick и ban участников с ролью ниже своей
+	PermModerate Permission = "moderate"
+	// PermManageRoles — выдавать и снимать роли ниже своей
+	PermManageRoles Permission = "manage_roles"
+	// PermManage — менять настройки и удалять ресурс
+	PermManage Permission = "manage"
+)
+
+var permissions = map[store.Role][]Permission{
+	store.RoleOwner:     {PermJoin, PermSendMessage, PermInvite, PermModerate, PermManageRoles, PermManage},
+	store.RoleModerator: {PermJoin, PermSendMessage, PermInvite, PermModerate, PermManageRoles},
+	store.RoleMember:    {PermJoin, PermSendMessage, PermInvite},
+	store.RoleGuest:     {PermJoin},
+}
+
+var ranks = map[store.Role]int{
+	store.RoleGuest:     1,
+	store.RoleMember:    2,
+	store.RoleModerator: 3,
+	store.RoleOwner:     4,
+}
+
+// Can сообщает, есть ли у роли право
+func Can(role store.Role, p Permission) bool {
+	for _, granted := range permissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidRole — одна из известных ролей
+func ValidRole(role store.Role) bool {
+	_, ok := ranks[role]
+	return ok
+}
+
+// Outranks сообщает, что роль a строго выше роли b
+func Outranks(a, b store.Role) bool {
+	return ranks[a] > ranks[b]
+}
+
+// CanAssign — может ли actor сменить роль участника с current на next.
+// Роль owner через назначение не передается.
+func CanAssign(actor, current, next store.Role) bool {
+	return next != store.RoleOwner &&
+		Can(actor, PermManageRoles) &&
+		Outranks(actor, current) &&
+		Outranks(actor, next)
+}
+
+// CanModerate — может ли actor применять санкции к участнику с ролью target
+func CanModerate(actor, target store.Role) bool {
+	return Can(actor, PermModerate) && Outranks(actor, target)
+}
+
+// Access — итоговые права пользователя на конкретную комнату или чат
+type Access struct {
+	Role   store.Role
+	Member bool
+	Muted  bool
+	Banned bool
+}
+
+// Can учитывает роль и действующие санкции
+func (a Access) Can(p Permission) bool {
+	if a.Banned {
+		return false
+	}
+	if a.Muted && p == PermSendMessage {
+		return false
+	}
+	return Can(a.Role, p)
+}
+
+// Checker вычисляет права по участникам и санкциям из хранилища
+type Checker struct {
+	members   store.MemberStore
+	sanctions store.SanctionStore
+}
+
+func NewChecker(members store.MemberStore, sanctions store.SanctionStore) *Checker {
+	return &Checker{
+		members:   members,
+		sanctions: sanctions,
+	}
+}
+
+// Resolve возвращает права пользователя. У того, кто не состоит в комнате
+// или чате, роли нет и права отсутствуют.
+func (c *Checker) Resolve(ctx context.Context, scope store.Scope, resourceID, userID string) (Access, error) {
+	var a Access
+
+	member, err := c.members.Get(ctx, scope, resourceID, userID)
+	switch {
+	case err == nil:
+		a.Role = member.Role
+		a.Member = true
+	case !errors.Is(err, store.ErrNotFound):
+		return Access{}, err
+	}
+
+	sanctions, err := c.sanctions.Active(ctx, scope, resourceID, userID, time.Now())
+	if err != nil {
+		return Access{}, err
+	}
+	for _, sn := range sanctions {
+		switch sn.Kind {
+		case store.SanctionMute:
+			a.Muted = true
+		case store.SanctionBan:
+			a.Banned = true
+		}
+	}
+
+	return a, nil
+}
+
+// Require возвращает права пользователя или ошибку, оборачивающую auth.ErrForbidden
+func (c *Checker) Require(ctx context.Context, scope store.Scope, resourceID, userID string, p Permission) (Access, error) {
+	a, err := c.Resolve(ctx, scope, resourceID, userID)
+	if err != nil {
+		return Access{}, err
+	}
+
+	switch {
+	case a.Banned:
+		return a, fmt.Errorf("%w: banned from this %s", auth.ErrForbidden, scope)
+	case a.Muted && p == PermSendMessage:
+		return a, fmt.Errorf("%w: muted in this %s", auth.ErrForbidden, scope)
+	case !a.Member:
+		return a, fmt.Errorf("%w: not a %s member", auth.ErrForbidden, scope)
+	case !a.Can(p):
+		return a, fmt.Errorf("%w: %s permission required", auth.ErrForbidden, p)
+	}
+	return a, nil
+}
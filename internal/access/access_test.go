@@ -0,0 +1,107 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"server/internal/auth"
+	"server/internal/store"
+	"server/internal/store/memory"
+)
+
+func TestCanAssign(t *testing.T) {
+	tests := []struct {
+		actor, current, next store.Role
+		want                 bool
+	}{
+		{store.RoleOwner, store.RoleMember, store.RoleModerator, true},
+		{store.RoleOwner, store.RoleModerator, store.RoleGuest, true},
+		{store.RoleOwner, store.RoleMember, store.RoleOwner, false},
+		{store.RoleModerator, store.RoleMember, store.RoleGuest, true},
+		{store.RoleModerator, store.RoleMember, store.RoleModerator, false},
+		{store.RoleModerator, store.RoleModerator, store.RoleMember, false},
+		{store.RoleMember, store.RoleGuest, store.RoleGuest, false},
+	}
+	for _, tt := range tests {
+		if got := CanAssign(tt.actor, tt.current, tt.next); got != tt.want {
+			t.Errorf("CanAssign(%s, %s, %s) = %v, want %v", tt.actor, tt.current, tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestCanModerate(t *testing.T) {
+	tests := []struct {
+		actor, target store.Role
+		want          bool
+	}{
+		{store.RoleOwner, store.RoleModerator, true},
+		{store.RoleModerator, store.RoleMember, true},
+		{store.RoleModerator, store.RoleGuest, true},
+		{store.RoleModerator, store.RoleModerator, false},
+		{store.RoleModerator, store.RoleOwner, false},
+		{store.RoleMember, store.RoleGuest, false},
+	}
+	for _, tt := range tests {
+		if got := CanModerate(tt.actor, tt.target); got != tt.want {
+			t.Errorf("CanModerate(%s, %s) = %v, want %v", tt.actor, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	checker := NewChecker(st.Members, st.Sanctions)
+
+	for userID, role := range map[string]store.Role{"owner": store.RoleOwner, "member": store.RoleMember, "muted": store.RoleMember, "banned": store.RoleModerator} {
+		for _, scope := range []store.Scope{store.ScopeRoom, store.ScopeChat} {
+			if err := st.Members.Add(ctx, scope, "r", userID, role); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expired := time.Now().Add(-time.Minute)
+	sanctions := []store.Sanction{
+		{UserID: "muted", Kind: store.SanctionMute},
+		{UserID: "banned", Kind: store.SanctionBan},
+		{UserID: "member", Kind: store.SanctionBan, ExpiresAt: &expired},
+	}
+	for _, sn := range sanctions {
+		for _, scope := range []store.Scope{store.ScopeRoom, store.ScopeChat} {
+			sn.Scope, sn.ResourceID, sn.IssuedBy = scope, "r", "owner"
+			if err := st.Sanctions.Put(ctx, sn); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		scope  store.Scope
+		userID string
+		perm   Permission
+		allow  bool
+	}{
+		{"owner manages", store.ScopeRoom, "owner", PermManage, true},
+		{"member cannot moderate", store.ScopeChat, "member", PermModerate, false},
+		{"expired ban is ignored", store.ScopeChat, "member", PermSendMessage, true},
+		{"stranger cannot join a room", store.ScopeRoom, "stranger", PermJoin, false},
+		{"stranger cannot join a chat", store.ScopeChat, "stranger", PermJoin, false},
+		{"muted still joins", store.ScopeChat, "muted", PermJoin, true},
+		{"muted cannot write", store.ScopeChat, "muted", PermSendMessage, false},
+		{"banned cannot join", store.ScopeRoom, "banned", PermJoin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checker.Require(ctx, tt.scope, "r", tt.userID, tt.perm)
+			if tt.allow && err != nil {
+				t.Errorf("Require = %v, want access", err)
+			}
+			if !tt.allow && !errors.Is(err, auth.ErrForbidden) {
+				t.Errorf("Require = %v, want ErrForbidden", err)
+			}
+		})
+	}
+}
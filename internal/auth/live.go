@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"log"
+	"server/internal/backplane"
+	"time"
+)
+
+// revokeTopic — тема шины, по которой узлы узнают об отозванных access-токенах;
+// данные — jti токена
+const revokeTopic = "auth:revoke"
+
+// liveToken — сокеты, открытые по одному access-токену. Закрытые сокеты
+// остаются в наборе до истечения токена: после этого его уже нельзя отозвать.
+type liveToken struct {
+	expires time.Time
+	conns   map[*websocket.Conn]struct{}
+}
+
+// track запоминает сокет, открытый по токену claims, и забывает истекшие токены
+func (a *WSAuthenticator) track(claims *Claims, conn *websocket.Conn) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for jti, live := range a.live {
+		if now.After(live.expires) {
+			delete(a.live, jti)
+		}
+	}
+
+	live, ok := a.live[claims.Id]
+	if !ok {
+		live = &liveToken{expires: time.Unix(claims.ExpiresAt, 0), conns: make(map[*websocket.Conn]struct{})}
+		a.live[claims.Id] = live
+	}
+	live.conns[conn] = struct{}{}
+}
+
+// Listen подписывает аутентификатор на отзывы токенов с других узлов
+func (a *WSAuthenticator) Listen(bus backplane.Backplane) error {
+	if _, err := bus.Subscribe(revokeTopic, func(msg backplane.Message) {
+		a.closeToken(string(msg.Data))
+	}); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.bus = bus
+	a.mu.Unlock()
+	return nil
+}
+
+// Disconnect закрывает на всех узлах сокеты, открытые по отозванному токену jti,
+// с кодом 4401: клиенту нужно войти заново
+func (a *WSAuthenticator) Disconnect(jti string) {
+	a.closeToken(jti)
+
+	a.mu.Lock()
+	bus := a.bus
+	a.mu.Unlock()
+	if bus == nil {
+		return
+	}
+	if err := bus.Publish(context.Background(), revokeTopic, []byte(jti)); err != nil {
+		log.Printf("Error publishing revoked token: %v", err)
+	}
+}
+
+// closeToken закрывает сокеты токена jti на этом узле
+func (a *WSAuthenticator) closeToken(jti string) {
+	a.mu.Lock()
+	live, ok := a.live[jti]
+	delete(a.live, jti)
+	a.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	for conn := range live.conns {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(CloseUnauthorized, "token revoked"),
+			time.Now().Add(time.Second))
+		conn.Close()
+	}
+}
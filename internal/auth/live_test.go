@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/store/memory"
+)
+
+func TestDisconnectOnOtherNode(t *testing.T) {
+	st := memory.New()
+	manager := NewManager(config.JWTConfig{
+		Secret:          "test-secret-test-secret-test-secret-1234",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}, st.Tokens, st.Users)
+	bus := backplane.NewMemoryBus()
+	local, remote := bus.Node("a"), bus.Node("b")
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+
+	// Сокеты открыты на узле b, выход — на узле a
+	nodes := make([]*WSAuthenticator, 2)
+	for i, node := range []backplane.Backplane{local, remote} {
+		nodes[i] = NewWSAuthenticator(manager, config.WebSocketConfig{AuthTimeout: time.Second}, nil)
+		if err := nodes[i].Listen(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := nodes[1].Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "?token="
+	revoked := issue(t, manager, "1")
+	kept := issue(t, manager, "1")
+	claims, err := manager.Parse(context.Background(), revoked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dial := func(token string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(url+token, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	closed, open := dial(revoked), dial(kept)
+
+	nodes[0].Disconnect(claims.Id)
+
+	closed.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var closeErr *websocket.CloseError
+	if _, _, err := closed.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != CloseUnauthorized {
+		t.Fatalf("revoked socket: err = %v, want close code %d", err, CloseUnauthorized)
+	}
+
+	open.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var netErr net.Error
+	if _, _, err := open.ReadMessage(); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("socket of another token: err = %v, want read timeout", err)
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type claimsKey struct{}
+
+// ClaimsFromContext возвращает claims, положенные в контекст Middleware
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// Middleware пропускает только запросы с действительным и не отозванным токеном
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+			return
+		}
+
+		// Формат заголовка: "Bearer {token}"
+		if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
+			http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := m.Parse(r.Context(), authHeader[7:])
+		if err != nil {
+			if errors.Is(err, ErrRevokedToken) {
+				http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+				return
+			}
+			if errors.Is(err, ErrInvalidToken) {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			log.Printf("AuthMiddleware: error checking token: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Добавление ID пользователя в контекст запроса
+		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
+		ctx = context.WithValue(ctx, claimsKey{}, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"log"
+	"server/internal/config"
+	"server/internal/store"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrRevokedToken = errors.New("token has been revoked")
+	// ErrRefreshReused — refresh-токен предъявлен повторно; вся цепочка отозвана
+	ErrRefreshReused = errors.New("refresh token reuse detected")
+)
+
+// Claims — содержимое access-токена
+type Claims struct {
+	UserID   string `json:"user_id"`
+	UserName string `json:"user_name"`
+	jwt.StandardClaims
+}
+
+// TokenPair — то, что получает клиент при входе и обновлении токенов
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Manager выпускает, проверяет и отзывает токены
+type Manager struct {
+	cfg    config.JWTConfig
+	tokens store.TokenStore
+	users  store.UserStore
+}
+
+func NewManager(cfg config.JWTConfig, tokens store.TokenStore, users store.UserStore) *Manager {
+	return &Manager{
+		cfg:    cfg,
+		tokens: tokens,
+		users:  users,
+	}
+}
+
+// Issue выпускает новую пару токенов и начинает новую цепочку refresh-токенов
+func (m *Manager) Issue(ctx context.Context, userID, userName string) (TokenPair, error) {
+	familyID, err := randomID(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return m.issue(ctx, userID, userName, familyID)
+}
+
+// Refresh меняет refresh-токен на новую пару. Старый токен отзывается;
+// повторное предъявление отозванного токена отзывает всю цепочку.
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (TokenPair, store.User, error) {
+	hash := hashToken(refreshToken)
+
+	rt, err := m.tokens.GetRefresh(ctx, hash)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return TokenPair{}, store.User{}, ErrInvalidToken
+		}
+		return TokenPair{}, store.User{}, err
+	}
+
+	if time.Now().After(rt.ExpiresAt) {
+		return TokenPair{}, store.User{}, ErrInvalidToken
+	}
+
+	rotated, err := m.tokens.RevokeRefresh(ctx, hash)
+	if err != nil {
+		return TokenPair{}, store.User{}, err
+	}
+	if !rotated {
+		log.Printf("Refresh token reuse for user %s, revoking family %s", rt.UserID, rt.FamilyID)
+		if err := m.tokens.RevokeFamily(ctx, rt.FamilyID); err != nil {
+			return TokenPair{}, store.User{}, err
+		}
+		return TokenPair{}, store.User{}, ErrRefreshReused
+	}
+
+	user, err := m.users.GetByID(ctx, rt.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return TokenPair{}, store.User{}, ErrInvalidToken
+		}
+		return TokenPair{}, store.User{}, err
+	}
+
+	pair, err := m.issue(ctx, user.ID, user.Name, rt.FamilyID)
+	return pair, user, err
+}
+
+// Parse проверяет подпись, срок действия и отзыв access-токена
+func (m *Manager) Parse(ctx context.Context, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Проверяем, что алгоритм подписи соответствует ожидаемому
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(m.cfg.Secret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.UserID == "" || claims.Id == "" {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := m.tokens.IsAccessRevoked(ctx, claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+
+	return claims, nil
+}
+
+// Revoke отзывает access-токен до конца срока его действия
+func (m *Manager) Revoke(ctx context.Context, claims *Claims) error {
+	return m.tokens.RevokeAccess(ctx, claims.Id, claims.UserID, time.Unix(claims.ExpiresAt, 0))
+}
+
+// RevokeRefresh отзывает цепочку, к которой относится refresh-токен.
+// Токены чужих пользователей игнорируются.
+func (m *Manager) RevokeRefresh(ctx context.Context, userID, refreshToken string) error {
+	rt, err := m.tokens.GetRefresh(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if rt.UserID != userID {
+		return nil
+	}
+	return m.tokens.RevokeFamily(ctx, rt.FamilyID)
+}
+
+// RevokeAll отзывает все refresh-токены пользователя (выход на всех устройствах)
+func (m *Manager) RevokeAll(ctx context.Context, userID string) error {
+	return m.tokens.RevokeAllRefresh(ctx, userID)
+}
+
+// RunCleanup периодически удаляет истекшие записи, пока ctx не отменен
+func (m *Manager) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := m.tokens.DeleteExpired(ctx, now); err != nil {
+				log.Printf("Error deleting expired tokens: %v", err)
+			}
+		}
+	}
+}
+
+func (m *Manager) issue(ctx context.Context, userID, userName, familyID string) (TokenPair, error) {
+	now := time.Now()
+
+	jti, err := randomID(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	expiresAt := now.Add(m.cfg.AccessTokenTTL)
+	claims := Claims{
+		UserID:   userID,
+		UserName: userName,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(m.cfg.Secret))
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return TokenPair{}, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	err = m.tokens.CreateRefresh(ctx, store.RefreshToken{
+		Hash:      hashToken(refreshToken),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(m.cfg.RefreshTokenTTL),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomID(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/store"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// BearerProtocol — подпротокол, в паре с которым браузер передает токен:
+	// new WebSocket(url, ["bearer", token])
+	BearerProtocol = "bearer"
+
+	// Коды закрытия для отказов, которые можно отправить только после апгрейда
+	CloseUnauthorized = 4401
+	CloseForbidden    = 4403
+	CloseNotFound     = 4404
+
+	// Максимальный размер первого кадра с токеном
+	authFrameLimit = 8192
+)
+
+var (
+	ErrMissingToken = errors.New("token required")
+	ErrForbidden    = errors.New("access denied")
+	// ErrOriginNotAllowed — источник запроса не разрешен политикой
+	ErrOriginNotAllowed = errors.New("origin not allowed")
+)
+
+// Identity — аутентифицированный пользователь WebSocket-подключения
+type Identity struct {
+	UserID   string
+	UserName string
+	Claims   *Claims
+}
+
+// Authorize — дополнительная проверка доступа к конкретному ресурсу (чат, комната).
+// Ошибка, оборачивающая ErrForbidden, превращается в 403 / код закрытия 4403,
+// store.ErrNotFound — в 404 / 4404.
+type Authorize func(ctx context.Context, id *Identity) error
+
+// authFrame — первый кадр, если токен не передан в запросе
+type authFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+// WSAuthenticator — единая точка апгрейда WebSocket-подключений с аутентификацией.
+// Используется сокетами комнат, чатов и игры.
+type WSAuthenticator struct {
+	manager     *Manager
+	upgrader    websocket.Upgrader
+	authTimeout time.Duration
+
+	// live — открытые сокеты по jti токена, чтобы закрыть их при выходе;
+	// bus — шина для отзывов на других узлах, nil до Listen
+	mu   sync.Mutex
+	live map[string]*liveToken
+	bus  backplane.Backplane
+}
+
+// checkOrigin проверяет заголовок Origin до апгрейда; отказ — ответ 403
+func NewWSAuthenticator(manager *Manager, cfg config.WebSocketConfig, checkOrigin func(r *http.Request) bool) *WSAuthenticator {
+	return &WSAuthenticator{
+		manager: manager,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  cfg.ReadBufferSize,
+			WriteBufferSize: cfg.WriteBufferSize,
+			CheckOrigin:     checkOrigin,
+		},
+		authTimeout: cfg.AuthTimeout,
+		live:        make(map[string]*liveToken),
+	}
+}
+
+// Upgrade аутентифицирует запрос и переключает протокол.
+//
+// Токен берется из заголовка Authorization, параметра ?token= или подпротокола
+// "bearer". В этом случае отказ отправляется обычным HTTP-ответом до апгрейда.
+// Если токена в запросе нет, соединение апгрейдится и первым кадром ожидается
+// {"type":"auth","token":"..."}; отказ тогда закрывает сокет с кодом 4401/4403.
+//
+// Источник проверяется первым: authorize может создавать хабы и членство,
+// и запрос с чужой страницы не должен до них дойти.
+//
+// Сокет закрывается с кодом 4401, если токен отозван выходом (Disconnect).
+//
+// При ошибке ответ клиенту уже отправлен, вызывающему остается только выйти.
+func (a *WSAuthenticator) Upgrade(w http.ResponseWriter, r *http.Request, authorize Authorize) (*websocket.Conn, *Identity, error) {
+	if a.upgrader.CheckOrigin != nil && !a.upgrader.CheckOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return nil, nil, ErrOriginNotAllowed
+	}
+
+	token, fromProtocol := tokenFromRequest(r)
+
+	if token != "" {
+		identity, err := a.authenticate(r.Context(), token, authorize)
+		if err != nil {
+			status, message := httpStatus(err)
+			http.Error(w, message, status)
+			return nil, nil, err
+		}
+
+		var header http.Header
+		if fromProtocol {
+			header = http.Header{"Sec-WebSocket-Protocol": {BearerProtocol}}
+		}
+
+		conn, err := a.upgrader.Upgrade(w, r, header)
+		if err != nil {
+			return nil, nil, err
+		}
+		a.track(identity.Claims, conn)
+		return conn, identity, nil
+	}
+
+	conn, err := a.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn.SetReadLimit(authFrameLimit)
+	conn.SetReadDeadline(time.Now().Add(a.authTimeout))
+
+	var frame authFrame
+	if err := conn.ReadJSON(&frame); err != nil || frame.Type != "auth" || frame.Token == "" {
+		reject(conn, ErrMissingToken)
+		return nil, nil, ErrMissingToken
+	}
+
+	identity, err := a.authenticate(r.Context(), frame.Token, authorize)
+	if err != nil {
+		reject(conn, err)
+		return nil, nil, err
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	a.track(identity.Claims, conn)
+	return conn, identity, nil
+}
+
+func (a *WSAuthenticator) authenticate(ctx context.Context, token string, authorize Authorize) (*Identity, error) {
+	claims, err := a.manager.Parse(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		UserID:   claims.UserID,
+		UserName: claims.UserName,
+		Claims:   claims,
+	}
+
+	if authorize != nil {
+		if err := authorize(ctx, identity); err != nil {
+			return nil, err
+		}
+	}
+	return identity, nil
+}
+
+func tokenFromRequest(r *http.Request) (token string, fromProtocol bool) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return header[7:], false
+	}
+
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, false
+	}
+
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == BearerProtocol {
+			return protocols[i+1], true
+		}
+	}
+
+	return "", false
+}
+
+func httpStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrMissingToken):
+		return http.StatusUnauthorized, "Invalid token"
+	case errors.Is(err, ErrRevokedToken):
+		return http.StatusUnauthorized, "Token has been revoked"
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound, "Not found"
+	default:
+		log.Printf("WebSocket auth error: %v", err)
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+}
+
+// reject закрывает уже апгрейднутое соединение с кодом, соответствующим ошибке
+func reject(conn *websocket.Conn, err error) {
+	code := websocket.CloseInternalServerErr
+	reason := "internal error"
+
+	switch {
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrMissingToken), errors.Is(err, ErrRevokedToken):
+		code, reason = CloseUnauthorized, "unauthorized"
+	case errors.Is(err, ErrForbidden):
+		code, reason = CloseForbidden, err.Error()
+	default:
+		log.Printf("WebSocket auth error: %v", err)
+	}
+
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(time.Second))
+	conn.Close()
+}
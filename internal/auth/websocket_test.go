@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"server/internal/config"
+	"server/internal/store/memory"
+)
+
+const foreignOrigin = "https://evil.example"
+
+// newWSServer поднимает сервер, который апгрейдит каждый запрос через
+// WSAuthenticator и отвечает кадром с ID пользователя
+func newWSServer(t *testing.T, authorize Authorize) (*httptest.Server, *Manager) {
+	t.Helper()
+	st := memory.New()
+	manager := NewManager(config.JWTConfig{
+		Secret:          "test-secret-test-secret-test-secret-1234",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}, st.Tokens, st.Users)
+	ws := NewWSAuthenticator(manager, config.WebSocketConfig{AuthTimeout: time.Second}, func(r *http.Request) bool {
+		return r.Header.Get("Origin") != foreignOrigin
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, id, err := ws.Upgrade(w, r, authorize)
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(id.UserID))
+		conn.Close()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, manager
+}
+
+func issue(t *testing.T, manager *Manager, userID string) string {
+	t.Helper()
+	tokens, err := manager.Issue(context.Background(), userID, "user"+userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens.AccessToken
+}
+
+func TestUpgradeTokenSources(t *testing.T) {
+	srv, manager := newWSServer(t, nil)
+	token := issue(t, manager, "7")
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	tests := []struct {
+		name      string
+		query     string
+		header    http.Header
+		protocols []string
+		frame     string
+	}{
+		{"header", "", http.Header{"Authorization": {"Bearer " + token}}, nil, ""},
+		{"query", "?token=" + token, nil, nil, ""},
+		{"subprotocol", "", nil, []string{BearerProtocol, token}, ""},
+		{"first frame", "", nil, nil, `{"type":"auth","token":"` + token + `"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := websocket.Dialer{Subprotocols: tt.protocols}
+			conn, resp, err := dialer.Dial(url+tt.query, tt.header)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			if tt.protocols != nil && resp.Header.Get("Sec-WebSocket-Protocol") != BearerProtocol {
+				t.Errorf("negotiated protocol %q, want %q", resp.Header.Get("Sec-WebSocket-Protocol"), BearerProtocol)
+			}
+			if tt.frame != "" {
+				conn.WriteMessage(websocket.TextMessage, []byte(tt.frame))
+			}
+			_, data, err := conn.ReadMessage()
+			if err != nil || string(data) != "7" {
+				t.Errorf("read %q, %v; want the user ID", data, err)
+			}
+		})
+	}
+}
+
+func TestUpgradeRejects(t *testing.T) {
+	srv, manager := newWSServer(t, func(_ context.Context, id *Identity) error {
+		if id.UserID == "2" {
+			return fmt.Errorf("%w: not a member", ErrForbidden)
+		}
+		return nil
+	})
+	allowed := issue(t, manager, "1")
+	denied := issue(t, manager, "2")
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// Токен в запросе: отказ обычным HTTP-ответом
+	before := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"invalid token", "garbage", http.StatusUnauthorized},
+		{"forbidden", denied, http.StatusForbidden},
+	}
+	for _, tt := range before {
+		t.Run(tt.name, func(t *testing.T) {
+			_, resp, err := websocket.DefaultDialer.Dial(url+"?token="+tt.token, nil)
+			if err == nil || resp == nil || resp.StatusCode != tt.want {
+				t.Fatalf("dial: %v, response %v; want status %d", err, resp, tt.want)
+			}
+		})
+	}
+
+	// Токен первым кадром: отказ кодом закрытия
+	after := []struct {
+		name  string
+		frame string
+		want  int
+	}{
+		{"no auth frame", `{"type":"hello"}`, CloseUnauthorized},
+		{"invalid token", `{"type":"auth","token":"garbage"}`, CloseUnauthorized},
+		{"forbidden", `{"type":"auth","token":"` + denied + `"}`, CloseForbidden},
+	}
+	for _, tt := range after {
+		t.Run("frame "+tt.name, func(t *testing.T) {
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.WriteMessage(websocket.TextMessage, []byte(tt.frame))
+			_, _, err = conn.ReadMessage()
+			if !websocket.IsCloseError(err, tt.want) {
+				t.Errorf("read error %v, want close %d", err, tt.want)
+			}
+		})
+	}
+
+	// Источник проверяется до токена: чужая страница получает 403 даже с плохим токеном
+	for _, token := range []string{allowed, "garbage"} {
+		_, resp, err := websocket.DefaultDialer.Dial(url+"?token="+token, http.Header{"Origin": {foreignOrigin}})
+		if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("foreign origin: %v, response %v; want status 403", err, resp)
+		}
+	}
+
+	if conn, _, err := websocket.DefaultDialer.Dial(url+"?token="+allowed, nil); err != nil {
+		t.Errorf("allowed user: %v", err)
+	} else {
+		conn.Close()
+	}
+}
@@ -0,0 +1,126 @@
+// Package backplane — шина событий между репликами сервера. Через нее хабы
+// комнат и чатов на разных узлах обмениваются рассылками, адресными
+// сообщениями и событиями присутствия.
+//
+// Доставка best-effort: сообщения, опубликованные пока узел не подписан,
+// теряются, а участники упавшего узла остаются в списках остальных узлов
+// до их собственного выхода.
+package backplane
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"server/internal/config"
+	"sync"
+)
+
+// Message — сообщение темы вместе с узлом-отправителем
+type Message struct {
+	Topic string
+	Node  string
+	Data  []byte
+}
+
+// Handler вызывается последовательно для сообщений одной подписки
+type Handler func(Message)
+
+type Backplane interface {
+	// NodeID — идентификатор текущего узла
+	NodeID() string
+	// Publish рассылает данные подписчикам темы на остальных узлах.
+	// Собственные сообщения узлу не доставляются.
+	Publish(ctx context.Context, topic string, data []byte) error
+	// Subscribe подписывает обработчик на тему; возвращает функцию отписки
+	Subscribe(topic string, handler Handler) (unsubscribe func(), err error)
+	// Peers — сколько других узлов подписано на тему
+	Peers(ctx context.Context, topic string) (int, error)
+	Close() error
+}
+
+// New создает шину по конфигурации: memory — один узел, redis — Redis Pub/Sub
+func New(cfg config.BackplaneConfig) (Backplane, error) {
+	nodeID := cfg.NodeID
+	if nodeID == "" {
+		nodeID = NewNodeID()
+	}
+
+	switch cfg.Driver {
+	case "", "memory":
+		return NewMemoryBus().Node(nodeID), nil
+	case "redis":
+		return NewRedis(cfg.RedisURL, cfg.Prefix, nodeID)
+	default:
+		return nil, fmt.Errorf("backplane: unknown driver %q", cfg.Driver)
+	}
+}
+
+// NewNodeID — имя хоста со случайным суффиксом, уникальное между перезапусками
+func NewNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "node"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// subscription — очередь сообщений одной подписки. Publish никогда не
+// блокируется на медленном обработчике: сообщения копятся в очереди
+// и обрабатываются отдельной горутиной по порядку.
+type subscription struct {
+	handler Handler
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []Message
+	closed bool
+}
+
+func newSubscription(handler Handler) *subscription {
+	s := &subscription{handler: handler}
+	s.cond = sync.NewCond(&s.mu)
+	go s.run()
+	return s
+}
+
+func (s *subscription) push(msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.queue = append(s.queue, msg)
+	s.cond.Signal()
+}
+
+func (s *subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.queue = nil
+	s.cond.Signal()
+}
+
+func (s *subscription) run() {
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		msg := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		s.handler(msg)
+	}
+}
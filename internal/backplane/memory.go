@@ -0,0 +1,137 @@
+package backplane
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// MemoryBus — шина внутри одного процесса. Для одного узла достаточно
+// NewMemoryBus().Node(id); несколько узлов на одной шине позволяют
+// проверить межузловое поведение без внешнего брокера.
+type MemoryBus struct {
+	mu     sync.RWMutex
+	topics map[string]map[*memorySubscription]struct{}
+}
+
+type memorySubscription struct {
+	*subscription
+	node string
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		topics: make(map[string]map[*memorySubscription]struct{}),
+	}
+}
+
+// Node возвращает узел, подключенный к шине
+func (b *MemoryBus) Node(nodeID string) Backplane {
+	return &memoryNode{bus: b, id: nodeID}
+}
+
+type memoryNode struct {
+	bus *MemoryBus
+	id  string
+
+	mu     sync.Mutex
+	subs   map[*memorySubscription]string
+	closed bool
+}
+
+func (n *memoryNode) NodeID() string {
+	return n.id
+}
+
+func (n *memoryNode) Publish(_ context.Context, topic string, data []byte) error {
+	n.mu.Lock()
+	closed := n.closed
+	n.mu.Unlock()
+	if closed {
+		return errors.New("backplane: node is closed")
+	}
+
+	msg := Message{
+		Topic: topic,
+		Node:  n.id,
+		Data:  append([]byte(nil), data...),
+	}
+
+	n.bus.mu.RLock()
+	defer n.bus.mu.RUnlock()
+
+	for sub := range n.bus.topics[topic] {
+		if sub.node != n.id {
+			sub.push(msg)
+		}
+	}
+	return nil
+}
+
+func (n *memoryNode) Subscribe(topic string, handler Handler) (func(), error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, errors.New("backplane: node is closed")
+	}
+
+	sub := &memorySubscription{subscription: newSubscription(handler), node: n.id}
+	if n.subs == nil {
+		n.subs = make(map[*memorySubscription]string)
+	}
+	n.subs[sub] = topic
+
+	n.bus.mu.Lock()
+	if n.bus.topics[topic] == nil {
+		n.bus.topics[topic] = make(map[*memorySubscription]struct{})
+	}
+	n.bus.topics[topic][sub] = struct{}{}
+	n.bus.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			n.mu.Lock()
+			delete(n.subs, sub)
+			n.mu.Unlock()
+			n.bus.remove(topic, sub)
+		})
+	}, nil
+}
+
+func (n *memoryNode) Peers(_ context.Context, topic string) (int, error) {
+	n.bus.mu.RLock()
+	defer n.bus.mu.RUnlock()
+
+	nodes := make(map[string]struct{})
+	for sub := range n.bus.topics[topic] {
+		if sub.node != n.id {
+			nodes[sub.node] = struct{}{}
+		}
+	}
+	return len(nodes), nil
+}
+
+func (n *memoryNode) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.closed = true
+	for sub, topic := range n.subs {
+		n.bus.remove(topic, sub)
+	}
+	n.subs = nil
+	return nil
+}
+
+func (b *MemoryBus) remove(topic string, sub *memorySubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.topics[topic], sub)
+	if len(b.topics[topic]) == 0 {
+		delete(b.topics, topic)
+	}
+	sub.close()
+}
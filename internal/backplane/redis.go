@@ -0,0 +1,221 @@
+package backplane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisEnvelope — формат сообщения в канале Redis
+type redisEnvelope struct {
+	Node string `json:"node"`
+	Data []byte `json:"data"`
+}
+
+// subscribeTimeout — сколько ждать подтверждения подписки от Redis
+const subscribeTimeout = 5 * time.Second
+
+// redisBackplane держит одно Pub/Sub-соединение на узел и подписывает его
+// на канал темы, пока на тему есть хотя бы один подписчик
+type redisBackplane struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+	prefix string
+	node   string
+
+	mu       sync.Mutex
+	channels map[string]*redisChannel
+	closed   bool
+}
+
+// redisChannel — локальные подписки на канал; ready закрывается,
+// когда Redis подтвердил SUBSCRIBE
+type redisChannel struct {
+	subs      map[*subscription]struct{}
+	ready     chan struct{}
+	confirmed bool
+}
+
+// NewRedis подключается к Redis по URL вида redis://[:password@]host:port/db.
+// prefix отделяет каналы разных окружений в одном Redis.
+func NewRedis(url, prefix, nodeID string) (Backplane, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("backplane: parse redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("backplane: redis ping: %w", err)
+	}
+
+	b := &redisBackplane{
+		client:   client,
+		pubsub:   client.Subscribe(context.Background()),
+		prefix:   prefix,
+		node:     nodeID,
+		channels: make(map[string]*redisChannel),
+	}
+	go b.dispatch()
+
+	return b, nil
+}
+
+func (b *redisBackplane) NodeID() string {
+	return b.node
+}
+
+func (b *redisBackplane) Publish(ctx context.Context, topic string, data []byte) error {
+	payload, err := json.Marshal(redisEnvelope{Node: b.node, Data: data})
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.prefix+topic, payload).Err()
+}
+
+// Subscribe возвращается, когда Redis подтвердил подписку, поэтому
+// сообщения, опубликованные после этого, гарантированно дойдут
+func (b *redisBackplane) Subscribe(topic string, handler Handler) (func(), error) {
+	channel := b.prefix + topic
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, errors.New("backplane: closed")
+	}
+
+	ch := b.channels[channel]
+	if ch == nil {
+		ch = &redisChannel{
+			subs:  make(map[*subscription]struct{}),
+			ready: make(chan struct{}),
+		}
+		if err := b.pubsub.Subscribe(context.Background(), channel); err != nil {
+			b.mu.Unlock()
+			return nil, fmt.Errorf("backplane: subscribe %s: %w", channel, err)
+		}
+		b.channels[channel] = ch
+	}
+
+	sub := newSubscription(handler)
+	ch.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() { b.unsubscribe(channel, sub) })
+	}
+
+	select {
+	case <-ch.ready:
+		return unsubscribe, nil
+	case <-time.After(subscribeTimeout):
+		unsubscribe()
+		return nil, fmt.Errorf("backplane: subscribe %s: not confirmed in %s", channel, subscribeTimeout)
+	}
+}
+
+// Peers считает подписчиков канала; у каждого узла одно Pub/Sub-соединение
+func (b *redisBackplane) Peers(ctx context.Context, topic string) (int, error) {
+	channel := b.prefix + topic
+
+	counts, err := b.client.PubSubNumSub(ctx, channel).Result()
+	if err != nil {
+		return 0, err
+	}
+	n := int(counts[channel])
+
+	b.mu.Lock()
+	if ch := b.channels[channel]; ch != nil && ch.confirmed {
+		n--
+	}
+	b.mu.Unlock()
+
+	return n, nil
+}
+
+func (b *redisBackplane) unsubscribe(channel string, sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub.close()
+	ch := b.channels[channel]
+	if ch == nil {
+		return
+	}
+	delete(ch.subs, sub)
+	if len(ch.subs) > 0 || b.closed {
+		return
+	}
+	delete(b.channels, channel)
+	if err := b.pubsub.Unsubscribe(context.Background(), channel); err != nil {
+		log.Printf("backplane: unsubscribe %s: %v", channel, err)
+	}
+}
+
+// dispatch раздает сообщения Redis подпискам своего узла
+func (b *redisBackplane) dispatch() {
+	for received := range b.pubsub.ChannelWithSubscriptions() {
+		switch msg := received.(type) {
+		case *redis.Subscription:
+			if msg.Kind != "subscribe" {
+				continue
+			}
+			b.mu.Lock()
+			if ch := b.channels[msg.Channel]; ch != nil && !ch.confirmed {
+				ch.confirmed = true
+				close(ch.ready)
+			}
+			b.mu.Unlock()
+
+		case *redis.Message:
+			var env redisEnvelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				log.Printf("backplane: invalid message in %s: %v", msg.Channel, err)
+				continue
+			}
+			if env.Node == b.node {
+				continue
+			}
+
+			m := Message{
+				Topic: strings.TrimPrefix(msg.Channel, b.prefix),
+				Node:  env.Node,
+				Data:  env.Data,
+			}
+
+			b.mu.Lock()
+			if ch := b.channels[msg.Channel]; ch != nil {
+				for sub := range ch.subs {
+					sub.push(m)
+				}
+			}
+			b.mu.Unlock()
+		}
+	}
+}
+
+func (b *redisBackplane) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	for _, ch := range b.channels {
+		for sub := range ch.subs {
+			sub.close()
+		}
+	}
+	b.channels = nil
+	b.mu.Unlock()
+
+	return errors.Join(b.pubsub.Close(), b.client.Close())
+}
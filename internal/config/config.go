@@ -0,0 +1,571 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config — общая конфигурация сервера. Загружается один раз при старте
+// и передается во все подсистемы (routes, signaling, game).
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	CORS      CORSConfig      `yaml:"cors"`
+	WebSocket WebSocketConfig `yaml:"websocket"`
+	Rooms     RoomsConfig     `yaml:"rooms"`
+	Backplane BackplaneConfig `yaml:"backplane"`
+	SFU       SFUConfig       `yaml:"sfu"`
+	ICE       ICEConfig       `yaml:"ice"`
+	Recording RecordingConfig `yaml:"recording"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+type JWTConfig struct {
+	Secret          string        `yaml:"secret"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+}
+
+// CORSConfig — политика источников для HTTP CORS и WebSocket-апгрейдов.
+// Пустой AllowedOrigins разрешает только запросы с того же хоста.
+type CORSConfig struct {
+	AllowedOrigins   []string          `yaml:"allowed_origins"`
+	AllowCredentials bool              `yaml:"allow_credentials"`
+	AllowedMethods   []string          `yaml:"allowed_methods"`
+	AllowedHeaders   []string          `yaml:"allowed_headers"`
+	ExposedHeaders   []string          `yaml:"exposed_headers"`
+	MaxAge           time.Duration     `yaml:"max_age"`
+	Routes           []CORSRouteConfig `yaml:"routes"`
+}
+
+// CORSRouteConfig переопределяет политику для путей с заданным префиксом
+type CORSRouteConfig struct {
+	PathPrefix       string   `yaml:"path_prefix"`
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowCredentials *bool    `yaml:"allow_credentials"`
+}
+
+type WebSocketConfig struct {
+	ReadBufferSize  int           `yaml:"read_buffer_size"`
+	WriteBufferSize int           `yaml:"write_buffer_size"`
+	MaxMessageSize  int64         `yaml:"max_message_size"`
+	SendBufferSize  int           `yaml:"send_buffer_size"`
+	WriteWait       time.Duration `yaml:"write_wait"`
+	PongWait        time.Duration `yaml:"pong_wait"`
+	// AuthTimeout — сколько ждать кадр с токеном, если он не передан в запросе
+	AuthTimeout time.Duration `yaml:"auth_timeout"`
+	// ResumeGrace — сколько комната держит место участника после обрыва
+	// сокета, ожидая переподключения с токеном возобновления; 0 — не держит
+	ResumeGrace time.Duration `yaml:"resume_grace"`
+}
+
+// RoomsConfig — ограничения видеокомнат
+type RoomsConfig struct {
+	// MaxParticipants — лимит участников по умолчанию и верхняя граница
+	// для настройки max_participants отдельной комнаты
+	MaxParticipants int `yaml:"max_participants"`
+}
+
+// BackplaneConfig — шина событий между репликами. Драйвер memory подходит
+// для одного узла; для нескольких реплик нужен redis.
+type BackplaneConfig struct {
+	Driver   string `yaml:"driver"`
+	RedisURL string `yaml:"redis_url"`
+	// Prefix — префикс каналов, чтобы разделять окружения в одном Redis
+	Prefix string `yaml:"prefix"`
+	// NodeID — имя узла; по умолчанию имя хоста со случайным суффиксом
+	NodeID string `yaml:"node_id"`
+}
+
+// SFUConfig — серверная пересылка медиа. Выключена по умолчанию: тогда
+// комнаты работают только в режиме mesh (клиенты соединяются напрямую).
+type SFUConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// PortMin и PortMax — диапазон UDP-портов для медиа; 0 — любые порты
+	PortMin uint16 `yaml:"port_min"`
+	PortMax uint16 `yaml:"port_max"`
+	// PublicIPs — внешние адреса сервера, если он за NAT
+	PublicIPs []string `yaml:"public_ips"`
+	// ICEServers — STUN/TURN-серверы, которые использует сам сервер
+	ICEServers []string `yaml:"ice_servers"`
+}
+
+// ICEConfig — STUN/TURN-серверы, которые получают клиенты
+type ICEConfig struct {
+	// STUNURLs — адреса STUN, например stun:stun.example.com:3478
+	STUNURLs []string   `yaml:"stun_urls"`
+	TURN     TURNConfig `yaml:"turn"`
+}
+
+// TURNConfig — TURN с временными учетками на общем секрете. Enabled
+// запускает встроенный сервер; без него учетки выдаются для внешнего
+// сервера из URLs (например, coturn с use-auth-secret).
+type TURNConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	ListenAddr string `yaml:"listen_addr"`
+	// PublicIP — адрес, на котором клиенты видят relay встроенного сервера
+	PublicIP string `yaml:"public_ip"`
+	Realm    string `yaml:"realm"`
+	Secret   string `yaml:"secret"`
+	// CredentialTTL — срок действия выдаваемой учетки
+	CredentialTTL time.Duration `yaml:"credential_ttl"`
+	// RelayPortMin и RelayPortMax — диапазон relay-портов; 0 — любые порты
+	RelayPortMin uint16 `yaml:"relay_port_min"`
+	RelayPortMax uint16 `yaml:"relay_port_max"`
+	// AllowPrivatePeers разрешает пересылку в частные сети (10/8, 192.168/16 и т.п.)
+	AllowPrivatePeers bool `yaml:"allow_private_peers"`
+	// URLs — адреса TURN для клиентов; для встроенного сервера по умолчанию
+	// turn:<public_ip>:<порт listen_addr>
+	URLs []string `yaml:"urls"`
+}
+
+// ClientURLs — адреса TURN, которые сообщаются клиентам
+func (c TURNConfig) ClientURLs() []string {
+	if len(c.URLs) > 0 {
+		return c.URLs
+	}
+	if host := c.publicAddr(); host != "" {
+		return []string{"turn:" + host + "?transport=udp"}
+	}
+	return nil
+}
+
+// STUNURL — адрес встроенного сервера как STUN; пустой, если он выключен
+func (c TURNConfig) STUNURL() string {
+	if host := c.publicAddr(); host != "" {
+		return "stun:" + host
+	}
+	return ""
+}
+
+func (c TURNConfig) publicAddr() string {
+	if !c.Enabled {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(c.ListenAddr)
+	if err != nil {
+		return ""
+	}
+	return net.JoinHostPort(c.PublicIP, port)
+}
+
+// RecordingConfig — запись комнат на диск. Медиа принимает SFU,
+// поэтому запись требует sfu.enabled.
+type RecordingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Dir — каталог для записей
+	Dir string `yaml:"dir"`
+}
+
+// PingPeriod — как часто отправлять ping, должен быть меньше PongWait
+func (c WebSocketConfig) PingPeriod() time.Duration {
+	return (c.PongWait * 9) / 10
+}
+
+const minJWTSecretLength = 32
+
+// Default возвращает конфигурацию со значениями по умолчанию.
+// DSN и JWT-секрет по умолчанию не заданы и должны прийти из файла или окружения.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            "localhost:8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		JWT: JWTConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization"},
+			MaxAge:         10 * time.Minute,
+		},
+		WebSocket: WebSocketConfig{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			MaxMessageSize:  65536,
+			SendBufferSize:  256,
+			WriteWait:       10 * time.Second,
+			PongWait:        60 * time.Second,
+			AuthTimeout:     10 * time.Second,
+			ResumeGrace:     30 * time.Second,
+		},
+		Rooms: RoomsConfig{
+			MaxParticipants: 16,
+		},
+		Backplane: BackplaneConfig{
+			Driver: "memory",
+			Prefix: "videochat:",
+		},
+		ICE: ICEConfig{
+			TURN: TURNConfig{
+				ListenAddr:    "0.0.0.0:3478",
+				Realm:         "videochat",
+				CredentialTTL: 12 * time.Hour,
+			},
+		},
+		Recording: RecordingConfig{
+			Dir: "recordings",
+		},
+	}
+}
+
+// Load собирает конфигурацию: значения по умолчанию, затем YAML-файл
+// (если path не пустой), затем переменные окружения. Результат валидируется.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	var errs []error
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	envString("SERVER_ADDR", &c.Server.Addr)
+	collect(envDuration("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout))
+	collect(envDuration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout))
+	collect(envDuration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout))
+	collect(envDuration("SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout))
+
+	envString("DATABASE_URL", &c.Database.DSN)
+	collect(envInt("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns))
+	collect(envInt("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns))
+	collect(envDuration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime))
+
+	envString("JWT_SECRET", &c.JWT.Secret)
+	collect(envDuration("JWT_ACCESS_TOKEN_TTL", &c.JWT.AccessTokenTTL))
+	collect(envDuration("JWT_REFRESH_TOKEN_TTL", &c.JWT.RefreshTokenTTL))
+
+	envList("CORS_ALLOWED_ORIGINS", &c.CORS.AllowedOrigins)
+	collect(envBool("CORS_ALLOW_CREDENTIALS", &c.CORS.AllowCredentials))
+	collect(envDuration("CORS_MAX_AGE", &c.CORS.MaxAge))
+
+	collect(envInt("WS_READ_BUFFER_SIZE", &c.WebSocket.ReadBufferSize))
+	collect(envInt("WS_WRITE_BUFFER_SIZE", &c.WebSocket.WriteBufferSize))
+	collect(envInt64("WS_MAX_MESSAGE_SIZE", &c.WebSocket.MaxMessageSize))
+	collect(envInt("WS_SEND_BUFFER_SIZE", &c.WebSocket.SendBufferSize))
+	collect(envDuration("WS_WRITE_WAIT", &c.WebSocket.WriteWait))
+	collect(envDuration("WS_PONG_WAIT", &c.WebSocket.PongWait))
+	collect(envDuration("WS_AUTH_TIMEOUT", &c.WebSocket.AuthTimeout))
+	collect(envDuration("WS_RESUME_GRACE", &c.WebSocket.ResumeGrace))
+
+	collect(envInt("ROOM_MAX_PARTICIPANTS", &c.Rooms.MaxParticipants))
+
+	envString("BACKPLANE_DRIVER", &c.Backplane.Driver)
+	envString("REDIS_URL", &c.Backplane.RedisURL)
+	envString("BACKPLANE_PREFIX", &c.Backplane.Prefix)
+	envString("BACKPLANE_NODE_ID", &c.Backplane.NodeID)
+
+	collect(envBool("SFU_ENABLED", &c.SFU.Enabled))
+	collect(envUint16("SFU_PORT_MIN", &c.SFU.PortMin))
+	collect(envUint16("SFU_PORT_MAX", &c.SFU.PortMax))
+	envList("SFU_PUBLIC_IPS", &c.SFU.PublicIPs)
+	envList("SFU_ICE_SERVERS", &c.SFU.ICEServers)
+
+	envList("ICE_STUN_URLS", &c.ICE.STUNURLs)
+	collect(envBool("TURN_ENABLED", &c.ICE.TURN.Enabled))
+	envString("TURN_LISTEN_ADDR", &c.ICE.TURN.ListenAddr)
+	envString("TURN_PUBLIC_IP", &c.ICE.TURN.PublicIP)
+	envString("TURN_REALM", &c.ICE.TURN.Realm)
+	envString("TURN_SECRET", &c.ICE.TURN.Secret)
+	collect(envDuration("TURN_CREDENTIAL_TTL", &c.ICE.TURN.CredentialTTL))
+	collect(envUint16("TURN_RELAY_PORT_MIN", &c.ICE.TURN.RelayPortMin))
+	collect(envUint16("TURN_RELAY_PORT_MAX", &c.ICE.TURN.RelayPortMax))
+	collect(envBool("TURN_ALLOW_PRIVATE_PEERS", &c.ICE.TURN.AllowPrivatePeers))
+	envList("TURN_URLS", &c.ICE.TURN.URLs)
+
+	collect(envBool("RECORDING_ENABLED", &c.Recording.Enabled))
+	envString("RECORDING_DIR", &c.Recording.Dir)
+
+	return errors.Join(errs...)
+}
+
+// Validate проверяет, что конфигурация пригодна для запуска сервера
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn is required (DATABASE_URL)"))
+	}
+	if c.Database.MaxOpenConns <= 0 {
+		errs = append(errs, errors.New("database.max_open_conns must be positive"))
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns must be between 0 and max_open_conns"))
+	}
+
+	if len(c.JWT.Secret) < minJWTSecretLength {
+		errs = append(errs, fmt.Errorf("jwt.secret must be at least %d bytes (JWT_SECRET)", minJWTSecretLength))
+	}
+	if c.JWT.AccessTokenTTL <= 0 || c.JWT.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("jwt token TTLs must be positive"))
+	}
+	if c.JWT.RefreshTokenTTL < c.JWT.AccessTokenTTL {
+		errs = append(errs, errors.New("jwt.refresh_token_ttl must not be shorter than access_token_ttl"))
+	}
+
+	errs = append(errs, validateOrigins("cors", c.CORS.AllowedOrigins, c.CORS.AllowCredentials)...)
+	for i, route := range c.CORS.Routes {
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			errs = append(errs, fmt.Errorf("cors.routes[%d].path_prefix must start with /", i))
+		}
+		credentials := c.CORS.AllowCredentials
+		if route.AllowCredentials != nil {
+			credentials = *route.AllowCredentials
+		}
+		errs = append(errs, validateOrigins(fmt.Sprintf("cors.routes[%d]", i), route.AllowedOrigins, credentials)...)
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age must not be negative"))
+	}
+
+	if c.WebSocket.ReadBufferSize <= 0 || c.WebSocket.WriteBufferSize <= 0 {
+		errs = append(errs, errors.New("websocket buffer sizes must be positive"))
+	}
+	if c.WebSocket.MaxMessageSize <= 0 {
+		errs = append(errs, errors.New("websocket.max_message_size must be positive"))
+	}
+	if c.WebSocket.SendBufferSize <= 0 {
+		errs = append(errs, errors.New("websocket.send_buffer_size must be positive"))
+	}
+	if c.WebSocket.WriteWait <= 0 || c.WebSocket.PongWait <= 0 || c.WebSocket.AuthTimeout <= 0 {
+		errs = append(errs, errors.New("websocket timeouts must be positive"))
+	}
+	if c.WebSocket.ResumeGrace < 0 {
+		errs = append(errs, errors.New("websocket.resume_grace must not be negative"))
+	}
+
+	if c.Rooms.MaxParticipants <= 0 {
+		errs = append(errs, errors.New("rooms.max_participants must be positive"))
+	}
+
+	switch c.Backplane.Driver {
+	case "memory":
+	case "redis":
+		if c.Backplane.RedisURL == "" {
+			errs = append(errs, errors.New("backplane.redis_url is required for the redis driver (REDIS_URL)"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("backplane.driver must be memory or redis, got %q", c.Backplane.Driver))
+	}
+
+	if (c.SFU.PortMin == 0) != (c.SFU.PortMax == 0) || c.SFU.PortMin > c.SFU.PortMax {
+		errs = append(errs, errors.New("sfu.port_min and sfu.port_max must be set together and form a range"))
+	}
+
+	errs = append(errs, c.ICE.TURN.validate()...)
+
+	if c.Recording.Enabled {
+		if !c.SFU.Enabled {
+			errs = append(errs, errors.New("recording requires sfu.enabled"))
+		}
+		if c.Recording.Dir == "" {
+			errs = append(errs, errors.New("recording.dir is required (RECORDING_DIR)"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c TURNConfig) validate() []error {
+	var errs []error
+	if c.Secret == "" && (c.Enabled || len(c.URLs) > 0) {
+		errs = append(errs, errors.New("ice.turn.secret is required for TURN (TURN_SECRET)"))
+	}
+	if c.CredentialTTL <= 0 {
+		errs = append(errs, errors.New("ice.turn.credential_ttl must be positive"))
+	}
+	if !c.Enabled {
+		return errs
+	}
+	if net.ParseIP(c.PublicIP) == nil {
+		errs = append(errs, errors.New("ice.turn.public_ip must be an IP address (TURN_PUBLIC_IP)"))
+	}
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		errs = append(errs, fmt.Errorf("ice.turn.listen_addr: %w", err))
+	}
+	if c.Realm == "" {
+		errs = append(errs, errors.New("ice.turn.realm is required"))
+	}
+	if (c.RelayPortMin == 0) != (c.RelayPortMax == 0) || c.RelayPortMin > c.RelayPortMax {
+		errs = append(errs, errors.New("ice.turn.relay_port_min and relay_port_max must be set together and form a range"))
+	}
+	return errs
+}
+
+// validateOrigins проверяет формат источников: "*", "scheme://host[:port]"
+// или шаблон поддоменов "scheme://*.example.com"
+func validateOrigins(field string, origins []string, credentials bool) []error {
+	var errs []error
+	for _, origin := range origins {
+		if origin == "*" {
+			if credentials {
+				errs = append(errs, fmt.Errorf("%s: \"*\" cannot be combined with allow_credentials", field))
+			}
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" || strings.Contains(host, "/") {
+			errs = append(errs, fmt.Errorf("%s: invalid origin %q", field, origin))
+		}
+	}
+	return errs
+}
+
+func envString(key string, dst *string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = v
+	}
+}
+
+func envList(key string, dst *[]string) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+func envInt(key string, dst *int) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envInt64(key string, dst *int64) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envUint16(key string, dst *uint16) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = uint16(n)
+	return nil
+}
+
+func envBool(key string, dst *bool) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+func envDuration(key string, dst *time.Duration) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
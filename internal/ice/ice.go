@@ -0,0 +1,72 @@
+// Package ice выдает клиентам список STUN/TURN-серверов и запускает
+// встроенный TURN-сервер. TURN-учетки временные, в формате TURN REST API:
+// имя — "<unix-время истечения>:<ID пользователя>", пароль — base64(HMAC-SHA1)
+// имени на общем секрете. Поэтому их проверяет и встроенный сервер, и
+// внешний coturn с use-auth-secret и тем же секретом.
+package ice
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"server/internal/config"
+	"strconv"
+	"time"
+)
+
+// Server — описание ICE-сервера в формате RTCIceServer браузера
+type Server struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Provider собирает список ICE-серверов для пользователя
+type Provider struct {
+	stun   []string
+	turn   []string
+	secret string
+	ttl    time.Duration
+}
+
+func NewProvider(cfg config.ICEConfig) *Provider {
+	stun := cfg.STUNURLs
+	if url := cfg.TURN.STUNURL(); url != "" {
+		stun = append(append([]string(nil), stun...), url)
+	}
+	return &Provider{
+		stun:   stun,
+		turn:   cfg.TURN.ClientURLs(),
+		secret: cfg.TURN.Secret,
+		ttl:    cfg.TURN.CredentialTTL,
+	}
+}
+
+// Servers возвращает STUN-серверы и, если TURN настроен, TURN-серверы
+// с учеткой пользователя, действующей ttl с момента вызова
+func (p *Provider) Servers(userID string) []Server {
+	var servers []Server
+	if len(p.stun) > 0 {
+		servers = append(servers, Server{URLs: p.stun})
+	}
+	if len(p.turn) > 0 && p.secret != "" {
+		username := strconv.FormatInt(time.Now().Add(p.ttl).Unix(), 10) + ":" + userID
+		servers = append(servers, Server{
+			URLs:       p.turn,
+			Username:   username,
+			Credential: credential(p.secret, username),
+		})
+	}
+	return servers
+}
+
+// TTL — срок действия выдаваемых TURN-учеток
+func (p *Provider) TTL() time.Duration {
+	return p.ttl
+}
+
+func credential(secret, username string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,75 @@
+package ice
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"server/internal/config"
+
+	"github.com/pion/turn/v4"
+)
+
+// TURNServer — встроенный STUN/TURN-сервер на UDP
+type TURNServer struct {
+	server *turn.Server
+}
+
+// StartTURN запускает встроенный сервер. Relay-адреса выдаются на PublicIP
+// из диапазона relay-портов. Пересылка к адресам самого хоста и служебным
+// сетям запрещена, к частным сетям — если не разрешено в настройках.
+func StartTURN(cfg config.TURNConfig) (*TURNServer, error) {
+	publicIP := net.ParseIP(cfg.PublicIP)
+	if publicIP == nil {
+		return nil, fmt.Errorf("ice: invalid turn public ip %q", cfg.PublicIP)
+	}
+
+	conn, err := net.ListenPacket("udp4", cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("ice: listen %s: %w", cfg.ListenAddr, err)
+	}
+
+	var relay turn.RelayAddressGenerator = &turn.RelayAddressGeneratorStatic{
+		RelayAddress: publicIP,
+		Address:      "0.0.0.0",
+	}
+	if cfg.RelayPortMin != 0 {
+		relay = &turn.RelayAddressGeneratorPortRange{
+			RelayAddress: publicIP,
+			Address:      "0.0.0.0",
+			MinPort:      cfg.RelayPortMin,
+			MaxPort:      cfg.RelayPortMax,
+		}
+	}
+
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm:       cfg.Realm,
+		AuthHandler: turn.LongTermTURNRESTAuthHandler(cfg.Secret, nil),
+		PacketConnConfigs: []turn.PacketConnConfig{{
+			PacketConn:            conn,
+			RelayAddressGenerator: relay,
+			PermissionHandler: func(_ net.Addr, peer net.IP) bool {
+				return allowedPeer(peer, cfg.AllowPrivatePeers)
+			},
+		}},
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ice: start turn: %w", err)
+	}
+
+	log.Printf("TURN server listening on %s, relay %s", conn.LocalAddr(), publicIP)
+	return &TURNServer{server: server}, nil
+}
+
+func (s *TURNServer) Close() error {
+	return s.server.Close()
+}
+
+// allowedPeer — можно ли пересылать трафик на адрес peer
+func allowedPeer(peer net.IP, allowPrivate bool) bool {
+	if peer.IsLoopback() || peer.IsUnspecified() || peer.IsMulticast() ||
+		peer.IsLinkLocalUnicast() || peer.IsLinkLocalMulticast() {
+		return false
+	}
+	return allowPrivate || !peer.IsPrivate()
+}
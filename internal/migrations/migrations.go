@@ -0,0 +1,297 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Файлы миграций: NNNN_name.up.sql и NNNN_name.down.sql
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// Ключ advisory-блокировки, чтобы две реплики не мигрировали базу одновременно
+const lockKey = 7_352_019
+
+var ErrSchemaOutdated = errors.New("database schema version mismatch")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Latest — версия схемы, которую ожидает текущая сборка
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// conn — пул или отдельное соединение, на котором удерживается блокировка
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Version возвращает текущую версию схемы в базе (0 — миграции не применялись)
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	return currentVersion(ctx, m.db)
+}
+
+func currentVersion(ctx context.Context, db conn) (int, error) {
+	if err := ensureVersionTable(ctx, db); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("migrations: read version: %w", err)
+	}
+	return version, nil
+}
+
+// Check проверяет, что база находится ровно на ожидаемой версии схемы
+func (m *Migrator) Check(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if version != m.Latest() {
+		return fmt.Errorf("%w: database is at %d, server expects %d (run `server migrate up`)",
+			ErrSchemaOutdated, version, m.Latest())
+	}
+	return nil
+}
+
+// Up применяет все еще не примененные миграции
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down откатывает последние steps миграций
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	target := 0
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if m.migrations[i].Version > version {
+			continue
+		}
+		if steps == 0 {
+			target = m.migrations[i].Version
+			break
+		}
+		steps--
+	}
+
+	return m.To(ctx, target)
+}
+
+// To переводит схему на указанную версию, применяя up- или down-шаги.
+// Весь переход идет под одной advisory-блокировкой, а версия читается уже
+// после ее взятия: реплика, дождавшаяся блокировки, не применит повторно
+// то, что успела применить другая.
+func (m *Migrator) To(ctx context.Context, target int) error {
+	if target != 0 && m.find(target) == nil {
+		return fmt.Errorf("migrations: unknown version %d", target)
+	}
+
+	// Сессионная блокировка держится на соединении, поэтому берем отдельное
+	db, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrations: connect: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("migrations: lock: %w", err)
+	}
+	defer func() {
+		if _, err := db.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			log.Printf("Error releasing migration lock: %v", err)
+		}
+	}()
+
+	version, err := currentVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if target > version {
+		for _, mg := range m.migrations {
+			if mg.Version <= version || mg.Version > target {
+				continue
+			}
+			if err := apply(ctx, db, mg, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if mg.Version > version || mg.Version <= target {
+			continue
+		}
+		if err := apply(ctx, db, mg, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply выполняет шаг миграции в транзакции; вызывается под блокировкой
+func apply(ctx context.Context, db conn, mg Migration, up bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrations: begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	direction, script := "up", mg.Up
+	if !up {
+		direction, script = "down", mg.Down
+	}
+
+	log.Printf("Applying migration %04d_%s (%s)", mg.Version, mg.Name, direction)
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migrations: %04d_%s %s: %w", mg.Version, mg.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mg.Version, mg.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mg.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("migrations: record version %d: %w", mg.Version, err)
+	}
+
+	return tx.Commit()
+}
+
+func ensureVersionTable(ctx context.Context, db conn) error {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("migrations: create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// load читает встроенные файлы и собирает упорядоченный список миграций
+func load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("migrations: read embedded files: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migrations: unexpected file %s", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrations: bad file name %s", fileName)
+		}
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrations: bad version in %s", fileName)
+		}
+
+		data, err := files.ReadFile(path.Join("sql", fileName))
+		if err != nil {
+			return nil, fmt.Errorf("migrations: read %s: %w", fileName, err)
+		}
+
+		mg, exists := byVersion[version]
+		if !exists {
+			mg = &Migration{Version: version, Name: name}
+			byVersion[version] = mg
+		} else if mg.Name != name {
+			return nil, fmt.Errorf("migrations: version %d used by %s and %s", version, mg.Name, name)
+		}
+
+		if direction == "up" {
+			mg.Up = string(data)
+		} else {
+			mg.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migrations: %04d_%s must have both up and down steps", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, *mg)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
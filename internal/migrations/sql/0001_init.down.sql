@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS chat_participants;
+DROP TABLE IF EXISTS chats;
+DROP TABLE IF EXISTS friendship;
+DROP TABLE IF EXISTS users;
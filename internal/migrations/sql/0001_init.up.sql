@@ -0,0 +1,52 @@
+-- Базовая схема. IF NOT EXISTS позволяет применить миграцию поверх
+-- существующей базы, созданной до появления миграций.
+
+CREATE TABLE IF NOT EXISTS users (
+    id       SERIAL PRIMARY KEY,
+    name     TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL
+);
+
+-- user_id и friend_id исторически хранятся как текст
+CREATE TABLE IF NOT EXISTS friendship (
+    user_id   TEXT NOT NULL,
+    friend_id TEXT NOT NULL,
+    status    TEXT NOT NULL DEFAULT 'pending',
+    PRIMARY KEY (user_id, friend_id)
+);
+
+CREATE TABLE IF NOT EXISTS chats (
+    id   TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS chat_participants (
+    chat_id   TEXT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    user_id   TEXT NOT NULL,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, user_id)
+);
+
+-- Колонка "message_type " содержит пробел в конце имени — так она
+-- называется в рабочей базе, и запросы обращаются к ней именно так.
+CREATE TABLE IF NOT EXISTS messages (
+    id             SERIAL PRIMARY KEY,
+    chat_id        TEXT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    sender_id      TEXT NOT NULL,
+    message_text   TEXT NOT NULL,
+    "message_type " TEXT NOT NULL DEFAULT 'text',
+    is_read        BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS messages_chat_id_idx ON messages (chat_id, id);
+
+CREATE TABLE IF NOT EXISTS rooms (
+    id            SERIAL PRIMARY KEY,
+    name          TEXT NOT NULL,
+    created_by    TEXT NOT NULL,
+    chat_messages JSON
+);
+
+CREATE INDEX IF NOT EXISTS rooms_created_by_idx ON rooms (created_by);
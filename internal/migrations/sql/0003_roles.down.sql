@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS sanctions;
+DROP TABLE IF EXISTS room_members;
+ALTER TABLE chat_participants DROP COLUMN IF EXISTS role;
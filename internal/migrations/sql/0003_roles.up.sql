@@ -0,0 +1,45 @@
+-- Роли участников: owner, moderator, member, guest
+ALTER TABLE chat_participants
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+    CHECK (role IN ('owner', 'moderator', 'member', 'guest'));
+
+-- У чатов нет создателя; владельцем группового чата становится
+-- участник, присоединившийся первым
+UPDATE chat_participants cp
+SET role = 'owner'
+FROM (
+    SELECT DISTINCT ON (p.chat_id) p.chat_id, p.user_id
+    FROM chat_participants p
+    JOIN chats c ON c.id = p.chat_id
+    WHERE c.type = 'group'
+    ORDER BY p.chat_id, p.joined_at, p.user_id
+) first
+WHERE cp.chat_id = first.chat_id AND cp.user_id = first.user_id;
+
+-- room_id хранится текстом, как и остальные ссылки на сущности
+CREATE TABLE room_members (
+    room_id   TEXT NOT NULL,
+    user_id   TEXT NOT NULL,
+    role      TEXT NOT NULL DEFAULT 'member'
+        CHECK (role IN ('owner', 'moderator', 'member', 'guest')),
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (room_id, user_id)
+);
+
+CREATE INDEX room_members_user_id_idx ON room_members (user_id);
+
+INSERT INTO room_members (room_id, user_id, role)
+SELECT id::text, created_by, 'owner' FROM rooms;
+
+-- Санкции модераторов. expires_at IS NULL — бессрочно.
+CREATE TABLE sanctions (
+    scope       TEXT NOT NULL CHECK (scope IN ('room', 'chat')),
+    resource_id TEXT NOT NULL,
+    user_id     TEXT NOT NULL,
+    kind        TEXT NOT NULL CHECK (kind IN ('mute', 'ban')),
+    issued_by   TEXT NOT NULL,
+    reason      TEXT NOT NULL DEFAULT '',
+    expires_at  TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (scope, resource_id, user_id, kind)
+);
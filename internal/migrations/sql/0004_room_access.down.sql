@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS room_invites;
+DROP INDEX IF EXISTS rooms_visibility_idx;
+ALTER TABLE rooms DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,24 @@
+-- Видимость комнаты: public — в списке и открыта всем,
+-- invite_only — в списке, вход по приглашению,
+-- private — не в списке, вход по приглашению или добавлением участником.
+-- Существующие комнаты были открыты всем, поэтому остаются public.
+ALTER TABLE rooms
+    ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'private', 'invite_only'));
+
+CREATE INDEX rooms_visibility_idx ON rooms (visibility);
+
+-- Ссылки-приглашения. max_uses = 0 — без ограничения,
+-- expires_at IS NULL — бессрочно.
+CREATE TABLE room_invites (
+    code       TEXT PRIMARY KEY,
+    room_id    TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    max_uses   INTEGER NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+    uses       INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX room_invites_room_id_idx ON room_invites (room_id);
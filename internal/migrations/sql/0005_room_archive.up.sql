@@ -0,0 +1,2 @@
+-- Архивная комната остается в списках участников, но войти в нее нельзя
+ALTER TABLE rooms ADD COLUMN archived_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+-- max_participants = 0 — действует общий лимит сервера
+ALTER TABLE rooms ADD COLUMN max_participants INTEGER NOT NULL DEFAULT 0 CHECK (max_participants >= 0);
+-- lobby — новые участники ждут, пока их впустит владелец или модератор
+ALTER TABLE rooms ADD COLUMN lobby BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- media_mode — mesh (клиенты соединяются напрямую) или sfu (медиа через сервер)
+ALTER TABLE rooms ADD COLUMN media_mode TEXT NOT NULL DEFAULT 'mesh' CHECK (media_mode IN ('mesh', 'sfu'));
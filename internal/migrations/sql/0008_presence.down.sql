@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS presence_status;
+ALTER TABLE users DROP COLUMN IF EXISTS last_seen_at;
@@ -0,0 +1,4 @@
+-- last_seen_at — когда закрылось последнее подключение пользователя;
+-- presence_status — ручной статус присутствия, NULL — не задан
+ALTER TABLE users ADD COLUMN last_seen_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN presence_status TEXT CHECK (presence_status IN ('away', 'dnd'));
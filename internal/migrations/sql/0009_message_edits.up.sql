@@ -0,0 +1,15 @@
+-- edited_at — время последней правки; deleted_at — сообщение удалено,
+-- его текст стерт, а строка остается отметкой в истории чата
+ALTER TABLE messages ADD COLUMN edited_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Прежние тексты сообщений, замененные правками
+CREATE TABLE IF NOT EXISTS message_revisions (
+    id           SERIAL PRIMARY KEY,
+    message_id   INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    message_text TEXT NOT NULL,
+    edited_by    TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS message_revisions_message_id_idx ON message_revisions (message_id, id);
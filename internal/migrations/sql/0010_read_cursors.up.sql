@@ -0,0 +1,3 @@
+-- last_read_message_id — курсор прочтения: ID последнего прочитанного
+-- участником сообщения, 0 — ничего не прочитано
+ALTER TABLE chat_participants ADD COLUMN last_read_message_id INTEGER NOT NULL DEFAULT 0;
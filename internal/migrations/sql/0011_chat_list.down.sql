@@ -0,0 +1 @@
+DROP INDEX IF EXISTS chat_participants_user_id_idx;
@@ -0,0 +1,2 @@
+-- Список чатов пользователя выбирается по user_id
+CREATE INDEX chat_participants_user_id_idx ON chat_participants (user_id);
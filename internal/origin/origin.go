@@ -0,0 +1,188 @@
+// Package origin — единая политика источников для HTTP CORS и WebSocket-апгрейдов
+package origin
+
+import (
+	"net/http"
+	"net/url"
+	"server/internal/config"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rules — разрешенные источники для набора путей
+type rules struct {
+	any         bool
+	exact       map[string]bool
+	wildcards   []wildcard
+	credentials bool
+}
+
+// wildcard — шаблон "scheme://*.example.com", совпадает только с поддоменами
+type wildcard struct {
+	scheme string
+	suffix string
+}
+
+type route struct {
+	prefix string
+	rules  rules
+}
+
+// Policy решает, каким источникам разрешено обращаться к серверу.
+// Переопределения для путей проверяются от самого длинного префикса к короткому.
+type Policy struct {
+	base    rules
+	routes  []route
+	methods string
+	headers string
+	exposed string
+	maxAge  string
+}
+
+func NewPolicy(cfg config.CORSConfig) *Policy {
+	p := &Policy{
+		base:    newRules(cfg.AllowedOrigins, cfg.AllowCredentials),
+		methods: strings.Join(cfg.AllowedMethods, ", "),
+		headers: strings.Join(cfg.AllowedHeaders, ", "),
+		exposed: strings.Join(cfg.ExposedHeaders, ", "),
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	for _, rc := range cfg.Routes {
+		credentials := cfg.AllowCredentials
+		if rc.AllowCredentials != nil {
+			credentials = *rc.AllowCredentials
+		}
+		p.routes = append(p.routes, route{
+			prefix: rc.PathPrefix,
+			rules:  newRules(rc.AllowedOrigins, credentials),
+		})
+	}
+	sort.SliceStable(p.routes, func(i, j int) bool {
+		return len(p.routes[i].prefix) > len(p.routes[j].prefix)
+	})
+
+	return p
+}
+
+func newRules(origins []string, credentials bool) rules {
+	r := rules{exact: make(map[string]bool), credentials: credentials}
+	for _, o := range origins {
+		o = strings.ToLower(strings.TrimRight(o, "/"))
+		if o == "*" {
+			r.any = true
+			continue
+		}
+		scheme, host, _ := strings.Cut(o, "://")
+		if suffix, ok := strings.CutPrefix(host, "*."); ok {
+			r.wildcards = append(r.wildcards, wildcard{scheme: scheme, suffix: "." + suffix})
+			continue
+		}
+		r.exact[o] = true
+	}
+	return r
+}
+
+func (p *Policy) rulesFor(path string) *rules {
+	for i := range p.routes {
+		if strings.HasPrefix(path, p.routes[i].prefix) {
+			return &p.routes[i].rules
+		}
+	}
+	return &p.base
+}
+
+// allows проверяет источник. Пустой список разрешает только тот же хост.
+func (r *rules) allows(origin string, req *http.Request) bool {
+	if r.any {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	normalized := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	if r.exact[normalized] {
+		return true
+	}
+	host := strings.ToLower(u.Host)
+	for _, w := range r.wildcards {
+		if strings.EqualFold(u.Scheme, w.scheme) && strings.HasSuffix(host, w.suffix) {
+			return true
+		}
+	}
+
+	if len(r.exact) == 0 && len(r.wildcards) == 0 {
+		return strings.EqualFold(u.Host, req.Host)
+	}
+	return false
+}
+
+// CheckOrigin подходит для websocket.Upgrader. Запросы без Origin приходят
+// не из браузера и пропускаются: для них подмена источника невозможна.
+func (p *Policy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return p.rulesFor(r.URL.Path).allows(origin, r)
+}
+
+// Middleware выставляет CORS-заголовки и отвечает на preflight-запросы.
+// Запросы с неразрешенных источников проходят без заголовков — браузер
+// не отдаст ответ странице; preflight для них отклоняется с 403.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rules := p.rulesFor(r.URL.Path)
+		if !rules.allows(origin, r) {
+			if preflight {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		if rules.any && !rules.credentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if rules.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if p.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", p.exposed)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", p.methods)
+		h.Set("Access-Control-Allow-Headers", p.headers)
+		if p.maxAge != "" {
+			h.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,98 @@
+package origin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/config"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	noCredentials := false
+	p := NewPolicy(config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com/", "https://*.example.org"},
+		AllowCredentials: true,
+		Routes: []config.CORSRouteConfig{
+			{PathPrefix: "/public", AllowedOrigins: []string{"*"}, AllowCredentials: &noCredentials},
+			{PathPrefix: "/same-host"},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+		want   bool
+	}{
+		{"no origin", "/ws", "", true},
+		{"exact", "/ws", "https://app.example.com", true},
+		{"exact, other case", "/ws", "HTTPS://APP.EXAMPLE.COM", true},
+		{"other scheme", "/ws", "http://app.example.com", false},
+		{"subdomain wildcard", "/ws", "https://a.b.example.org", true},
+		{"wildcard does not match the apex", "/ws", "https://example.org", false},
+		{"suffix lookalike", "/ws", "https://evilexample.org", false},
+		{"unknown", "/ws", "https://evil.example", false},
+		{"malformed", "/ws", "::", false},
+		{"route allows any", "/public/x", "https://evil.example", true},
+		{"empty route allows the same host", "/same-host", "http://server.test", true},
+		{"empty route rejects other hosts", "/same-host", "https://app.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://server.test"+tt.path, nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := p.CheckOrigin(r); got != tt.want {
+				t.Errorf("CheckOrigin(%q on %s) = %v, want %v", tt.origin, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	p := NewPolicy(config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+		AllowedMethods:   []string{"GET", "POST"},
+	})
+	handler := p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		preflight  bool
+		wantStatus int
+		wantAllow  string
+	}{
+		{"allowed request", "GET", "https://app.example.com", false, http.StatusTeapot, "https://app.example.com"},
+		{"allowed preflight", "OPTIONS", "https://app.example.com", true, http.StatusNoContent, "https://app.example.com"},
+		{"foreign request passes without headers", "GET", "https://evil.example", false, http.StatusTeapot, ""},
+		{"foreign preflight", "OPTIONS", "https://evil.example", true, http.StatusForbidden, ""},
+		{"no origin", "GET", "", false, http.StatusTeapot, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/x", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
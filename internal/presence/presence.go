@@ -0,0 +1,437 @@
+// Package presence — присутствие пользователей в сети. Сервис считает
+// WebSocket-подключения каждого пользователя (чаты, комнаты, игра, сокет
+// присутствия) на всех узлах, хранит ручной статус и время последнего
+// визита в users и рассылает изменения друзьям.
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"server/internal/backplane"
+	"server/internal/store"
+	"sync"
+	"time"
+)
+
+// topic — тема шины, в которой узлы обмениваются присутствием
+const topic = "presence"
+
+// Узел раз в heartbeatInterval сообщает остальным, кто у него в сети.
+// Узел, молчащий дольше heartbeatTimeout (упал или отрезан от шины),
+// считается выключенным, и его пользователи уходят из сети.
+const (
+	heartbeatInterval = 10 * time.Second
+	heartbeatTimeout  = 3 * heartbeatInterval
+)
+
+// watcherBuffer — сколько обновлений ждет медленного подписчика;
+// остальные теряются
+const watcherBuffer = 64
+
+var ErrInvalidStatus = errors.New("presence: invalid status")
+
+// Presence — присутствие пользователя: online, away, dnd или offline
+type Presence struct {
+	UserID   string               `json:"user_id"`
+	Status   store.PresenceStatus `json:"status"`
+	LastSeen *time.Time           `json:"last_seen,omitempty"`
+}
+
+// event — изменение присутствия на другом узле
+type event struct {
+	Kind     string   `json:"kind"`
+	UserID   string   `json:"user_id,omitempty"`
+	FriendID string   `json:"friend_id,omitempty"`
+	Users    []string `json:"users,omitempty"`
+}
+
+const (
+	// eventHello — узел запущен, остальные присылают online своих пользователей
+	eventHello = "hello"
+	// eventOnline и eventOffline — первое подключение пользователя к узлу и
+	// закрытие последнего
+	eventOnline  = "online"
+	eventOffline = "offline"
+	// eventStatus — пользователь сменил ручной статус
+	eventStatus = "status"
+	// eventFriends — пользователи стали друзьями
+	eventFriends = "friends"
+	// eventHeartbeat — узел жив; Users — все пользователи, подключенные к нему
+	eventHeartbeat = "heartbeat"
+)
+
+// Watcher — подписка пользователя на присутствие друзей и свое собственное
+type Watcher struct {
+	userID  string
+	friends map[string]bool
+	updates chan Presence
+}
+
+// Updates — канал обновлений; закрывается после Unwatch
+func (w *Watcher) Updates() <-chan Presence {
+	return w.updates
+}
+
+type Service struct {
+	users   store.UserStore
+	friends store.FriendshipStore
+	bus     backplane.Backplane
+
+	mu       sync.Mutex
+	local    map[string]int             // userID -> подключений к этому узлу
+	remote   map[string]map[string]bool // userID -> узлы, где он подключен
+	nodes    map[string]time.Time       // узел -> когда от него было последнее событие
+	watchers map[*Watcher]bool
+
+	heartbeat time.Duration
+	timeout   time.Duration
+}
+
+func NewService(users store.UserStore, friends store.FriendshipStore, bus backplane.Backplane) *Service {
+	return &Service{
+		users:    users,
+		friends:  friends,
+		bus:      bus,
+		local:    make(map[string]int),
+		remote:   make(map[string]map[string]bool),
+		nodes:    make(map[string]time.Time),
+		watchers: make(map[*Watcher]bool),
+
+		heartbeat: heartbeatInterval,
+		timeout:   heartbeatTimeout,
+	}
+}
+
+// Start подписывает сервис на присутствие с других узлов, запрашивает
+// у них, кто сейчас в сети, и начинает рассылать heartbeat
+func (s *Service) Start() error {
+	if _, err := s.bus.Subscribe(topic, s.receive); err != nil {
+		return err
+	}
+	s.publish(event{Kind: eventHello})
+	go s.run()
+	return nil
+}
+
+// run рассылает heartbeat и снимает из сети пользователей молчащих узлов
+func (s *Service) run() {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.publish(event{Kind: eventHeartbeat, Users: s.localUsers()})
+		s.expire(now.Add(-s.timeout))
+	}
+}
+
+// expire забывает узлы, от которых не было событий с before
+func (s *Service) expire(before time.Time) {
+	s.mu.Lock()
+	var silent []string
+	for node, seen := range s.nodes {
+		if seen.Before(before) {
+			silent = append(silent, node)
+			delete(s.nodes, node)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, node := range silent {
+		log.Printf("Presence node %s is silent; its users go offline", node)
+		s.syncNode(node, nil)
+	}
+}
+
+// localUsers — пользователи, подключенные к этому узлу
+func (s *Service) localUsers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]string, 0, len(s.local))
+	for userID := range s.local {
+		users = append(users, userID)
+	}
+	return users
+}
+
+// Connect учитывает новое WebSocket-подключение пользователя. Возвращенную
+// функцию нужно вызвать, когда подключение закроется; повторные вызовы
+// ничего не делают.
+func (s *Service) Connect(userID string) (release func()) {
+	s.mu.Lock()
+	s.local[userID]++
+	first := s.local[userID] == 1
+	cameOnline := first && len(s.remote[userID]) == 0
+	s.mu.Unlock()
+
+	if first {
+		s.publish(event{Kind: eventOnline, UserID: userID})
+	}
+	if cameOnline {
+		s.notify(userID)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.disconnect(userID) })
+	}
+}
+
+func (s *Service) disconnect(userID string) {
+	s.mu.Lock()
+	s.local[userID]--
+	last := s.local[userID] <= 0
+	if last {
+		delete(s.local, userID)
+	}
+	wentOffline := last && len(s.remote[userID]) == 0
+	s.mu.Unlock()
+
+	if !last {
+		return
+	}
+	if err := s.users.SetLastSeen(context.Background(), userID, time.Now()); err != nil {
+		log.Printf("Error saving last seen of %s: %v", userID, err)
+	}
+	s.publish(event{Kind: eventOffline, UserID: userID})
+	if wentOffline {
+		s.notify(userID)
+	}
+}
+
+// SetStatus задает ручной статус: away, dnd или online, чтобы его сбросить
+func (s *Service) SetStatus(ctx context.Context, userID string, status store.PresenceStatus) error {
+	switch status {
+	case store.PresenceOnline:
+		status = ""
+	case store.PresenceAway, store.PresenceDND:
+	default:
+		return ErrInvalidStatus
+	}
+
+	if err := s.users.SetPresenceStatus(ctx, userID, status); err != nil {
+		return err
+	}
+	s.publish(event{Kind: eventStatus, UserID: userID})
+	s.notify(userID)
+	return nil
+}
+
+// Get возвращает присутствие пользователей; несуществующие пропускаются
+func (s *Service) Get(ctx context.Context, ids []string) ([]Presence, error) {
+	saved, err := s.users.ListPresence(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Presence, 0, len(saved))
+	for _, p := range saved {
+		result = append(result, s.resolve(p))
+	}
+	return result, nil
+}
+
+// resolve вычисляет итоговый статус: ручной статус действует, только пока
+// пользователь в сети; вызывается под s.mu
+func (s *Service) resolve(p store.UserPresence) Presence {
+	status := store.PresenceOffline
+	if s.local[p.UserID] > 0 || len(s.remote[p.UserID]) > 0 {
+		status = store.PresenceOnline
+		if p.Status != "" {
+			status = p.Status
+		}
+	}
+	return Presence{UserID: p.UserID, Status: status, LastSeen: p.LastSeenAt}
+}
+
+// Watch подписывает пользователя на присутствие его друзей и возвращает
+// подписку вместе с их текущим присутствием
+func (s *Service) Watch(ctx context.Context, userID string) (*Watcher, []Presence, error) {
+	friends, err := s.friends.ListAccepted(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &Watcher{
+		userID:  userID,
+		friends: make(map[string]bool, len(friends)),
+		updates: make(chan Presence, watcherBuffer),
+	}
+	ids := make([]string, 0, len(friends))
+	for _, f := range friends {
+		w.friends[f.ID] = true
+		ids = append(ids, f.ID)
+	}
+
+	snapshot, err := s.Get(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	s.watchers[w] = true
+	s.mu.Unlock()
+	return w, snapshot, nil
+}
+
+// Unwatch отменяет подписку и закрывает ее канал
+func (s *Service) Unwatch(w *Watcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.watchers[w] {
+		delete(s.watchers, w)
+		close(w.updates)
+	}
+}
+
+// Befriend сообщает подписчикам на всех узлах, что пользователи стали
+// друзьями: каждый начинает получать присутствие другого
+func (s *Service) Befriend(userID, friendID string) {
+	s.befriend(userID, friendID)
+	s.publish(event{Kind: eventFriends, UserID: userID, FriendID: friendID})
+}
+
+func (s *Service) befriend(userID, friendID string) {
+	s.mu.Lock()
+	for w := range s.watchers {
+		switch w.userID {
+		case userID:
+			w.friends[friendID] = true
+		case friendID:
+			w.friends[userID] = true
+		}
+	}
+	s.mu.Unlock()
+
+	s.notify(userID)
+	s.notify(friendID)
+}
+
+// notify рассылает текущее присутствие пользователя его друзьям и ему
+// самому (на других устройствах) на этом узле
+func (s *Service) notify(userID string) {
+	saved, err := s.users.ListPresence(context.Background(), []string{userID})
+	if err != nil {
+		log.Printf("Error loading presence of %s: %v", userID, err)
+		return
+	}
+	if len(saved) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.resolve(saved[0])
+	for w := range s.watchers {
+		if w.userID != userID && !w.friends[userID] {
+			continue
+		}
+		select {
+		case w.updates <- p:
+		default:
+			log.Printf("Presence updates of %s are not read; dropping update", w.userID)
+		}
+	}
+}
+
+// receive обрабатывает событие присутствия с другого узла
+func (s *Service) receive(msg backplane.Message) {
+	var ev event
+	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+		log.Printf("Invalid presence event from %s: %v", msg.Node, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.nodes[msg.Node] = time.Now()
+	s.mu.Unlock()
+
+	switch ev.Kind {
+	case eventHello:
+		for _, userID := range s.localUsers() {
+			s.publish(event{Kind: eventOnline, UserID: userID})
+		}
+
+	case eventOnline, eventOffline:
+		s.mu.Lock()
+		changed := s.setRemote(ev.UserID, msg.Node, ev.Kind == eventOnline)
+		s.mu.Unlock()
+		if changed {
+			s.notify(ev.UserID)
+		}
+
+	case eventHeartbeat:
+		s.syncNode(msg.Node, ev.Users)
+
+	case eventStatus:
+		s.notify(ev.UserID)
+
+	case eventFriends:
+		s.befriend(ev.UserID, ev.FriendID)
+	}
+}
+
+// setRemote отмечает, подключен ли пользователь к узлу node; true — от
+// этого пользователь появился в сети или ушел из нее. Вызывается под s.mu.
+func (s *Service) setRemote(userID, node string, connected bool) bool {
+	wasOnline := s.local[userID] > 0 || len(s.remote[userID]) > 0
+	if connected {
+		if s.remote[userID] == nil {
+			s.remote[userID] = make(map[string]bool)
+		}
+		s.remote[userID][node] = true
+	} else {
+		delete(s.remote[userID], node)
+		if len(s.remote[userID]) == 0 {
+			delete(s.remote, userID)
+		}
+	}
+	online := s.local[userID] > 0 || len(s.remote[userID]) > 0
+	return online != wasOnline
+}
+
+// syncNode приводит пользователей узла node к списку users: пропущенное
+// событие online или offline исправляется следующим heartbeat
+func (s *Service) syncNode(node string, users []string) {
+	connected := make(map[string]bool, len(users))
+	for _, userID := range users {
+		connected[userID] = true
+	}
+
+	s.mu.Lock()
+	var changed []string
+	for userID, nodes := range s.remote {
+		if nodes[node] && !connected[userID] && s.setRemote(userID, node, false) {
+			changed = append(changed, userID)
+		}
+	}
+	for userID := range connected {
+		if !s.remote[userID][node] && s.setRemote(userID, node, true) {
+			changed = append(changed, userID)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, userID := range changed {
+		s.notify(userID)
+	}
+}
+
+// publish отправляет событие остальным узлам; ошибка только логируется
+func (s *Service) publish(ev event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Error encoding presence event: %v", err)
+		return
+	}
+	if err := s.bus.Publish(context.Background(), topic, data); err != nil {
+		log.Printf("Error publishing presence %s event: %v", ev.Kind, err)
+	}
+}
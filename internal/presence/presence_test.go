@@ -0,0 +1,95 @@
+package presence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"server/internal/backplane"
+	"server/internal/store"
+	"server/internal/store/memory"
+)
+
+// eventually ждет, пока cond не станет истинным: события шины доставляются асинхронно
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func status(t *testing.T, s *Service, userID string) store.PresenceStatus {
+	t.Helper()
+	list, err := s.Get(context.Background(), []string{userID})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Get(%s) = %v, %v", userID, list, err)
+	}
+	return list[0].Status
+}
+
+func TestSilentNodeUsersExpire(t *testing.T) {
+	st := memory.New()
+	user, err := st.Users.Create(context.Background(), "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := backplane.NewMemoryBus()
+	a := NewService(st.Users, st.Friendships, bus.Node("a"))
+	b := NewService(st.Users, st.Friendships, bus.Node("b"))
+	for _, s := range []*Service{a, b} {
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	release := a.Connect(user.ID)
+	defer release()
+	eventually(t, "user online on b", func() bool { return status(t, b, user.ID) == store.PresenceOnline })
+
+	// Узел a замолчал: b снимает его пользователей
+	b.expire(time.Now().Add(time.Minute))
+	if got := status(t, b, user.ID); got != store.PresenceOffline {
+		t.Fatalf("after expiry status = %s, want offline", got)
+	}
+
+	// Следующий heartbeat узла a возвращает пользователя
+	a.publish(event{Kind: eventHeartbeat, Users: a.localUsers()})
+	eventually(t, "user back online on b", func() bool { return status(t, b, user.ID) == store.PresenceOnline })
+}
+
+func TestHeartbeatSyncsRemoteUsers(t *testing.T) {
+	st := memory.New()
+	ctx := context.Background()
+	alice, _ := st.Users.Create(ctx, "alice", "hash")
+	bob, _ := st.Users.Create(ctx, "bob", "hash")
+
+	s := NewService(st.Users, st.Friendships, backplane.NewMemoryBus().Node("b"))
+
+	tests := []struct {
+		name  string
+		users []string
+		want  map[string]store.PresenceStatus
+	}{
+		{"both connected", []string{alice.ID, bob.ID},
+			map[string]store.PresenceStatus{alice.ID: store.PresenceOnline, bob.ID: store.PresenceOnline}},
+		{"missed offline of bob", []string{alice.ID},
+			map[string]store.PresenceStatus{alice.ID: store.PresenceOnline, bob.ID: store.PresenceOffline}},
+		{"node empty", nil,
+			map[string]store.PresenceStatus{alice.ID: store.PresenceOffline, bob.ID: store.PresenceOffline}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.syncNode("a", tt.users)
+			for userID, want := range tt.want {
+				if got := status(t, s, userID); got != want {
+					t.Errorf("status of %s = %s, want %s", userID, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Package recording записывает медиа видеокомнат на диск. Каждая запись —
+// каталог <dir>/<roomID>/<recordingID> с файлом на каждую дорожку (Ogg для
+// Opus, IVF для VP8/VP9/AV1, Annex B для H.264) и manifest.json с описанием.
+// Записи хранятся на узле, который принимал медиа.
+package recording
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"server/internal/store"
+	"sort"
+	"sync"
+	"time"
+)
+
+const manifestFile = "manifest.json"
+
+var (
+	// ErrActive — запись уже идет (или удаляется идущая запись)
+	ErrActive = errors.New("recording is in progress")
+	// ErrNotActive — в комнате нет идущей записи
+	ErrNotActive = errors.New("room is not being recorded")
+)
+
+// validName — ID комнат и записей и имена файлов, допустимые в путях
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Manifest — описание записи
+type Manifest struct {
+	ID        string     `json:"id"`
+	RoomID    string     `json:"room_id"`
+	StartedBy string     `json:"started_by"`
+	StartedAt time.Time  `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+	Tracks    []Track    `json:"tracks"`
+}
+
+// Track — файл одной дорожки участника
+type Track struct {
+	File      string     `json:"file"`
+	UserID    string     `json:"user_id"`
+	Kind      string     `json:"kind"`
+	Codec     string     `json:"codec"`
+	StartedAt time.Time  `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+}
+
+// Manager хранит записи в каталоге и следит, чтобы в комнате шла одна запись
+type Manager struct {
+	dir string
+
+	mu     sync.Mutex
+	active map[string]*Recording // roomID -> идущая запись
+}
+
+func New(dir string) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("recording: create %s: %w", dir, err)
+	}
+	return &Manager{dir: dir, active: make(map[string]*Recording)}, nil
+}
+
+// Start начинает запись комнаты; дорожки добавляются через AddTrack
+func (m *Manager) Start(roomID, userID string) (*Recording, error) {
+	if !validName.MatchString(roomID) {
+		return nil, store.ErrNotFound
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.active[roomID]; ok {
+		return nil, ErrActive
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(m.dir, roomID, id)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("recording: create %s: %w", dir, err)
+	}
+
+	rec := &Recording{
+		manager: m,
+		dir:     dir,
+		manifest: Manifest{
+			ID:        id,
+			RoomID:    roomID,
+			StartedBy: userID,
+			StartedAt: time.Now().UTC(),
+			Tracks:    []Track{},
+		},
+	}
+	if err := rec.saveLocked(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	m.active[roomID] = rec
+	return rec, nil
+}
+
+// List возвращает записи комнаты, новые первыми
+func (m *Manager) List(roomID string) ([]Manifest, error) {
+	if !validName.MatchString(roomID) {
+		return []Manifest{}, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(m.dir, roomID))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make([]Manifest, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := m.Get(roomID, entry.Name())
+		if err != nil {
+			// Каталог без манифеста — недописанная или чужая запись
+			continue
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].StartedAt.After(manifests[j].StartedAt)
+	})
+	return manifests, nil
+}
+
+// Get читает манифест записи; для неизвестной записи — store.ErrNotFound
+func (m *Manager) Get(roomID, id string) (Manifest, error) {
+	if !validName.MatchString(roomID) || !validName.MatchString(id) {
+		return Manifest{}, store.ErrNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.dir, roomID, id, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, store.ErrNotFound
+	}
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("recording: manifest %s/%s: %w", roomID, id, err)
+	}
+	return manifest, nil
+}
+
+// FilePath — путь к файлу дорожки или манифесту записи
+func (m *Manager) FilePath(roomID, id, file string) (string, error) {
+	manifest, err := m.Get(roomID, id)
+	if err != nil {
+		return "", err
+	}
+	if file != manifestFile {
+		known := false
+		for _, track := range manifest.Tracks {
+			if track.File == file {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return "", store.ErrNotFound
+		}
+	}
+	return filepath.Join(m.dir, roomID, id, file), nil
+}
+
+// Delete удаляет запись; идущую запись сначала нужно остановить
+func (m *Manager) Delete(roomID, id string) error {
+	if _, err := m.Get(roomID, id); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, ok := m.active[roomID]; ok && rec.manifest.ID == id {
+		return ErrActive
+	}
+	return os.RemoveAll(filepath.Join(m.dir, roomID, id))
+}
+
+// DeleteRoom удаляет все записи комнаты, кроме идущей
+func (m *Manager) DeleteRoom(roomID string) error {
+	manifests, err := m.List(roomID)
+	if err != nil {
+		return err
+	}
+	for _, manifest := range manifests {
+		if err := m.Delete(roomID, manifest.ID); err != nil && !errors.Is(err, ErrActive) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) finish(rec *Recording) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active[rec.manifest.RoomID] == rec {
+		delete(m.active, rec.manifest.RoomID)
+	}
+}
+
+// newID — сортируемый по времени ID записи
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,188 @@
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/pion/webrtc/v4/pkg/media/h264writer"
+	"github.com/pion/webrtc/v4/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// unsafeChars — символы, которые не попадают в имена файлов дорожек
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Recording — идущая запись комнаты. Реализует sfu.Sink.
+type Recording struct {
+	manager *Manager
+	dir     string
+
+	mu       sync.Mutex
+	manifest Manifest
+	writers  []*trackWriter
+	stopped  bool
+}
+
+func (r *Recording) ID() string {
+	return r.manifest.ID
+}
+
+// AddTrack открывает файл для дорожки участника
+func (r *Recording) AddTrack(userID string, track *webrtc.TrackRemote) (media.Writer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return nil, ErrNotActive
+	}
+
+	codec := track.Codec()
+	ext, open, err := writerFor(codec)
+	if err != nil {
+		return nil, err
+	}
+
+	index := len(r.manifest.Tracks)
+	file := fmt.Sprintf("%02d-%s-%s.%s", index+1, unsafeChars.ReplaceAllString(userID, "_"), track.Kind(), ext)
+	w, err := open(filepath.Join(r.dir, file))
+	if err != nil {
+		return nil, fmt.Errorf("recording: open %s: %w", file, err)
+	}
+
+	r.manifest.Tracks = append(r.manifest.Tracks, Track{
+		File:      file,
+		UserID:    userID,
+		Kind:      track.Kind().String(),
+		Codec:     codec.MimeType,
+		StartedAt: time.Now().UTC(),
+	})
+	if err := r.saveLocked(); err != nil {
+		log.Printf("Error saving recording manifest: %v", err)
+	}
+
+	tw := &trackWriter{recording: r, index: index, writer: w}
+	r.writers = append(r.writers, tw)
+	return tw, nil
+}
+
+// Stop закрывает все файлы и дописывает манифест
+func (r *Recording) Stop() (Manifest, error) {
+	r.mu.Lock()
+	if r.stopped {
+		manifest := r.manifest
+		r.mu.Unlock()
+		return manifest, nil
+	}
+	r.stopped = true
+	writers := r.writers
+	r.mu.Unlock()
+
+	var errs []error
+	for _, w := range writers {
+		errs = append(errs, w.Close())
+	}
+
+	r.mu.Lock()
+	now := time.Now().UTC()
+	r.manifest.StoppedAt = &now
+	errs = append(errs, r.saveLocked())
+	manifest := r.manifest
+	r.mu.Unlock()
+
+	r.manager.finish(r)
+	return manifest, errors.Join(errs...)
+}
+
+// trackClosed отмечает в манифесте конец дорожки
+func (r *Recording) trackClosed(index int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	r.manifest.Tracks[index].StoppedAt = &now
+	if err := r.saveLocked(); err != nil {
+		log.Printf("Error saving recording manifest: %v", err)
+	}
+}
+
+// saveLocked перезаписывает манифест атомарно, чтобы читатели не видели его наполовину
+func (r *Recording) saveLocked() error {
+	data, err := json.MarshalIndent(r.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(r.dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(r.dir, manifestFile))
+}
+
+// trackWriter — файл дорожки; безопасен для записи и закрытия из разных горутин
+type trackWriter struct {
+	recording *Recording
+	index     int
+
+	mu     sync.Mutex
+	writer media.Writer
+	closed bool
+}
+
+func (w *trackWriter) WriteRTP(packet *rtp.Packet) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	return w.writer.WriteRTP(packet)
+}
+
+func (w *trackWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.writer.Close()
+	w.mu.Unlock()
+
+	w.recording.trackClosed(w.index)
+	return err
+}
+
+// writerFor выбирает контейнер по кодеку дорожки
+func writerFor(codec webrtc.RTPCodecParameters) (string, func(path string) (media.Writer, error), error) {
+	mime := strings.ToLower(codec.MimeType)
+	switch mime {
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		return "ogg", func(path string) (media.Writer, error) {
+			channels := codec.Channels
+			if channels == 0 {
+				channels = 2
+			}
+			return oggwriter.New(path, codec.ClockRate, channels)
+		}, nil
+	case strings.ToLower(webrtc.MimeTypeVP8), strings.ToLower(webrtc.MimeTypeVP9), strings.ToLower(webrtc.MimeTypeAV1):
+		return "ivf", func(path string) (media.Writer, error) {
+			return ivfwriter.New(path, ivfwriter.WithCodec(codec.MimeType))
+		}, nil
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return "h264", func(path string) (media.Writer, error) {
+			return h264writer.New(path)
+		}, nil
+	}
+	return "", nil, fmt.Errorf("recording: unsupported codec %s", codec.MimeType)
+}
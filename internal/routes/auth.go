@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"server/internal/auth"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// All — выйти на всех устройствах
+	All bool `json:"all"`
+}
+
+func newAuthResponse(tokens auth.TokenPair, user User) AuthResponse {
+	return AuthResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		User:         user,
+	}
+}
+
+// @Summary Обновление токенов
+// @Description Обменять refresh-токен на новую пару токенов. Старый refresh-токен становится недействительным
+// @Tags users
+// @Accept json
+// @Produce json
+// @Router /users/refresh [post]
+// @Param data body routes.RefreshRequest true "Refresh-токен"
+// @Success 200 {object} routes.AuthResponse
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding refresh request: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	tokens, user, err := h.Auth.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrRefreshReused) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Error refreshing token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newAuthResponse(tokens, User{ID: user.ID, Name: user.Name}))
+}
+
+// @Summary Выход
+// @Description Отзывает текущий access-токен и цепочку refresh-токенов (или все сессии при all=true). Открытые по этому access-токену WebSocket-подключения закрываются с кодом 4401.
+// @Tags users
+// @Accept json
+// @Router /auth/logout [post]
+// @Param data body routes.LogoutRequest false "Refresh-токен текущей сессии"
+// @Security BearerAuth
+// @Success 204
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Printf("Error decoding logout request: %v", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.Auth.Revoke(r.Context(), claims); err != nil {
+		log.Printf("Error revoking access token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// Сокеты, открытые по этому токену, закрываются на всех узлах
+	h.ws.Disconnect(claims.Id)
+
+	var err error
+	if req.All {
+		err = h.Auth.RevokeAll(r.Context(), claims.UserID)
+	} else if req.RefreshToken != "" {
+		err = h.Auth.RevokeRefresh(r.Context(), claims.UserID, req.RefreshToken)
+	}
+	if err != nil {
+		log.Printf("Error revoking refresh tokens: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,165 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"server/internal/auth"
+)
+
+// session выпускает пользователю еще одну пару токенов, как при входе с другого устройства
+func (s *testServer) session(userID, name string) auth.TokenPair {
+	s.t.Helper()
+	tokens, err := s.auth.Issue(context.Background(), userID, name)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return tokens
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	s := newTestServer(t)
+	u, _ := s.user("alice")
+	tokens := s.session(u.ID, u.Name)
+
+	rec := s.do("POST", "/users/refresh", "", RefreshRequest{RefreshToken: tokens.RefreshToken})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	rotated := decode[AuthResponse](t, rec)
+	if rotated.RefreshToken == "" || rotated.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("refresh token was not rotated: %+v", rotated)
+	}
+
+	// Повторное предъявление старого токена отзывает всю цепочку
+	if rec := s.do("POST", "/users/refresh", "", RefreshRequest{RefreshToken: tokens.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh: status %d, want 401", rec.Code)
+	}
+	if rec := s.do("POST", "/users/refresh", "", RefreshRequest{RefreshToken: rotated.RefreshToken}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh from a revoked family: status %d, want 401", rec.Code)
+	}
+}
+
+func TestRefreshRejectsBadRequests(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name string
+		body any
+		want int
+	}{
+		{"malformed", []byte("{"), http.StatusBadRequest},
+		{"empty token", RefreshRequest{}, http.StatusBadRequest},
+		{"unknown token", RefreshRequest{RefreshToken: "nope"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.do("POST", "/users/refresh", "", tt.body); rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name string
+		body func(current auth.TokenPair) any
+		// Статус обновления текущей и второй сессии после выхода
+		wantCurrent int
+		wantOther   int
+	}{
+		{"access token only", func(auth.TokenPair) any { return nil }, http.StatusOK, http.StatusOK},
+		{"current session", func(current auth.TokenPair) any {
+			return LogoutRequest{RefreshToken: current.RefreshToken}
+		}, http.StatusUnauthorized, http.StatusOK},
+		{"all sessions", func(auth.TokenPair) any { return LogoutRequest{All: true} }, http.StatusUnauthorized, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			u, _ := s.user("alice")
+			current := s.session(u.ID, u.Name)
+			other := s.session(u.ID, u.Name)
+
+			if rec := s.do("POST", "/auth/logout", current.AccessToken, tt.body(current)); rec.Code != http.StatusNoContent {
+				t.Fatalf("logout: status %d, body %q", rec.Code, rec.Body.String())
+			}
+
+			if rec := s.do("POST", "/auth/logout", current.AccessToken, nil); rec.Code != http.StatusUnauthorized {
+				t.Errorf("access token still valid after logout: status %d", rec.Code)
+			}
+			if rec := s.do("POST", "/users/refresh", "", RefreshRequest{RefreshToken: current.RefreshToken}); rec.Code != tt.wantCurrent {
+				t.Errorf("current session refresh: status %d, want %d", rec.Code, tt.wantCurrent)
+			}
+			if rec := s.do("POST", "/users/refresh", "", RefreshRequest{RefreshToken: other.RefreshToken}); rec.Code != tt.wantOther {
+				t.Errorf("other session refresh: status %d, want %d", rec.Code, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestLogoutClosesLiveSockets(t *testing.T) {
+	s := newTestServer(t)
+	srv := httptest.NewServer(s.handler)
+	t.Cleanup(srv.Close)
+	u, _ := s.user("alice")
+	current := s.session(u.ID, u.Name)
+	other := s.session(u.ID, u.Name)
+
+	rec := s.do("POST", "/auth/chats", current.AccessToken, CreateChatRequest{TypeChat: TypeChatGroup, Name: "group"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create chat: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	chatID := decode[CreateChatResponse](t, rec).ChatID
+
+	dial := func(token string) *websocket.Conn {
+		t.Helper()
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/chats/" + chatID + "?token=" + token
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	revoked := dial(current.AccessToken)
+	alive := dial(other.AccessToken)
+
+	if rec := s.do("POST", "/auth/logout", current.AccessToken, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("logout: status %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	// Сокет отозванного токена закрывается с 4401, пропустив уже пришедшие кадры
+	revoked.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := revoked.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != auth.CloseUnauthorized {
+			t.Fatalf("revoked socket: err = %v, want close code %d", err, auth.CloseUnauthorized)
+		}
+		break
+	}
+
+	// Сокет другой сессии остается открытым и узнает об уходе первой
+	alive.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var ev ChatEnvelope
+		if err := alive.ReadJSON(&ev); err != nil {
+			t.Fatalf("other session socket: %v", err)
+		}
+		if ev.Type == ChatEventPresence && strings.Contains(string(ev.Payload), chatPresenceLeft) {
+			break
+		}
+	}
+}
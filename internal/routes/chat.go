@@ -1,15 +1,20 @@
 package routes
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"log"
 	"math/rand"
 	"net/http"
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/store"
 	"strconv"
 	"sync"
 	"time"
@@ -20,11 +25,11 @@ var (
 	hubMutex sync.RWMutex
 )
 
-type TypeChat string
+type TypeChat = store.ChatType
 
 const (
-	TypeChatPrivate TypeChat = "private"
-	TypeChatGroup   TypeChat = "group"
+	TypeChatPrivate = store.ChatTypePrivate
+	TypeChatGroup   = store.ChatTypeGroup
 )
 
 type CreateChatRequest struct {
@@ -32,25 +37,7 @@ type CreateChatRequest struct {
 	TypeChat TypeChat `json:"type_chat"`
 	Name     string   `json:"name"`
 }
-type Message struct {
-	ID          int       `json:"id"`
-	SenderID    string    `json:"sender_id"`
-	Text        string    `json:"message_text"`
-	MessageType string    `json:"message_type"`
-	IsRead      bool      `json:"is_read"`
-	CreatedAt   time.Time `json:"created_at"`
-}
-type Chat struct {
-	Id   string   `json:"id"`
-	Type TypeChat `json:"type"`
-	Name string   `json:"name"`
-}
-
-type ChatParticipants struct {
-	ChatId   string `json:"chat_id"`
-	UserId   string `json:"user_id"`
-	JoinedAt string `json:"joined_at"`
-}
+type Message = store.Message
 
 // CreateChatResponse — что мы возвращаем клиенту
 type CreateChatResponse struct {
@@ -63,43 +50,86 @@ type ClientChat struct {
 	conn   *websocket.Conn
 	chatId string
 	userId string
-	send   chan *MessageChat
+	send   chan *ChatEnvelope
 	hub    *ClientHub
 	name   string
-}
+	cfg    config.WebSocketConfig
 
-type MessageChat struct {
-	Message  string `json:"message"`
-	Name     string `json:"name"`
-	ChatId   string `json:"chat_id"`
-	SenderId string `json:"sender_id"`
+	// Код и причина закрытия при отключении хабом (kick, ban)
+	closeCode   int
+	closeReason string
 }
 
 type ClientHub struct {
+	chatID      string
 	clientsChat map[*ClientChat]bool
 	register    chan *ClientChat
 	unregister  chan *ClientChat
-	broadcast   chan *MessageChat
-	db          *sql.DB
+	incoming    chan chatRequest
+	kick        chan kickRequest
+	remote      chan *chatEvent
+	chats       store.ChatStore
+	messages    store.MessageStore
+	access      *access.Checker
+	bus         backplane.Backplane
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// chatRequest — кадр клиента для хаба; err — кадр не разобрался
+type chatRequest struct {
+	client *ClientChat
+	event  *ChatEnvelope
+	err    error
+}
+
+// chatEvent — событие чата между узлами: кадр для рассылки или отключение
+type chatEvent struct {
+	Kind   string        `json:"kind"`
+	Event  *ChatEnvelope `json:"event,omitempty"`
+	UserID string        `json:"user_id,omitempty"`
+	Reason string        `json:"reason,omitempty"`
+}
+
+const (
+	chatEventBroadcast = "broadcast"
+	chatEventKick      = "kick"
+)
+
+// kickRequest — принудительное отключение пользователя от чата
+type kickRequest struct {
+	userID string
+	reason string
+}
+
+// ChatHistoryResponse — окно истории чата от новых сообщений к старым
+type ChatHistoryResponse struct {
+	ChatID   string    `json:"chat_id"`
+	Messages []Message `json:"messages"`
+	// Total — всего сообщений в чате, включая удаленные
+	Total int `json:"total_messages"`
+	// NextCursor ведет к более старым сообщениям, PrevCursor — к более новым;
+	// пустой — в эту сторону сообщений нет. Курсор в сторону границы запроса
+	// выдается без проверки и может привести к пустому окну.
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	// ReadCursors — ID последнего прочитанного сообщения каждого участника
+	ReadCursors map[string]int `json:"read_cursors"`
+	Unread      int            `json:"unread"`
 }
 
 // GetChat Получение переписки из чата
 // @Summary Получение чата
-// @Description Получение чата
+// @Description Окно истории от новых сообщений к старым. Без границ — самые новые; before_id и after_id — сообщения старше или новее заданного, around_id — вокруг него, включая его самого; cursor — next_cursor или prev_cursor из прошлого ответа. Граница задается только одна. Удаленные сообщения остаются в истории без текста, с deleted_at. В групповых чатах у сообщений есть seen_by — кто их прочитал.
 // @Tags chats
-// @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} CreateChatResponse "Чат успешно создан или пользователь присоединен к существующему"
-// @Router /auth/chats [post]
+// @Param chat_id query string true "ID чата"
+// @Param limit query int false "Количество (по умолчанию 50, не больше 100)"
+// @Param cursor query string false "Курсор из next_cursor или prev_cursor"
+// @Param before_id query int false "Сообщения старше этого"
+// @Param after_id query int false "Сообщения новее этого"
+// @Param around_id query int false "Сообщения вокруг этого"
+// @Success 200 {object} routes.ChatHistoryResponse
+// @Router /auth/chats [get]
 func (h *Handler) GetChat(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value("user_id").(string)
 	if !ok {
@@ -111,71 +141,61 @@ func (h *Handler) GetChat(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing chat_id parameter", http.StatusBadRequest)
 		return
 	}
-	limit := 50 // По умолчанию 50 сообщений
-	offset := 0
-
-	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
-		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
-		if parsedOffset, err := strconv.Atoi(offsetParam); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	if !h.userHasAccessToChat(userID, chatID) {
+	if !h.userHasAccessToChat(r.Context(), userID, chatID) {
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	query := `
-    SELECT id, sender_id, message_text, "message_type ", is_read
-    FROM messages
-    WHERE chat_id = $1
-    ORDER BY id ASC  
-    LIMIT $2 OFFSET $3
-`
-
-	rows, err := h.DB.Query(query, chatID, limit, offset)
+	page, err := loadHistory(r.Context(), h.Store.Messages, chatID, query)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to get chat history", http.StatusInternalServerError)
+		return
+	}
+	messages := page.Messages
+	total, err := h.Store.Messages.Count(r.Context(), chatID)
 	if err != nil {
 		log.Printf("Database error: %v", err)
 		http.Error(w, "Failed to get chat history", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	messages := []Message{}
-	for rows.Next() {
-		var msg Message
-
-		err := rows.Scan(&msg.ID, &msg.SenderID, &msg.Text, &msg.MessageType, &msg.IsRead)
-		if err != nil {
-			log.Printf("Error scanning message row: %v", err)
-			continue
-		}
-
-		messages = append(messages, msg)
-
-		if err = rows.Err(); err != nil {
-			log.Printf("Error iterating through results: %v", err)
-			http.Error(w, "Error processing chat history", http.StatusInternalServerError)
-			return
-		}
 
+	chat, err := h.Store.Chats.Get(r.Context(), chatID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to get chat history", http.StatusInternalServerError)
+		return
+	}
+	cursors, err := h.Store.Chats.ReadCursors(r.Context(), chatID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to get chat history", http.StatusInternalServerError)
+		return
+	}
+	if chat.Type == TypeChatGroup {
+		fillSeenBy(messages, cursors)
+	}
+	unread, err := h.Store.Chats.UnreadCount(r.Context(), chatID, userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Failed to get chat history", http.StatusInternalServerError)
+		return
 	}
 
-	response := struct {
-		ChatID   string    `json:"chat_id"`
-		Messages []Message `json:"messages"`
-		Total    int       `json:"total_messages"`
-	}{
-		ChatID:   chatID,
-		Messages: messages,
-		Total:    len(messages),
+	response := ChatHistoryResponse{
+		ChatID:      chatID,
+		Messages:    messages,
+		Total:       total,
+		ReadCursors: cursors,
+		Unread:      unread,
 	}
+	response.NextCursor, response.PrevCursor = page.cursors()
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -186,6 +206,56 @@ func (h *Handler) GetChat(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Максимальная длина текста последнего сообщения в списке чатов, в символах
+const chatPreviewSize = 100
+
+// ListChats Список чатов пользователя
+// @Summary Список чатов
+// @Description Все чаты пользователя от недавно активных к давним: участники с именами, последнее сообщение (текст обрезан до 100 символов) и число непрочитанных
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Количество (по умолчанию 50)"
+// @Param offset query int false "Смещение"
+// @Success 200 {array} store.ChatSummary
+// @Router /auth/conversations [get]
+func (h *Handler) ListChats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 50
+	offset := 0
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v >= 0 {
+		offset = v
+	}
+
+	chats, err := h.Store.Chats.ListForUser(r.Context(), userID, limit, offset)
+	if err != nil {
+		log.Printf("Error listing chats: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if chats == nil {
+		chats = []store.ChatSummary{}
+	}
+	for _, chat := range chats {
+		if chat.LastMessage != nil {
+			if text := []rune(chat.LastMessage.Text); len(text) > chatPreviewSize {
+				chat.LastMessage.Text = string(text[:chatPreviewSize])
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chats)
+}
+
 // CreateChat создает новый чат или присоединяет к существующему
 // @Summary Создание чата
 // @Description Создает новый чат (приватный или групповой) или присоединяет пользователя к существующему приватному чату
@@ -216,153 +286,50 @@ func (h *Handler) CreateChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Дополнительная валидация для приватного чата
-	if req.TypeChat == "private" && req.FriendId == "" {
+	if req.TypeChat == TypeChatPrivate && req.FriendId == "" {
 		http.Error(w, "Friend ID is required for private chat", http.StatusBadRequest)
 		return
 	}
 
-	tx, err := h.DB.Begin()
-	if err != nil {
-		log.Printf("CreateChat: tx begin error: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	chat := store.Chat{
+		ID:   generateChatID(),
+		Type: req.TypeChat,
+		Name: req.Name,
 	}
-	defer tx.Rollback()
-
-	// Логика для приватного чата
-	if req.TypeChat == "private" {
-		// 1. Проверяем дружбу между пользователями
-		var status string
-		err = tx.QueryRow(`
-			SELECT status FROM friendship 
-			WHERE ((user_id = $1 AND friend_id = $2) 
-			   OR (user_id = $2 AND friend_id = $1))
-			AND status = 'accepted'
-		`, userID, req.FriendId).Scan(&status)
 
-		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Friendship not found or not accepted", http.StatusBadRequest)
-				return
-			}
-			log.Printf("CreateChat: friendship check error: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	// Приватный чат: дружба, поиск существующего чата и создание нового —
+	// одна операция хранилища, иначе параллельные запросы создадут дубликаты
+	if req.TypeChat == TypeChatPrivate {
+		existing, created, err := h.Store.Chats.CreatePrivate(r.Context(), chat, userID, req.FriendId)
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Friendship not found or not accepted", http.StatusBadRequest)
 			return
 		}
-
-		// 2. Проверяем существование приватного чата между этими пользователями
-		var existingChatID string
-		err = tx.QueryRow(`
-			SELECT c.id FROM chats c
-			JOIN chat_participants cp1 ON c.id = cp1.chat_id
-			JOIN chat_participants cp2 ON c.id = cp2.chat_id
-			WHERE c.type = 'private' 
-			AND cp1.user_id = $1 
-			AND cp2.user_id = $2
-			AND cp1.user_id != cp2.user_id
-		`, userID, req.FriendId).Scan(&existingChatID)
-
-		if err != nil && err != sql.ErrNoRows {
-			log.Printf("CreateChat: existing chat check error: %v", err)
+		if err != nil {
+			log.Printf("CreateChat: create private chat error: %v", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-
-		// 3. Если чат существует, проверяем участие текущего пользователя
-		if existingChatID != "" {
-			var participantExists bool
-			err = tx.QueryRow(`
-				SELECT EXISTS(SELECT 1 FROM chat_participants WHERE chat_id = $1 AND user_id = $2)
-			`, existingChatID, userID).Scan(&participantExists)
-
-			if err != nil {
-				log.Printf("CreateChat: participant check error: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
-
-			if !participantExists {
-				// Добавляем пользователя в существующий чат
-				_, err = tx.Exec(`
-					INSERT INTO chat_participants (chat_id, user_id, joined_at)
-					VALUES ($1, $2, NOW())
-				`, existingChatID, userID)
-
-				if err != nil {
-					log.Printf("CreateChat: add participant error: %v", err)
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-					return
-				}
-			}
-
-			// Коммитим транзакцию
-			if err = tx.Commit(); err != nil {
-				log.Printf("CreateChat: commit error: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
-
+		if !created {
 			// Возвращаем существующий чат
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"chat_id": existingChatID,
+				"chat_id": existing.ID,
 				"message": "Joined existing chat",
 			})
 			return
 		}
-	}
-
-	// 4. Создаем новый чат с сгенерированным ID
-	chatID := generateChatID()
-
-	_, err = tx.Exec(`
-		INSERT INTO chats (id, type, name)
-		VALUES ($1, $2, $3)
-	`, chatID, req.TypeChat, req.Name)
-
-	if err != nil {
+	} else if err := h.Store.Chats.Create(r.Context(), chat, userID); err != nil {
+		// Создатель группового чата — его владелец
 		log.Printf("CreateChat: create chat error: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	// 5. Добавляем создателя чата в участники
-	_, err = tx.Exec(`
-		INSERT INTO chat_participants (chat_id, user_id, joined_at)
-		VALUES ($1, $2, NOW())
-	`, chatID, userID)
-
-	if err != nil {
-		log.Printf("CreateChat: add creator error: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	// 6. Для приватного чата добавляем друга
-	if req.TypeChat == "private" {
-		_, err = tx.Exec(`
-			INSERT INTO chat_participants (chat_id, user_id, joined_at)
-			VALUES ($1, $2, NOW())
-		`, chatID, req.FriendId)
-
-		if err != nil {
-			log.Printf("CreateChat: add friend error: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Коммитим транзакцию
-	if err = tx.Commit(); err != nil {
-		log.Printf("CreateChat: commit error: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
 	// Возвращаем успешный ответ
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"chat_id": chatID,
+		"chat_id": chat.ID,
 		"message": "Chat created successfully",
 	})
 }
@@ -376,88 +343,38 @@ func (h *Handler) CreateConnectChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
-
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
+	conn, identity, err := h.ws.Upgrade(w, r, func(ctx context.Context, id *auth.Identity) error {
+		_, err := h.access.Require(ctx, store.ScopeChat, chatID, id.UserID, access.PermJoin)
+		return err
 	})
-
-	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("CreateConnectChat: upgrader error: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("CreateConnectChat: connection rejected: %v", err)
 		return
 	}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		log.Println("Invalid token claims")
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
-	userId, ok := claims["user_id"].(string)
-	if !ok {
-		log.Println("User Name not found in context")
-		conn.Close()
-		return
-	}
-
-	userName, ok := claims["user_name"].(string)
-	if !ok {
-		log.Println("User Name not found in context")
-		conn.Close()
-		return
-	}
-
-	var exists bool
-	err = h.DB.QueryRow(`
-    SELECT EXISTS(
-        SELECT 1 FROM chat_participants
-        WHERE chat_id = $1 AND user_id = $2
-    )
-`, chatID, userId).Scan(&exists)
-
-	if err != nil {
-		log.Printf("CreateConnectChat: chat participant check error: %v", err)
-		conn.Close()
-		return
-	}
-	if !exists {
-		errMsg := map[string]string{
-			"name":    "error",
-			"message": "Вы не участник этого чата",
-		}
-		data, _ := json.Marshal(errMsg)
-		conn.WriteMessage(websocket.TextMessage, data)
-		conn.Close()
-		return
-	}
-
-	newChatHub := getOrCreateChatHub(h.DB, chatID)
+	newChatHub := getOrCreateChatHub(h.Store.Chats, h.Store.Messages, h.access, h.bus, chatID)
 
 	client := &ClientChat{
 		conn:   conn,
 		chatId: chatID,
-		send:   make(chan *MessageChat, 256),
+		send:   make(chan *ChatEnvelope, h.Config.WebSocket.SendBufferSize),
 		hub:    newChatHub,
-		name:   userName,
-		userId: userId,
+		name:   identity.UserName,
+		userId: identity.UserID,
+		cfg:    h.Config.WebSocket,
 	}
 
+	release := h.presence.Connect(identity.UserID)
 	client.hub.register <- client
 
 	go client.writePump()
-	go client.readPump(client.hub)
+	go func() {
+		defer release()
+		client.readPump(client.hub)
+	}()
 }
 
-func getOrCreateChatHub(db *sql.DB, chatId string) *ClientHub {
+func getOrCreateChatHub(chats store.ChatStore, messages store.MessageStore, checker *access.Checker, bus backplane.Backplane, chatId string) *ClientHub {
 	hubMutex.RLock()
 	hub, exists := chatHubs[chatId]
 	hubMutex.RUnlock()
@@ -472,7 +389,10 @@ func getOrCreateChatHub(db *sql.DB, chatId string) *ClientHub {
 		return hub
 	}
 
-	newHub := NewClientHub(db)
+	newHub := NewClientHub(chats, messages, checker, bus, chatId)
+	if err := newHub.subscribe(); err != nil {
+		log.Printf("Chat %s is not shared with other nodes: %v", chatId, err)
+	}
 	go newHub.Run()
 	chatHubs[chatId] = newHub
 	return newHub
@@ -483,60 +403,281 @@ func (h *ClientHub) Run() {
 		select {
 		case client := <-h.register:
 			h.clientsChat[client] = true
-			println("Client registered", client)
-			message := &MessageChat{
-				Message:  "Is online",
-				Name:     client.name,
-				ChatId:   client.chatId,
-				SenderId: client.userId,
-			}
-			client.hub.broadcast <- message
+			log.Printf("Client %s joined chat %s", client.userId, h.chatID)
+			h.emit(h.presenceEvent(client, chatPresenceJoined), nil)
 
 		case client := <-h.unregister:
-			if _, ok := h.clientsChat[client]; ok {
-				message := &MessageChat{
-					Message:  "Left from chat",
-					Name:     client.name,
-					ChatId:   client.chatId,
-					SenderId: client.userId,
-				}
-				client.hub.broadcast <- message
-				delete(h.clientsChat, client)
-				println("Client unregistered", client)
-				close(client.send)
+			if h.drop(client) {
+				log.Printf("Client %s left chat %s", client.userId, h.chatID)
 			}
 
-		case msg := <-h.broadcast:
-			if msg.Message != "Is online" && msg.Message != "Left from chat" {
-				query := `
-		INSERT INTO messages (chat_id, sender_id, message_text, "message_type ", is_read )
-		VALUES ($1, $2, $3, $4, true)
-		RETURNING id
-	`
-				var messageID int
-				err := h.db.QueryRow(
-					query,
-					msg.ChatId,
-					msg.SenderId,
-					msg.Message,
-					"type",
-				).Scan(&messageID)
-
-				if err != nil {
-					log.Printf("Run: insert message error: %v", err)
-					return
-				}
+		case req := <-h.kick:
+			h.kickLocal(req)
+
+		case ev := <-h.remote:
+			switch ev.Kind {
+			case chatEventBroadcast:
+				h.deliver(ev.Event, nil)
+			case chatEventKick:
+				h.kickLocal(kickRequest{userID: ev.UserID, reason: ev.Reason})
 			}
 
-			for client := range h.clientsChat {
-				select {
-				case client.send <- msg:
-				default:
-					close(client.send)
-					delete(h.clientsChat, client)
-				}
+		case req := <-h.incoming:
+			h.handle(req)
+		}
+	}
+}
+
+// handle обрабатывает кадр клиента
+func (h *ClientHub) handle(req chatRequest) {
+	client := req.client
+	if _, ok := h.clientsChat[client]; !ok {
+		return
+	}
+	if req.err != nil {
+		h.reply(client, chatError(h.chatID, "", ChatErrorBadRequest, "invalid frame: "+req.err.Error()))
+		return
+	}
+
+	in := req.event
+	switch in.Type {
+	case ChatEventMessage:
+		var payload ChatMessagePayload
+		if err := json.Unmarshal(in.Payload, &payload); err != nil || payload.Text == "" {
+			h.reply(client, chatError(h.chatID, in.RequestID, ChatErrorBadRequest, "text is required"))
+			return
+		}
+		if !h.allowed(client, in.RequestID, access.PermSendMessage) {
+			return
+		}
+
+		msg, err := h.messages.Create(context.Background(), store.Message{
+			ChatID:      h.chatID,
+			SenderID:    client.userId,
+			Text:        payload.Text,
+			MessageType: store.MessageTypeText,
+		})
+		if err != nil {
+			log.Printf("Run: insert message error: %v", err)
+			h.reply(client, chatError(h.chatID, in.RequestID, chatErrorCode(err), "message was not saved"))
+			return
+		}
+		// Отвечая, автор прочитал все до своего сообщения; остальным об этом
+		// не сообщаем — курсор автора очевиден из самого сообщения
+		if _, _, err := h.chats.MarkRead(context.Background(), h.chatID, client.userId, msg.ID); err != nil {
+			log.Printf("Run: advance read cursor error: %v", err)
+		}
+
+		out := h.eventFrom(client, ChatEventMessage, ChatMessagePayload{MessageID: msg.ID, Text: msg.Text})
+		out.Timestamp = &msg.CreatedAt
+		h.emit(out, &chatReply{client, in.RequestID})
+
+	case ChatEventTyping:
+		var payload ChatTypingPayload
+		if err := json.Unmarshal(in.Payload, &payload); err != nil {
+			h.reply(client, chatError(h.chatID, in.RequestID, ChatErrorBadRequest, "invalid typing payload"))
+			return
+		}
+		if !h.allowed(client, in.RequestID, access.PermSendMessage) {
+			return
+		}
+		h.emit(h.eventFrom(client, ChatEventTyping, payload), &chatReply{client, in.RequestID})
+
+	case ChatEventRead:
+		var payload ChatMessageRef
+		if err := json.Unmarshal(in.Payload, &payload); err != nil || payload.MessageID <= 0 {
+			h.reply(client, chatError(h.chatID, in.RequestID, ChatErrorBadRequest, "message_id is required"))
+			return
+		}
+		cursor, moved, err := h.chats.MarkRead(context.Background(), h.chatID, client.userId, payload.MessageID)
+		if err != nil {
+			if chatErrorCode(err) == ChatErrorInternal {
+				log.Printf("Run: mark read error: %v", err)
+			}
+			h.reply(client, chatError(h.chatID, in.RequestID, chatErrorCode(err), err.Error()))
+			return
+		}
+
+		out := h.eventFrom(client, ChatEventRead, ChatMessageRef{MessageID: cursor})
+		if !moved {
+			// Курсор не сдвинулся — остальным сообщать нечего
+			out.RequestID = in.RequestID
+			h.reply(client, out)
+			return
+		}
+		h.emit(out, &chatReply{client, in.RequestID})
+
+	case ChatEventReaction:
+		var payload ChatReactionPayload
+		if err := json.Unmarshal(in.Payload, &payload); err != nil || payload.MessageID <= 0 ||
+			payload.Emoji == "" || len(payload.Emoji) > maxReactionSize {
+			h.reply(client, chatError(h.chatID, in.RequestID, ChatErrorBadRequest, "message_id and emoji are required"))
+			return
+		}
+		if !h.allowed(client, in.RequestID, access.PermSendMessage) {
+			return
+		}
+		h.emit(h.eventFrom(client, ChatEventReaction, payload), &chatReply{client, in.RequestID})
+
+	case ChatEventEdit, ChatEventDelete:
+		var payload ChatMessagePayload
+		if err := json.Unmarshal(in.Payload, &payload); err != nil || payload.MessageID <= 0 ||
+			(in.Type == ChatEventEdit && payload.Text == "") {
+			h.reply(client, chatError(h.chatID, in.RequestID, ChatErrorBadRequest, "message_id is required"))
+			return
+		}
+
+		var msg store.Message
+		var err error
+		if in.Type == ChatEventEdit {
+			msg, err = editMessage(context.Background(), h.access, h.messages, h.chatID, client.userId, payload.MessageID, payload.Text)
+		} else {
+			msg, err = deleteMessage(context.Background(), h.access, h.messages, h.chatID, client.userId, payload.MessageID)
+		}
+		if err != nil {
+			if chatErrorCode(err) == ChatErrorInternal {
+				log.Printf("Run: %s message error: %v", in.Type, err)
 			}
+			h.reply(client, chatError(h.chatID, in.RequestID, chatErrorCode(err), err.Error()))
+			return
+		}
+		h.emit(messageChangeEvent(msg, client.userId, client.name), &chatReply{client, in.RequestID})
+
+	default:
+		h.reply(client, chatError(h.chatID, in.RequestID, ChatErrorUnknownType, fmt.Sprintf("unknown event type %q", in.Type)))
+	}
+}
+
+// allowed проверяет право отправителя кадра; при отказе отвечает ему error
+func (h *ClientHub) allowed(client *ClientChat, requestID string, perm access.Permission) bool {
+	_, err := h.access.Require(context.Background(), store.ScopeChat, h.chatID, client.userId, perm)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, auth.ErrForbidden) {
+		log.Printf("Run: permission check error: %v", err)
+	}
+	h.reply(client, chatError(h.chatID, requestID, chatErrorCode(err), err.Error()))
+	return false
+}
+
+// eventFrom собирает кадр сервера от имени участника
+func (h *ClientHub) eventFrom(client *ClientChat, typ ChatEventType, payload interface{}) *ChatEnvelope {
+	ev := newChatEvent(typ, h.chatID, payload)
+	ev.SenderID = client.userId
+	ev.Name = client.name
+	return ev
+}
+
+func (h *ClientHub) presenceEvent(client *ClientChat, state string) *ChatEnvelope {
+	return h.eventFrom(client, ChatEventPresence, ChatPresencePayload{State: state})
+}
+
+// chatReply — кому из получателей рассылки вернуть request_id его кадра
+type chatReply struct {
+	client    *ClientChat
+	requestID string
+}
+
+// emit рассылает кадр подключениям чата на всех узлах
+func (h *ClientHub) emit(ev *ChatEnvelope, origin *chatReply) {
+	h.deliver(ev, origin)
+	h.publish(&chatEvent{Kind: chatEventBroadcast, Event: ev})
+}
+
+// deliver рассылает кадр подключениям чата на этом узле; отправитель
+// получает копию со своим request_id. Не успевающие читать подключения
+// отключаются после рассылки, чтобы их уход разослался уже без них.
+func (h *ClientHub) deliver(ev *ChatEnvelope, origin *chatReply) {
+	var slow []*ClientChat
+	for client := range h.clientsChat {
+		frame := ev
+		if origin != nil && origin.client == client && origin.requestID != "" {
+			reply := *ev
+			reply.RequestID = origin.requestID
+			frame = &reply
 		}
+		select {
+		case client.send <- frame:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	for _, client := range slow {
+		h.dropSlow(client)
+	}
+}
+
+// reply отправляет кадр одному подключению
+func (h *ClientHub) reply(client *ClientChat, ev *ChatEnvelope) {
+	select {
+	case client.send <- ev:
+	default:
+		h.dropSlow(client)
+	}
+}
+
+// dropSlow отключает подключение с переполненным буфером
+func (h *ClientHub) dropSlow(client *ClientChat) {
+	if h.clientsChat[client] {
+		log.Printf("Client %s is too slow, dropping it from chat %s", client.userId, h.chatID)
+		h.drop(client)
+	}
+}
+
+// drop убирает подключение из чата и сообщает остальным о его уходе;
+// false — подключение уже убрано
+func (h *ClientHub) drop(client *ClientChat) bool {
+	if !h.clientsChat[client] {
+		return false
+	}
+	delete(h.clientsChat, client)
+	close(client.send)
+	h.emit(h.presenceEvent(client, chatPresenceLeft), nil)
+	return true
+}
+
+func (h *ClientHub) kickLocal(req kickRequest) {
+	for client := range h.clientsChat {
+		if client.userId != req.userID {
+			continue
+		}
+		client.closeCode = auth.CloseForbidden
+		client.closeReason = req.reason
+		h.drop(client)
+	}
+}
+
+func chatTopic(chatID string) string {
+	return "chat:" + chatID
+}
+
+// subscribe подписывает хаб на события чата с других узлов
+func (h *ClientHub) subscribe() error {
+	_, err := h.bus.Subscribe(chatTopic(h.chatID), func(msg backplane.Message) {
+		var ev chatEvent
+		if err := json.Unmarshal(msg.Data, &ev); err != nil {
+			log.Printf("Invalid event for chat %s from %s: %v", h.chatID, msg.Node, err)
+			return
+		}
+		h.remote <- &ev
+	})
+	return err
+}
+
+func (h *ClientHub) publish(ev *chatEvent) {
+	publishChatEvent(h.bus, h.chatID, ev)
+}
+
+// publishChatEvent отправляет событие хабам чата на других узлах; ошибка только логируется
+func publishChatEvent(bus backplane.Backplane, chatID string, ev *chatEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Error encoding chat event: %v", err)
+		return
+	}
+	if err := bus.Publish(context.Background(), chatTopic(chatID), data); err != nil {
+		log.Printf("Error publishing %s event for chat %s: %v", ev.Kind, chatID, err)
 	}
 }
 
@@ -546,19 +687,22 @@ func (c *ClientChat) readPump(hub *ClientHub) {
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(c.cfg.MaxMessageSize)
+
 	for {
 		_, text, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
 
-		msg := &MessageChat{
-			Message:  string(text),
-			Name:     c.name,
-			ChatId:   c.chatId,
-			SenderId: c.userId,
+		req := chatRequest{client: c}
+		var ev ChatEnvelope
+		if err := json.Unmarshal(text, &ev); err != nil {
+			req.err = err
+		} else {
+			req.event = &ev
 		}
-		hub.broadcast <- msg
+		hub.incoming <- req
 	}
 }
 
@@ -569,7 +713,11 @@ func (c *ClientChat) writePump() {
 		select {
 		case message, ok := <-c.send:
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeCode != 0 {
+					closeMessage = websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
@@ -580,17 +728,37 @@ func (c *ClientChat) writePump() {
 				continue
 			}
 
+			c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
 			c.conn.WriteMessage(websocket.TextMessage, data)
 		}
 	}
 }
-func NewClientHub(db *sql.DB) *ClientHub {
+
+// disconnectFromChat отключает пользователя от хаба чата на всех узлах
+func disconnectFromChat(bus backplane.Backplane, chatID, userID, reason string) {
+	hubMutex.RLock()
+	hub, exists := chatHubs[chatID]
+	hubMutex.RUnlock()
+
+	if exists {
+		hub.kick <- kickRequest{userID: userID, reason: reason}
+	}
+	publishChatEvent(bus, chatID, &chatEvent{Kind: chatEventKick, UserID: userID, Reason: reason})
+}
+
+func NewClientHub(chats store.ChatStore, messages store.MessageStore, checker *access.Checker, bus backplane.Backplane, chatID string) *ClientHub {
 	return &ClientHub{
+		chatID:      chatID,
 		clientsChat: make(map[*ClientChat]bool),
-		broadcast:   make(chan *MessageChat, 256),
+		incoming:    make(chan chatRequest, 256),
 		register:    make(chan *ClientChat),
 		unregister:  make(chan *ClientChat),
-		db:          db,
+		kick:        make(chan kickRequest),
+		remote:      make(chan *chatEvent),
+		chats:       chats,
+		messages:    messages,
+		access:      checker,
+		bus:         bus,
 	}
 }
 
@@ -608,22 +776,10 @@ func generateRandomString(length int) string {
 	return string(b)
 }
 
-func (h *Handler) userHasAccessToChat(userID, chatID string) bool {
-	// Здесь должна быть логика проверки прав доступа
-	// Например, проверка, является ли пользователь участником чата
-
-	query := `
-		SELECT COUNT(*) 
-		FROM chat_participants 
-		WHERE chat_id = $1 AND user_id = $2
-	`
-
-	var count int
-	err := h.DB.QueryRow(query, chatID, userID).Scan(&count)
-	if err != nil {
+func (h *Handler) userHasAccessToChat(ctx context.Context, userID, chatID string) bool {
+	_, err := h.access.Require(ctx, store.ScopeChat, chatID, userID, access.PermJoin)
+	if err != nil && !errors.Is(err, auth.ErrForbidden) {
 		log.Printf("Error checking chat access: %v", err)
-		return false
 	}
-
-	return count > 0
+	return err == nil
 }
@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"server/internal/access"
+	"server/internal/backplane"
+	"server/internal/store"
+	"server/internal/store/memory"
+)
+
+func TestCreatePrivateChat(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	alice, aliceToken := s.user("alice")
+	bob, bobToken := s.user("bob")
+	_, carolToken := s.user("carol")
+	if _, err := s.store.Friendships.Create(ctx, store.Friendship{UserID: alice.ID, FriendID: bob.ID, Status: store.FriendStatusAccepted}); err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(token, friendID string) CreateChatResponse {
+		t.Helper()
+		rec := s.do("POST", "/auth/chats", token, CreateChatRequest{FriendId: friendID, TypeChat: TypeChatPrivate, Name: "dm"})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("create chat with %s: status %d, body %q", friendID, rec.Code, rec.Body.String())
+		}
+		return decode[CreateChatResponse](t, rec)
+	}
+
+	first := create(aliceToken, bob.ID)
+	// Повторный запрос с любой стороны возвращает тот же чат
+	if again := create(aliceToken, bob.ID); again.ChatID != first.ChatID {
+		t.Errorf("second request created chat %s, want %s", again.ChatID, first.ChatID)
+	}
+	if reverse := create(bobToken, alice.ID); reverse.ChatID != first.ChatID {
+		t.Errorf("request from the friend created chat %s, want %s", reverse.ChatID, first.ChatID)
+	}
+	for _, userID := range []string{alice.ID, bob.ID} {
+		if ok, _ := s.store.Chats.IsParticipant(ctx, first.ChatID, userID); !ok {
+			t.Errorf("user %s is not a participant", userID)
+		}
+	}
+
+	rejected := []struct {
+		name  string
+		token string
+		req   CreateChatRequest
+		want  int
+	}{
+		{"not friends", carolToken, CreateChatRequest{FriendId: alice.ID, TypeChat: TypeChatPrivate, Name: "dm"}, http.StatusBadRequest},
+		{"no friend", aliceToken, CreateChatRequest{TypeChat: TypeChatPrivate, Name: "dm"}, http.StatusBadRequest},
+		{"no name", aliceToken, CreateChatRequest{FriendId: bob.ID, TypeChat: TypeChatPrivate}, http.StatusBadRequest},
+		{"no token", "", CreateChatRequest{FriendId: bob.ID, TypeChat: TypeChatPrivate, Name: "dm"}, http.StatusUnauthorized},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.do("POST", "/auth/chats", tt.token, tt.req); rec.Code != tt.want {
+				t.Errorf("status %d, want %d, body %q", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetChatHistory(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	alice, aliceToken := s.user("alice")
+	_, strangerToken := s.user("stranger")
+	if err := s.store.Chats.Create(ctx, store.Chat{ID: "c", Type: store.ChatTypeGroup, Name: "group"}, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.store.Messages.Create(ctx, store.Message{ChatID: "c", SenderID: alice.ID, Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		query string
+		want  int
+		count int
+	}{
+		{"all", aliceToken, "?chat_id=c", http.StatusOK, 3},
+		{"page", aliceToken, "?chat_id=c&limit=2&before_id=2", http.StatusOK, 1},
+		{"stranger", strangerToken, "?chat_id=c", http.StatusForbidden, 0},
+		{"no chat", aliceToken, "", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do("GET", "/auth/chats"+tt.query, tt.token, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %q", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			history := decode[struct {
+				Messages []Message `json:"messages"`
+			}](t, rec)
+			if len(history.Messages) != tt.count {
+				t.Errorf("got %d messages, want %d", len(history.Messages), tt.count)
+			}
+		})
+	}
+}
+
+func TestChatHubDropsSlowClient(t *testing.T) {
+	bus := backplane.NewMemoryBus().Node("test")
+	t.Cleanup(func() { bus.Close() })
+	h := NewClientHub(nil, nil, nil, bus, "chat")
+	fast := &ClientChat{userId: "alice", send: make(chan *ChatEnvelope, 4)}
+	slow := &ClientChat{userId: "bob", send: make(chan *ChatEnvelope)}
+	h.clientsChat[fast] = true
+	h.clientsChat[slow] = true
+
+	h.deliver(newChatEvent(ChatEventMessage, "chat", nil), nil)
+
+	if h.clientsChat[slow] {
+		t.Fatal("slow client is still in the chat")
+	}
+	if _, ok := <-slow.send; ok {
+		t.Error("send channel of the slow client is not closed")
+	}
+	if got := (<-fast.send).Type; got != ChatEventMessage {
+		t.Errorf("first frame = %s, want %s", got, ChatEventMessage)
+	}
+	left := <-fast.send
+	if left.Type != ChatEventPresence || left.SenderID != "bob" {
+		t.Fatalf("second frame = %s from %s, want presence from bob", left.Type, left.SenderID)
+	}
+	var presence ChatPresencePayload
+	if err := json.Unmarshal(left.Payload, &presence); err != nil || presence.State != chatPresenceLeft {
+		t.Errorf("presence = %+v, %v; want %s", presence, err, chatPresenceLeft)
+	}
+}
+
+func TestChatHubStoresTextMessage(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	bus := backplane.NewMemoryBus().Node("test")
+	t.Cleanup(func() { bus.Close() })
+	if err := st.Chats.Create(ctx, store.Chat{ID: "chat", Type: store.ChatTypeGroup}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	h := NewClientHub(st.Chats, st.Messages, access.NewChecker(st.Members, st.Sanctions), bus, "chat")
+	alice := &ClientChat{userId: "alice", send: make(chan *ChatEnvelope, 4)}
+	h.clientsChat[alice] = true
+
+	h.handle(chatRequest{client: alice, event: newChatEvent(ChatEventMessage, "chat", ChatMessagePayload{Text: "hi"})})
+
+	list, err := st.Messages.List(ctx, "chat", store.MessageQuery{Limit: 10})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List = %+v, %v; want one message", list, err)
+	}
+	if list[0].MessageType != store.MessageTypeText {
+		t.Errorf("message type = %q, want %q", list[0].MessageType, store.MessageTypeText)
+	}
+}
@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"server/internal/auth"
+	"server/internal/store"
+	"time"
+)
+
+// ChatEventType — тип кадра сокета чата
+type ChatEventType string
+
+const (
+	// ChatEventMessage — новое сообщение; сохраняется в истории чата
+	ChatEventMessage ChatEventType = "message"
+	// ChatEventTyping — участник начал или перестал печатать
+	ChatEventTyping ChatEventType = "typing"
+	// ChatEventRead — участник прочитал сообщения до message_id. Сервер
+	// рассылает новый курсор участника, только если тот сдвинулся вперед;
+	// то же делает POST /auth/chats/{chatId}/read
+	ChatEventRead ChatEventType = "read"
+	// ChatEventEdit и ChatEventDelete — правка и удаление сообщения автором
+	// или модератором; то же делают PATCH и DELETE /auth/chats/{chatId}/messages/{id}
+	ChatEventEdit   ChatEventType = "edit"
+	ChatEventDelete ChatEventType = "delete"
+	// ChatEventReaction — реакция на сообщение
+	ChatEventReaction ChatEventType = "reaction"
+	// ChatEventPresence — участник открыл или закрыл чат; только от сервера
+	ChatEventPresence ChatEventType = "presence"
+	// ChatEventError — ошибка в ответ на кадр клиента; только от сервера
+	ChatEventError ChatEventType = "error"
+)
+
+// ChatEnvelope — кадр сокета чата.
+//
+// Клиент отправляет type, payload и, если ждет ответа, свой request_id.
+// Сервер рассылает кадры с собственным id события, timestamp и автором
+// (sender_id, name); отправитель получает свой кадр с тем же request_id,
+// это и есть подтверждение. Ошибка приходит только отправителю кадром error.
+type ChatEnvelope struct {
+	Type      ChatEventType   `json:"type"`
+	ID        string          `json:"id,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	ChatID    string          `json:"chat_id,omitempty"`
+	SenderID  string          `json:"sender_id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Timestamp *time.Time      `json:"timestamp,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// ChatMessagePayload — содержимое message и edit. MessageID назначает
+// сервер при сохранении; в edit клиент указывает, какое сообщение правит.
+type ChatMessagePayload struct {
+	MessageID int    `json:"message_id,omitempty"`
+	Text      string `json:"text"`
+}
+
+// ChatMessageRef — содержимое read и delete
+type ChatMessageRef struct {
+	MessageID int `json:"message_id"`
+}
+
+// ChatTypingPayload — содержимое typing
+type ChatTypingPayload struct {
+	Typing bool `json:"typing"`
+}
+
+// ChatReactionPayload — содержимое reaction; Remove снимает реакцию.
+// Реакции не сохраняются: их видят только подключенные участники.
+type ChatReactionPayload struct {
+	MessageID int    `json:"message_id"`
+	Emoji     string `json:"emoji"`
+	Remove    bool   `json:"remove,omitempty"`
+}
+
+// ChatPresencePayload — содержимое presence: joined или left
+type ChatPresencePayload struct {
+	State string `json:"state"`
+}
+
+const (
+	chatPresenceJoined = "joined"
+	chatPresenceLeft   = "left"
+)
+
+// ChatErrorCode — машиночитаемая причина ошибки в кадре error
+type ChatErrorCode string
+
+const (
+	ChatErrorBadRequest  ChatErrorCode = "bad_request"
+	ChatErrorUnknownType ChatErrorCode = "unknown_type"
+	ChatErrorForbidden   ChatErrorCode = "forbidden"
+	ChatErrorNotFound    ChatErrorCode = "not_found"
+	ChatErrorInternal    ChatErrorCode = "internal"
+)
+
+// ChatErrorPayload — содержимое error
+type ChatErrorPayload struct {
+	Code    ChatErrorCode `json:"code"`
+	Message string        `json:"message"`
+}
+
+// Максимальная длина эмодзи реакции в байтах
+const maxReactionSize = 32
+
+// newChatEvent собирает кадр сервера с новым ID события и текущим временем
+func newChatEvent(typ ChatEventType, chatID string, payload interface{}) *ChatEnvelope {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding %s chat event: %v", typ, err)
+	}
+	now := time.Now()
+	return &ChatEnvelope{
+		Type:      typ,
+		ID:        generateRandomString(16),
+		ChatID:    chatID,
+		Timestamp: &now,
+		Payload:   data,
+	}
+}
+
+// chatError собирает кадр error в ответ на запрос requestID
+func chatError(chatID, requestID string, code ChatErrorCode, message string) *ChatEnvelope {
+	ev := newChatEvent(ChatEventError, chatID, ChatErrorPayload{Code: code, Message: message})
+	ev.RequestID = requestID
+	return ev
+}
+
+// chatErrorCode сопоставляет ошибку хранилища или проверки прав коду кадра error
+func chatErrorCode(err error) ChatErrorCode {
+	switch {
+	case errors.Is(err, auth.ErrForbidden):
+		return ChatErrorForbidden
+	case errors.Is(err, store.ErrNotFound):
+		return ChatErrorNotFound
+	default:
+		return ChatErrorInternal
+	}
+}
@@ -1,17 +1,18 @@
 package routes
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"server/internal/store"
 )
 
-type FriendStatus string
+type FriendStatus = store.FriendStatus
 
 const (
-	StatusPending  FriendStatus = "pending"
-	StatusAccepted FriendStatus = "accepted"
+	StatusPending  = store.FriendStatusPending
+	StatusAccepted = store.FriendStatusAccepted
 )
 
 type Friend struct {
@@ -55,15 +56,14 @@ func (h *Handler) CreateFriendship(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var status string
-	err := h.DB.QueryRow(`
-    SELECT status FROM friendship 
-    WHERE user_id = $1 AND friend_id = $2
-`, req.UserId, req.FriendId).Scan(&status)
+	existing, err := h.Store.Friendships.Get(r.Context(), req.UserId, req.FriendId)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			var friend Friend
-			err = h.DB.QueryRow(`INSERT INTO friendship (user_id, friend_id, status) VALUES ($1, $2, $3) RETURNING status`, req.UserId, req.FriendId, StatusPending).Scan(&friend.Status)
+		if errors.Is(err, store.ErrNotFound) {
+			friend, err := h.Store.Friendships.Create(r.Context(), store.Friendship{
+				UserID:   req.UserId,
+				FriendID: req.FriendId,
+				Status:   StatusPending,
+			})
 			if err != nil {
 				log.Printf("Error inserting friend: %v", err)
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -77,11 +77,11 @@ func (h *Handler) CreateFriendship(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	if status == "pending" {
+	if existing.Status == StatusPending {
 		http.Error(w, "This Friend Pending", http.StatusConflict)
 		return
 	}
-	if status == "accepted" {
+	if existing.Status == StatusAccepted {
 		http.Error(w, "This Friend Accepted", http.StatusConflict)
 		return
 	}
@@ -109,22 +109,15 @@ func (h *Handler) GetFriends(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := h.DB.Query(`SELECT u.name FROM friendship f JOIN users u ON f.friend_id::text = u.id::text WHERE f.user_id = $1 AND f.status = $2`, userID, status)
+	list, err := h.Store.Friendships.ListNames(r.Context(), userID, FriendStatus(status))
 	if err != nil {
 		log.Printf("Error querying friendships: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 	var friends []FriendResponse
-	for rows.Next() {
-		var friend FriendResponse
-		err := rows.Scan(&friend.Name, &friend.Status)
-		if err != nil {
-			log.Printf("Error scanning friendships: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		friends = append(friends, friend)
+	for _, f := range list {
+		friends = append(friends, FriendResponse{Name: f.Name, Status: f.Status})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -162,25 +155,21 @@ func (h *Handler) AcceptedFriend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.DB.Exec(`UPDATE friendship SET status = $1 WHERE user_id = $2 AND friend_id = $3`, StatusAccepted, userID, req.Friend_id)
-	if err != nil {
-		log.Printf("Error updating friend: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	rows, err := result.RowsAffected()
+	err := h.Store.Friendships.UpdateStatus(r.Context(), userID, req.Friend_id, StatusAccepted)
 	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Friend Not Found", http.StatusNotFound)
+			return
+		}
 		log.Printf("Error updating friend: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if rows == 0 {
-		http.Error(w, "Friend Not Found", http.StatusNotFound)
-		return
-	}
+	h.presence.Befriend(userID, req.Friend_id)
 
+	// Количество обновленных строк — клиент исторически получает 1
+	rows := 1
 	w.Header().Set("Content-Type", "application/json")
 	if err = json.NewEncoder(w).Encode(rows); err != nil {
 		log.Printf("Error encoding response: %v", err)
@@ -6,10 +6,16 @@ import (
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
+	"server/internal/auth"
+	"server/internal/config"
+	"server/internal/presence"
 	"sync"
 	"time"
 )
 
+// Состояние игры (мяч, пули) считается хабом на одном узле, поэтому игры
+// не разделяются через шину: все игроки одной игры должны попадать на один
+// узел (например, балансировкой по gameId).
 var (
 	gameHubs = make(map[string]*Hub)
 	hubMutex sync.RWMutex
@@ -61,9 +67,12 @@ type Hub struct {
 type Client struct {
 	conn   *websocket.Conn
 	gameId string
+	userID string
+	name   string
 	send   chan interface{}
 	bullet chan *Bullet
 	hub    *Hub
+	cfg    config.WebSocketConfig
 }
 
 type HitMessage struct {
@@ -78,12 +87,21 @@ type BulletRemoveMessage struct {
 	BulletId int    `json:"bulletId"`
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// Максимальный размер сообщения игрока — ходы и пули умещаются в несколько десятков байт
+const maxGameMessageSize = 512
+
+type Handler struct {
+	cfg      *config.Config
+	ws       *auth.WSAuthenticator
+	presence *presence.Service
+}
+
+func NewHandler(cfg *config.Config, ws *auth.WSAuthenticator, presenceService *presence.Service) *Handler {
+	return &Handler{
+		cfg:      cfg,
+		ws:       ws,
+		presence: presenceService,
+	}
 }
 
 func getOrCreateChatHub(gameId string) *Hub {
@@ -127,7 +145,7 @@ func getOrCreateChatHub(gameId string) *Hub {
 	return newHub
 }
 
-func CreateConnectGame(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateConnectGame(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameId := vars["gameId"]
 	if gameId == "" {
@@ -135,10 +153,9 @@ func CreateConnectGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, identity, err := h.ws.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, "Failed to upgrade to websocket", http.StatusInternalServerError)
+		log.Printf("Game connection rejected: %v", err)
 		return
 	}
 
@@ -147,14 +164,21 @@ func CreateConnectGame(w http.ResponseWriter, r *http.Request) {
 	player := &Client{
 		conn:   conn,
 		gameId: gameId,
-		send:   make(chan interface{}, 256),
-		bullet: make(chan *Bullet, 256),
+		userID: identity.UserID,
+		name:   identity.UserName,
+		send:   make(chan interface{}, h.cfg.WebSocket.SendBufferSize),
+		bullet: make(chan *Bullet, h.cfg.WebSocket.SendBufferSize),
 		hub:    newGameHub,
+		cfg:    h.cfg.WebSocket,
 	}
 
+	release := h.presence.Connect(identity.UserID)
 	player.hub.register <- player
 	go player.writePump()
-	go player.readPump(player.hub)
+	go func() {
+		defer release()
+		player.readPump(player.hub)
+	}()
 }
 func (h *Hub) Run() {
 	go h.processBullets()
@@ -252,14 +276,14 @@ func (c *Client) readPump(hub *Hub) {
 	}()
 
 	// Увеличиваем таймауты
-	c.conn.SetReadDeadline(time.Now().Add(90 * time.Second))
+	c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(90 * time.Second))
+		c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
 		return nil
 	})
 
 	// Устанавливаем максимальный размер сообщения
-	c.conn.SetReadLimit(512)
+	c.conn.SetReadLimit(maxGameMessageSize)
 
 	for {
 		_, text, err := c.conn.ReadMessage()
@@ -271,14 +295,14 @@ func (c *Client) readPump(hub *Hub) {
 		}
 
 		// Обновляем deadline при каждом сообщении
-		c.conn.SetReadDeadline(time.Now().Add(90 * time.Second))
+		c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
 
 		var raw map[string]interface{}
 		if err := json.Unmarshal(text, &raw); err != nil {
 			log.Printf("Ошибка парсинга JSON: %v\n", err)
 			continue // Используем continue вместо return
 		}
-		log.Printf("Bullet received from Player %d: X=%d, Y=%d", raw)
+		log.Printf("Game message received: %v", raw)
 
 		if _, ok := raw["bullet"]; ok {
 			var wrapper struct {
@@ -309,7 +333,7 @@ func (c *Client) readPump(hub *Hub) {
 	}
 }
 func (c *Client) writePump() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(c.cfg.PingPeriod())
 	defer func() {
 		ticker.Stop()
 		c.conn.WriteMessage(websocket.CloseMessage,
@@ -331,14 +355,14 @@ func (c *Client) writePump() {
 				continue
 			}
 
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
 			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
 				return
 			}
 
 		case <-ticker.C:
 			// ping keepalive
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
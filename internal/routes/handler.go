@@ -1,17 +1,29 @@
 package routes
 
 import (
-	"context"
-	"database/sql"
-	"fmt"
-	"github.com/dgrijalva/jwt-go"
-	"log"
-	"net/http"
-	"time"
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/ice"
+	"server/internal/presence"
+	"server/internal/recording"
+	"server/internal/signaling"
+	"server/internal/store"
 )
 
 type Handler struct {
-	DB *sql.DB
+	Store  *store.Store
+	Config *config.Config
+	Auth   *auth.Manager
+	ws     *auth.WSAuthenticator
+	access *access.Checker
+	rooms  *signaling.RoomManager
+	bus    backplane.Backplane
+	ice    *ice.Provider
+	// recordings — nil, если запись выключена
+	recordings *recording.Manager
+	presence   *presence.Service
 }
 
 type User struct {
@@ -22,84 +34,20 @@ type User struct {
 	IsFriend *string `json:"is_friend,omitempty"`
 }
 
-type Room struct {
-	// Комната
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	CreatedBy string `json:"created_by"`
-}
-
-func NewHandler(db *sql.DB) *Handler {
+func NewHandler(st *store.Store, cfg *config.Config, authManager *auth.Manager, ws *auth.WSAuthenticator,
+	checker *access.Checker, rooms *signaling.RoomManager, bus backplane.Backplane, iceServers *ice.Provider,
+	recordings *recording.Manager, presenceService *presence.Service) *Handler {
 	return &Handler{
-		DB: db,
+		Store:  st,
+		Config: cfg,
+		Auth:   authManager,
+		ws:     ws,
+		access: checker,
+		rooms:  rooms,
+		bus:    bus,
+		ice:    iceServers,
+
+		recordings: recordings,
+		presence:   presenceService,
 	}
 }
-
-var jwtSecret = []byte("myapp-super-secret-jwt-key-2024-development")
-
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-			return
-		}
-
-		// Формат заголовка: "Bearer {token}"
-		if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-			http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := authHeader[7:]
-
-		// Парсинг и проверка токена
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Проверяем, что алгоритм подписи соответствует ожидаемому
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtSecret, nil
-		})
-
-		if err != nil {
-			log.Printf("Error parsing token: %v", err)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		if !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Извлечение ID пользователя из токена
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
-			return
-		}
-
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			log.Printf("AuthMiddleware: Invalid user ID in token, claims: %+v", claims)
-			http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
-			return
-		}
-
-		// Добавление ID пользователя в контекст запроса
-		ctx := context.WithValue(r.Context(), "user_id", string(userID))
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
-func createToken(userID string, userName string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":   userID,
-		"user_name": userName,
-		"exp":       time.Now().Add(time.Hour * 24).Unix(), // Токен действителен 24 часа
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
-}
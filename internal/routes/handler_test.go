@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/presence"
+	"server/internal/store"
+	"server/internal/store/memory"
+)
+
+// testServer — обработчики поверх хранилища в памяти за настоящей проверкой токенов
+type testServer struct {
+	t       *testing.T
+	store   *store.Store
+	auth    *auth.Manager
+	handler http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	st := memory.New()
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			Secret:          "test-secret-test-secret-test-secret-1234",
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+		},
+		WebSocket: config.Default().WebSocket,
+	}
+	cfg.WebSocket.MaxMessageSize = 512
+	manager := auth.NewManager(cfg.JWT, st.Tokens, st.Users)
+	bus := backplane.NewMemoryBus().Node("test")
+	t.Cleanup(func() { bus.Close() })
+	ws := auth.NewWSAuthenticator(manager, cfg.WebSocket, nil)
+	h := NewHandler(st, cfg, manager, ws, access.NewChecker(st.Members, st.Sanctions), nil, bus, nil, nil,
+		presence.NewService(st.Users, st.Friendships, bus))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/register", h.RegisterUser).Methods("POST")
+	router.HandleFunc("/users/login", h.LoginUser).Methods("POST")
+	router.HandleFunc("/users/refresh", h.RefreshToken).Methods("POST")
+	protected := router.PathPrefix("/auth").Subrouter()
+	protected.Use(manager.Middleware)
+	protected.HandleFunc("/logout", h.Logout).Methods("POST")
+	protected.HandleFunc("/chats", h.CreateChat).Methods("POST")
+	protected.HandleFunc("/chats", h.GetChat).Methods("GET")
+	protected.HandleFunc("/chats/{chatId}/read", h.MarkChatRead).Methods("POST")
+	protected.HandleFunc("/chats/{chatId}/messages/{id}", h.EditMessage).Methods("PATCH")
+	protected.HandleFunc("/chats/{chatId}/messages/{id}", h.DeleteMessage).Methods("DELETE")
+	protected.HandleFunc("/chats/{chatId}/messages/{id}/revisions", h.GetMessageRevisions).Methods("GET")
+	protected.HandleFunc("/chats/{chatId}/members", h.ListMembers).Methods("GET")
+	protected.HandleFunc("/chats/{chatId}/members", h.AddMember).Methods("POST")
+	protected.HandleFunc("/chats/{chatId}/members/{userId}/role", h.SetMemberRole).Methods("PUT")
+	protected.HandleFunc("/chats/{chatId}/members/{userId}/mute", h.MuteMember).Methods("POST")
+	protected.HandleFunc("/chats/{chatId}/members/{userId}/ban", h.BanMember).Methods("POST")
+	protected.HandleFunc("/chats/{chatId}/members/{userId}/kick", h.KickMember).Methods("POST")
+	protected.HandleFunc("/rooms/{roomId}", h.GetRoom).Methods("GET")
+	protected.HandleFunc("/rooms/{roomId}/invites", h.CreateInvite).Methods("POST")
+	protected.HandleFunc("/invites/{code}/accept", h.AcceptInvite).Methods("POST")
+	router.HandleFunc("/ws/chats/{chatId}", h.CreateConnectChat)
+
+	return &testServer{t: t, store: st, auth: manager, handler: router}
+}
+
+// user создает пользователя и выпускает ему access-токен
+func (s *testServer) user(name string) (store.User, string) {
+	s.t.Helper()
+	u, err := s.store.Users.Create(context.Background(), name, "hash")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	tokens, err := s.auth.Issue(context.Background(), u.ID, u.Name)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return u, tokens.AccessToken
+}
+
+// do выполняет запрос; body кодируется в JSON, если это не []byte
+func (s *testServer) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
@@ -0,0 +1,178 @@
+package routes
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"server/internal/store"
+	"strconv"
+	"strings"
+)
+
+// Размер окна истории по умолчанию и наибольший
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 100
+)
+
+// historyQuery — запрошенное окно истории чата; задана не больше чем одна граница
+type historyQuery struct {
+	BeforeID int
+	AfterID  int
+	AroundID int
+	Limit    int
+}
+
+// historyPage — окно истории от новых к старым
+type historyPage struct {
+	Messages []Message
+	// Older и Newer — за окном есть более старые и более новые сообщения
+	Older bool
+	Newer bool
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Курсор — base64 от "before:<id>" или "after:<id>"; клиенту он непрозрачен
+const (
+	cursorBefore = "before"
+	cursorAfter  = "after"
+)
+
+func encodeHistoryCursor(direction string, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(direction + ":" + strconv.Itoa(id)))
+}
+
+func decodeHistoryCursor(cursor string) (direction string, id int, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, errInvalidCursor
+	}
+	direction, value, ok := strings.Cut(string(raw), ":")
+	if !ok || (direction != cursorBefore && direction != cursorAfter) {
+		return "", 0, errInvalidCursor
+	}
+	id, err = strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return "", 0, errInvalidCursor
+	}
+	return direction, id, nil
+}
+
+// parseHistoryQuery разбирает limit и одну из границ: cursor, before_id,
+// after_id или around_id
+func parseHistoryQuery(values url.Values) (historyQuery, error) {
+	q := historyQuery{Limit: defaultHistoryLimit}
+	if v, err := strconv.Atoi(values.Get("limit")); err == nil && v > 0 {
+		q.Limit = min(v, maxHistoryLimit)
+	}
+
+	anchors := 0
+	for _, param := range []struct {
+		name string
+		dest *int
+	}{
+		{"before_id", &q.BeforeID},
+		{"after_id", &q.AfterID},
+		{"around_id", &q.AroundID},
+	} {
+		value := values.Get(param.name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return historyQuery{}, fmt.Errorf("invalid %s", param.name)
+		}
+		*param.dest = id
+		anchors++
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		direction, id, err := decodeHistoryCursor(cursor)
+		if err != nil {
+			return historyQuery{}, err
+		}
+		if direction == cursorBefore {
+			q.BeforeID = id
+		} else {
+			q.AfterID = id
+		}
+		anchors++
+	}
+
+	if anchors > 1 {
+		return historyQuery{}, errors.New("only one of cursor, before_id, after_id and around_id is allowed")
+	}
+	return q, nil
+}
+
+// loadHistory загружает окно истории одним запросом по индексу, а в
+// случае around_id — двумя. Окно around_id включает само сообщение и
+// делится между старыми и новыми примерно поровну.
+//
+// Есть ли сообщения дальше, узнаем, запрашивая на одно больше. За границей
+// запроса сообщения считаются существующими без проверки: граница обычно
+// взята из курсора, то есть из самой истории.
+func loadHistory(ctx context.Context, messages store.MessageStore, chatID string, q historyQuery) (historyPage, error) {
+	var page historyPage
+	switch {
+	case q.AroundID > 0:
+		half := q.Limit / 2
+		newer, err := messages.List(ctx, chatID, store.MessageQuery{AfterID: q.AroundID, Limit: half + 1})
+		if err != nil {
+			return historyPage{}, err
+		}
+		older, err := messages.List(ctx, chatID, store.MessageQuery{BeforeID: q.AroundID + 1, Limit: q.Limit - half + 1})
+		if err != nil {
+			return historyPage{}, err
+		}
+		if page.Newer = len(newer) > half; page.Newer {
+			newer = newer[1:]
+		}
+		if page.Older = len(older) > q.Limit-half; page.Older {
+			older = older[:len(older)-1]
+		}
+		page.Messages = append(newer, older...)
+
+	case q.AfterID > 0:
+		list, err := messages.List(ctx, chatID, store.MessageQuery{AfterID: q.AfterID, Limit: q.Limit + 1})
+		if err != nil {
+			return historyPage{}, err
+		}
+		if page.Newer = len(list) > q.Limit; page.Newer {
+			list = list[1:]
+		}
+		page.Messages = list
+		page.Older = true
+
+	default:
+		list, err := messages.List(ctx, chatID, store.MessageQuery{BeforeID: q.BeforeID, Limit: q.Limit + 1})
+		if err != nil {
+			return historyPage{}, err
+		}
+		if page.Older = len(list) > q.Limit; page.Older {
+			list = list[:len(list)-1]
+		}
+		page.Messages = list
+		page.Newer = q.BeforeID > 0
+	}
+	return page, nil
+}
+
+// cursors возвращает курсоры к более старому и более новому окну; пустой —
+// в эту сторону сообщений нет
+func (p historyPage) cursors() (next, prev string) {
+	if len(p.Messages) == 0 {
+		return "", ""
+	}
+	if p.Older {
+		next = encodeHistoryCursor(cursorBefore, p.Messages[len(p.Messages)-1].ID)
+	}
+	if p.Newer {
+		prev = encodeHistoryCursor(cursorAfter, p.Messages[0].ID)
+	}
+	return next, prev
+}
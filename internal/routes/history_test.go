@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"server/internal/store"
+	"server/internal/store/memory"
+)
+
+func TestParseHistoryQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    historyQuery
+		wantErr bool
+	}{
+		{"defaults", "", historyQuery{Limit: defaultHistoryLimit}, false},
+		{"limit", "limit=10", historyQuery{Limit: 10}, false},
+		{"limit capped", "limit=1000", historyQuery{Limit: maxHistoryLimit}, false},
+		{"bad limit ignored", "limit=-5", historyQuery{Limit: defaultHistoryLimit}, false},
+		{"before", "before_id=7", historyQuery{BeforeID: 7, Limit: defaultHistoryLimit}, false},
+		{"after", "after_id=7&limit=5", historyQuery{AfterID: 7, Limit: 5}, false},
+		{"around", "around_id=7", historyQuery{AroundID: 7, Limit: defaultHistoryLimit}, false},
+		{"before cursor", "cursor=" + encodeHistoryCursor(cursorBefore, 3), historyQuery{BeforeID: 3, Limit: defaultHistoryLimit}, false},
+		{"after cursor", "cursor=" + encodeHistoryCursor(cursorAfter, 3), historyQuery{AfterID: 3, Limit: defaultHistoryLimit}, false},
+		{"zero id", "before_id=0", historyQuery{}, true},
+		{"not a number", "after_id=x", historyQuery{}, true},
+		{"two anchors", "before_id=3&after_id=1", historyQuery{}, true},
+		{"cursor and anchor", "before_id=3&cursor=" + encodeHistoryCursor(cursorAfter, 1), historyQuery{}, true},
+		{"garbage cursor", "cursor=!!!", historyQuery{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseHistoryQuery(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseHistoryQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHistoryCursor(t *testing.T) {
+	for _, direction := range []string{cursorBefore, cursorAfter} {
+		gotDirection, gotID, err := decodeHistoryCursor(encodeHistoryCursor(direction, 42))
+		if err != nil || gotDirection != direction || gotID != 42 {
+			t.Errorf("round trip of %s:42 = %s:%d, %v", direction, gotDirection, gotID, err)
+		}
+	}
+
+	for _, raw := range []string{"", "before", "sideways:1", "before:0", "before:-1", "after:x"} {
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(raw))
+		if _, _, err := decodeHistoryCursor(cursor); err != errInvalidCursor {
+			t.Errorf("decodeHistoryCursor(%q): err = %v, want errInvalidCursor", raw, err)
+		}
+	}
+	if _, _, err := decodeHistoryCursor("not base64!"); err != errInvalidCursor {
+		t.Errorf("decodeHistoryCursor of non-base64: err = %v, want errInvalidCursor", err)
+	}
+}
+
+func messageIDs(messages []Message) []int {
+	result := []int{}
+	for _, msg := range messages {
+		result = append(result, msg.ID)
+	}
+	return result
+}
+
+func TestLoadHistory(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	if err := st.Chats.Create(ctx, store.Chat{ID: "c", Type: store.ChatTypeGroup}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := st.Messages.Create(ctx, store.Message{ChatID: "c", SenderID: "1", Text: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		q         historyQuery
+		want      []int
+		wantOlder bool
+		wantNewer bool
+	}{
+		{"first page", historyQuery{Limit: 3}, []int{10, 9, 8}, true, false},
+		{"whole history", historyQuery{Limit: 10}, []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, false, false},
+		{"before", historyQuery{BeforeID: 8, Limit: 3}, []int{7, 6, 5}, true, true},
+		{"before reaches start", historyQuery{BeforeID: 4, Limit: 3}, []int{3, 2, 1}, false, true},
+		{"after", historyQuery{AfterID: 3, Limit: 3}, []int{6, 5, 4}, true, true},
+		{"after reaches end", historyQuery{AfterID: 7, Limit: 3}, []int{10, 9, 8}, true, false},
+		{"after last", historyQuery{AfterID: 10, Limit: 3}, []int{}, true, false},
+		{"around", historyQuery{AroundID: 5, Limit: 4}, []int{7, 6, 5, 4}, true, true},
+		{"around start", historyQuery{AroundID: 1, Limit: 4}, []int{3, 2, 1}, false, true},
+		// Половины окна не перетекают друг в друга
+		{"around end", historyQuery{AroundID: 10, Limit: 4}, []int{10, 9}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := loadHistory(ctx, st.Messages, "c", tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messageIDs(page.Messages); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %v, want %v", got, tt.want)
+			}
+			if page.Older != tt.wantOlder || page.Newer != tt.wantNewer {
+				t.Errorf("older, newer = %v, %v; want %v, %v", page.Older, page.Newer, tt.wantOlder, tt.wantNewer)
+			}
+		})
+	}
+}
+
+// TestGetChatPaging проходит историю курсорами в обе стороны
+func TestGetChatPaging(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	u, token := s.user("alice")
+	if err := s.store.Chats.Create(ctx, store.Chat{ID: "c", Type: store.ChatTypeGroup}, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if _, err := s.store.Messages.Create(ctx, store.Message{ChatID: "c", SenderID: u.ID, Text: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(query string) ChatHistoryResponse {
+		t.Helper()
+		rec := s.do("GET", "/auth/chats?chat_id=c&limit=3"+query, token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d, body %q", query, rec.Code, rec.Body.String())
+		}
+		return decode[ChatHistoryResponse](t, rec)
+	}
+
+	var pages [][]int
+	page := get("")
+	if page.Total != 7 {
+		t.Fatalf("first page total = %d, want 7", page.Total)
+	}
+	if page.PrevCursor != "" {
+		t.Errorf("first page has prev_cursor %q", page.PrevCursor)
+	}
+	pages = append(pages, messageIDs(page.Messages))
+	for page.NextCursor != "" {
+		page = get("&cursor=" + page.NextCursor)
+		pages = append(pages, messageIDs(page.Messages))
+	}
+	want := [][]int{{7, 6, 5}, {4, 3, 2}, {1}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("pages going back = %v, want %v", pages, want)
+	}
+
+	// Обратно к новым сообщениям от самого старого окна
+	pages = nil
+	for page.PrevCursor != "" {
+		page = get("&cursor=" + page.PrevCursor)
+		pages = append(pages, messageIDs(page.Messages))
+	}
+	want = [][]int{{4, 3, 2}, {7, 6, 5}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("pages going forward = %v, want %v", pages, want)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"bad cursor", "&cursor=zzz", http.StatusBadRequest},
+		{"two anchors", "&before_id=2&after_id=1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.do("GET", "/auth/chats?chat_id=c"+tt.query, token, nil); rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	_, strangerToken := s.user("stranger")
+	if rec := s.do("GET", "/auth/chats?chat_id=c", strangerToken, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("history for a stranger: status %d, want 403", rec.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"server/internal/ice"
+)
+
+// ICEServersResponse — ICE-серверы для RTCPeerConnection
+type ICEServersResponse struct {
+	ICEServers []ice.Server `json:"iceServers"`
+	// TTL — сколько секунд действует TURN-учетка
+	TTL int `json:"ttl"`
+}
+
+// @Summary Получить STUN/TURN-серверы
+// @Description Список для RTCConfiguration.iceServers. TURN-учетка временная и привязана к пользователю из токена; ее нужно запросить заново до истечения ttl.
+// @Tags ice
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} routes.ICEServersResponse
+// @Router /auth/ice-servers [get]
+func (h *Handler) GetICEServers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		log.Printf("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	servers := h.ice.Servers(userID)
+	if servers == nil {
+		servers = []ice.Server{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(ICEServersResponse{
+		ICEServers: servers,
+		TTL:        int(h.ice.TTL().Seconds()),
+	}); err != nil {
+		log.Printf("Error encoding ICE servers: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
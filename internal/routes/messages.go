@@ -0,0 +1,219 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/backplane"
+	"server/internal/store"
+	"strconv"
+	"time"
+)
+
+// EditMessageRequest — новый текст сообщения
+type EditMessageRequest struct {
+	Text string `json:"text"`
+}
+
+// authorizeMessageChange загружает сообщение, которое пользователь правит
+// или удаляет. Автору нужно право p, остальным — право модерации чата.
+func authorizeMessageChange(ctx context.Context, checker *access.Checker, messages store.MessageStore,
+	chatID, userID string, id int, p access.Permission) (store.Message, error) {
+	msg, err := messages.Get(ctx, chatID, id)
+	if err != nil {
+		return store.Message{}, err
+	}
+	if msg.DeletedAt != nil {
+		return store.Message{}, store.ErrNotFound
+	}
+
+	own := msg.SenderID == userID
+	if !own {
+		p = access.PermModerate
+	}
+	_, err = checker.Require(ctx, store.ScopeChat, chatID, userID, p)
+	if err != nil && !own && errors.Is(err, auth.ErrForbidden) {
+		return store.Message{}, fmt.Errorf("%w: only the sender or a moderator can change this message", auth.ErrForbidden)
+	}
+	if err != nil {
+		return store.Message{}, err
+	}
+	return msg, nil
+}
+
+// editMessage правит сообщение от имени пользователя
+func editMessage(ctx context.Context, checker *access.Checker, messages store.MessageStore,
+	chatID, userID string, id int, text string) (store.Message, error) {
+	if _, err := authorizeMessageChange(ctx, checker, messages, chatID, userID, id, access.PermSendMessage); err != nil {
+		return store.Message{}, err
+	}
+	return messages.Edit(ctx, chatID, id, text, userID, time.Now())
+}
+
+// deleteMessage удаляет сообщение от имени пользователя
+func deleteMessage(ctx context.Context, checker *access.Checker, messages store.MessageStore,
+	chatID, userID string, id int) (store.Message, error) {
+	if _, err := authorizeMessageChange(ctx, checker, messages, chatID, userID, id, access.PermJoin); err != nil {
+		return store.Message{}, err
+	}
+	return messages.Delete(ctx, chatID, id, time.Now())
+}
+
+// messageChangeEvent — кадр edit или delete об измененном сообщении от имени
+// того, кто его изменил
+func messageChangeEvent(msg store.Message, userID, name string) *ChatEnvelope {
+	var ev *ChatEnvelope
+	if msg.DeletedAt != nil {
+		ev = newChatEvent(ChatEventDelete, msg.ChatID, ChatMessageRef{MessageID: msg.ID})
+		ev.Timestamp = msg.DeletedAt
+	} else {
+		ev = newChatEvent(ChatEventEdit, msg.ChatID, ChatMessagePayload{MessageID: msg.ID, Text: msg.Text})
+		ev.Timestamp = msg.EditedAt
+	}
+	ev.SenderID = userID
+	ev.Name = name
+	return ev
+}
+
+// broadcastToChat рассылает кадр подключениям чата на всех узлах
+func broadcastToChat(bus backplane.Backplane, chatID string, ev *ChatEnvelope) {
+	hubMutex.RLock()
+	hub, exists := chatHubs[chatID]
+	hubMutex.RUnlock()
+
+	if exists {
+		hub.remote <- &chatEvent{Kind: chatEventBroadcast, Event: ev}
+	}
+	publishChatEvent(bus, chatID, &chatEvent{Kind: chatEventBroadcast, Event: ev})
+}
+
+// messageParams разбирает chatId и id сообщения из пути
+func messageParams(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return "", 0, false
+	}
+	return vars["chatId"], id, true
+}
+
+// @Summary Изменить сообщение
+// @Description Править может автор или модератор чата. Прежний текст сохраняется в истории правок, подключенные участники получают кадр edit.
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path string true "ID чата"
+// @Param id path int true "ID сообщения"
+// @Param data body routes.EditMessageRequest true "Новый текст"
+// @Success 200 {object} store.Message
+// @Failure 413 {string} string "Текст длиннее предела кадра сокета"
+// @Router /auth/chats/{chatId}/messages/{id} [patch]
+func (h *Handler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, id, ok := messageParams(w, r)
+	if !ok {
+		return
+	}
+
+	// Тот же предел, что и у кадра правки через сокет чата
+	r.Body = http.MaxBytesReader(w, r.Body, h.Config.WebSocket.MaxMessageSize)
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == "" {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Message is too long", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := editMessage(r.Context(), h.access, h.Store.Messages, chatID, claims.UserID, id, req.Text)
+	if err != nil {
+		writeAccessError(w, "EditMessage", err)
+		return
+	}
+	broadcastToChat(h.bus, chatID, messageChangeEvent(msg, claims.UserID, claims.UserName))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// @Summary Удалить сообщение
+// @Description Удалять может автор или модератор чата. Сообщение остается в истории отметкой без текста, история правок стирается; подключенные участники получают кадр delete.
+// @Tags chats
+// @Security BearerAuth
+// @Param chatId path string true "ID чата"
+// @Param id path int true "ID сообщения"
+// @Success 204
+// @Router /auth/chats/{chatId}/messages/{id} [delete]
+func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, id, ok := messageParams(w, r)
+	if !ok {
+		return
+	}
+
+	msg, err := deleteMessage(r.Context(), h.access, h.Store.Messages, chatID, claims.UserID, id)
+	if err != nil {
+		writeAccessError(w, "DeleteMessage", err)
+		return
+	}
+	broadcastToChat(h.bus, chatID, messageChangeEvent(msg, claims.UserID, claims.UserName))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary История правок сообщения
+// @Description Прежние тексты от старых к новым; доступна участникам чата
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path string true "ID чата"
+// @Param id path int true "ID сообщения"
+// @Success 200 {array} store.MessageRevision
+// @Router /auth/chats/{chatId}/messages/{id}/revisions [get]
+func (h *Handler) GetMessageRevisions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID, id, ok := messageParams(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.userHasAccessToChat(r.Context(), userID, chatID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	revisions, err := h.Store.Messages.Revisions(r.Context(), chatID, id)
+	if err != nil {
+		writeAccessError(w, "GetMessageRevisions", err)
+		return
+	}
+	if revisions == nil {
+		revisions = []store.MessageRevision{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
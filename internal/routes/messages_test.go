@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"server/internal/store"
+)
+
+func TestEditMessage(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	author, authorToken := s.user("author")
+	reader, readerToken := s.user("reader")
+	_, strangerToken := s.user("stranger")
+	if err := s.store.Chats.Create(ctx, store.Chat{ID: "c", Type: store.ChatTypeGroup}, author.ID, reader.ID); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := s.store.Messages.Create(ctx, store.Message{ChatID: "c", SenderID: author.ID, Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		path  string
+		body  any
+		want  int
+	}{
+		{"author", authorToken, "/auth/chats/c/messages/1", EditMessageRequest{Text: "hello!"}, http.StatusOK},
+		{"other participant", readerToken, "/auth/chats/c/messages/1", EditMessageRequest{Text: "mine"}, http.StatusForbidden},
+		{"stranger", strangerToken, "/auth/chats/c/messages/1", EditMessageRequest{Text: "mine"}, http.StatusForbidden},
+		{"empty text", authorToken, "/auth/chats/c/messages/1", EditMessageRequest{}, http.StatusBadRequest},
+		{"bad id", authorToken, "/auth/chats/c/messages/x", EditMessageRequest{Text: "a"}, http.StatusBadRequest},
+		{"missing message", authorToken, "/auth/chats/c/messages/99", EditMessageRequest{Text: "a"}, http.StatusNotFound},
+		{"longer than a socket frame", authorToken, "/auth/chats/c/messages/1", EditMessageRequest{Text: strings.Repeat("a", 600)}, http.StatusRequestEntityTooLarge},
+		{"no token", "", "/auth/chats/c/messages/1", EditMessageRequest{Text: "a"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do("PATCH", tt.path, tt.token, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %q", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	rec := s.do("GET", "/auth/chats/c/messages/1/revisions", readerToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revisions: status %d", rec.Code)
+	}
+	revisions := decode[[]store.MessageRevision](t, rec)
+	if len(revisions) != 1 || revisions[0].Text != msg.Text {
+		t.Errorf("revisions = %+v, want the original text", revisions)
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	author, authorToken := s.user("author")
+	reader, readerToken := s.user("reader")
+	if err := s.store.Chats.Create(ctx, store.Chat{ID: "c", Type: store.ChatTypeGroup}, author.ID, reader.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.store.Messages.Create(ctx, store.Message{ChatID: "c", SenderID: author.ID, Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := s.do("DELETE", "/auth/chats/c/messages/1", readerToken, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("delete by another participant: status %d, want 403", rec.Code)
+	}
+	if rec := s.do("DELETE", "/auth/chats/c/messages/1", authorToken, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete by the author: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if rec := s.do("DELETE", "/auth/chats/c/messages/1", authorToken, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete: status %d, want 404", rec.Code)
+	}
+	if rec := s.do("PATCH", "/auth/chats/c/messages/1", authorToken, EditMessageRequest{Text: "back"}); rec.Code != http.StatusNotFound {
+		t.Errorf("edit of a deleted message: status %d, want 404", rec.Code)
+	}
+}
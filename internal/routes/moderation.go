@@ -0,0 +1,378 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/store"
+	"time"
+)
+
+type RoleRequest struct {
+	Role store.Role `json:"role"`
+}
+
+type SanctionRequest struct {
+	// Длительность в секундах; 0 — бессрочно
+	Duration int    `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+type AddMemberRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// resource определяет комнату или чат по переменным маршрута
+func resource(r *http.Request) (store.Scope, string) {
+	vars := mux.Vars(r)
+	if roomID, ok := vars["roomId"]; ok {
+		return store.ScopeRoom, roomID
+	}
+	return store.ScopeChat, vars["chatId"]
+}
+
+// authorizeResource проверяет, что ресурс существует и у пользователя есть право.
+// При ошибке ответ уже отправлен.
+func (h *Handler) authorizeResource(w http.ResponseWriter, r *http.Request, p access.Permission) (store.Scope, string, access.Access, bool) {
+	scope, resourceID := resource(r)
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return scope, resourceID, access.Access{}, false
+	}
+
+	var err error
+	if scope == store.ScopeRoom {
+		_, err = h.Store.Rooms.Get(r.Context(), resourceID)
+	} else {
+		var chat store.Chat
+		chat, err = h.Store.Chats.Get(r.Context(), resourceID)
+		if err == nil && chat.Type != TypeChatGroup && p != access.PermJoin {
+			http.Error(w, "Only group chats have roles", http.StatusBadRequest)
+			return scope, resourceID, access.Access{}, false
+		}
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return scope, resourceID, access.Access{}, false
+	}
+	if err != nil {
+		log.Printf("Error loading %s %s: %v", scope, resourceID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return scope, resourceID, access.Access{}, false
+	}
+
+	a, err := h.access.Require(r.Context(), scope, resourceID, userID, p)
+	if err != nil {
+		if errors.Is(err, auth.ErrForbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else {
+			log.Printf("Error checking %s access: %v", scope, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return scope, resourceID, access.Access{}, false
+	}
+
+	return scope, resourceID, a, true
+}
+
+// moderationTarget проверяет, что вызывающий может применить санкцию к пользователю из пути
+func (h *Handler) moderationTarget(w http.ResponseWriter, r *http.Request) (store.Scope, string, string, bool) {
+	scope, resourceID, actor, ok := h.authorizeResource(w, r, access.PermModerate)
+	if !ok {
+		return scope, resourceID, "", false
+	}
+
+	targetID := mux.Vars(r)["userId"]
+	target, err := h.access.Resolve(r.Context(), scope, resourceID, targetID)
+	if err != nil {
+		log.Printf("Error resolving %s member: %v", scope, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return scope, resourceID, "", false
+	}
+
+	role := target.Role
+	if role == "" {
+		role = store.RoleGuest
+	}
+	if !access.CanModerate(actor.Role, role) {
+		http.Error(w, "Cannot moderate a user with the same or higher role", http.StatusForbidden)
+		return scope, resourceID, "", false
+	}
+
+	return scope, resourceID, targetID, true
+}
+
+// disconnect отключает пользователя от активной комнаты или чата
+func (h *Handler) disconnect(scope store.Scope, resourceID, userID, reason string) {
+	if scope == store.ScopeRoom {
+		h.rooms.Disconnect(resourceID, userID, reason)
+		return
+	}
+	disconnectFromChat(h.bus, resourceID, userID, reason)
+}
+
+// @Summary Участники комнаты или чата с ролями
+// @Tags moderation
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Success 200 {array} store.Member
+// @Router /auth/rooms/{roomId}/members [get]
+// @Router /auth/chats/{chatId}/members [get]
+func (h *Handler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	scope, resourceID, _, ok := h.authorizeResource(w, r, access.PermJoin)
+	if !ok {
+		return
+	}
+
+	members, err := h.Store.Members.List(r.Context(), scope, resourceID)
+	if err != nil {
+		log.Printf("Error listing %s members: %v", scope, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if members == nil {
+		members = []store.Member{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// @Summary Добавить участника в комнату или групповой чат
+// @Tags moderation
+// @Accept json
+// @Security BearerAuth
+// @Param request body AddMemberRequest true "Пользователь"
+// @Success 204
+// @Router /auth/rooms/{roomId}/members [post]
+// @Router /auth/chats/{chatId}/members [post]
+func (h *Handler) AddMember(w http.ResponseWriter, r *http.Request) {
+	scope, resourceID, _, ok := h.authorizeResource(w, r, access.PermInvite)
+	if !ok {
+		return
+	}
+
+	var req AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.Store.Users.GetByID(r.Context(), req.UserID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error loading user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Store.Members.Add(r.Context(), scope, resourceID, req.UserID, store.RoleMember); err != nil {
+		log.Printf("Error adding %s member: %v", scope, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Назначить роль участнику
+// @Description owner назначает moderator, member и guest; moderator — member и guest участникам ниже себя
+// @Tags moderation
+// @Accept json
+// @Security BearerAuth
+// @Param userId path string true "ID пользователя"
+// @Param request body RoleRequest true "Новая роль"
+// @Success 204
+// @Router /auth/rooms/{roomId}/members/{userId}/role [put]
+// @Router /auth/chats/{chatId}/members/{userId}/role [put]
+func (h *Handler) SetMemberRole(w http.ResponseWriter, r *http.Request) {
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !access.ValidRole(req.Role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+	h.assignRole(w, r, req.Role)
+}
+
+// @Summary Снять роль (участник становится member)
+// @Tags moderation
+// @Security BearerAuth
+// @Param userId path string true "ID пользователя"
+// @Success 204
+// @Router /auth/rooms/{roomId}/members/{userId}/role [delete]
+// @Router /auth/chats/{chatId}/members/{userId}/role [delete]
+func (h *Handler) RevokeMemberRole(w http.ResponseWriter, r *http.Request) {
+	h.assignRole(w, r, store.RoleMember)
+}
+
+func (h *Handler) assignRole(w http.ResponseWriter, r *http.Request, role store.Role) {
+	scope, resourceID, actor, ok := h.authorizeResource(w, r, access.PermManageRoles)
+	if !ok {
+		return
+	}
+
+	targetID := mux.Vars(r)["userId"]
+	member, err := h.Store.Members.Get(r.Context(), scope, resourceID, targetID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "User is not a member", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading %s member: %v", scope, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !access.CanAssign(actor.Role, member.Role, role) {
+		http.Error(w, "Cannot assign this role", http.StatusForbidden)
+		return
+	}
+
+	if err := h.Store.Members.SetRole(r.Context(), scope, resourceID, targetID, role); err != nil {
+		log.Printf("Error setting %s role: %v", scope, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Запретить участнику писать в чат
+// @Tags moderation
+// @Accept json
+// @Security BearerAuth
+// @Param userId path string true "ID пользователя"
+// @Param request body SanctionRequest false "Длительность и причина"
+// @Success 204
+// @Router /auth/rooms/{roomId}/members/{userId}/mute [post]
+// @Router /auth/chats/{chatId}/members/{userId}/mute [post]
+func (h *Handler) MuteMember(w http.ResponseWriter, r *http.Request) {
+	h.sanction(w, r, store.SanctionMute)
+}
+
+// @Summary Заблокировать участника и отключить его
+// @Tags moderation
+// @Accept json
+// @Security BearerAuth
+// @Param userId path string true "ID пользователя"
+// @Param request body SanctionRequest false "Длительность и причина"
+// @Success 204
+// @Router /auth/rooms/{roomId}/members/{userId}/ban [post]
+// @Router /auth/chats/{chatId}/members/{userId}/ban [post]
+func (h *Handler) BanMember(w http.ResponseWriter, r *http.Request) {
+	h.sanction(w, r, store.SanctionBan)
+}
+
+// @Summary Снять запрет писать
+// @Tags moderation
+// @Security BearerAuth
+// @Param userId path string true "ID пользователя"
+// @Success 204
+// @Router /auth/rooms/{roomId}/members/{userId}/mute [delete]
+// @Router /auth/chats/{chatId}/members/{userId}/mute [delete]
+func (h *Handler) UnmuteMember(w http.ResponseWriter, r *http.Request) {
+	h.liftSanction(w, r, store.SanctionMute)
+}
+
+// @Summary Снять блокировку
+// @Tags moderation
+// @Security BearerAuth
+// @Param userId path string true "ID пользователя"
+// @Success 204
+// @Router /auth/rooms/{roomId}/members/{userId}/ban [delete]
+// @Router /auth/chats/{chatId}/members/{userId}/ban [delete]
+func (h *Handler) UnbanMember(w http.ResponseWriter, r *http.Request) {
+	h.liftSanction(w, r, store.SanctionBan)
+}
+
+// @Summary Исключить участника и отключить его от активной сессии
+// @Tags moderation
+// @Security BearerAuth
+// @Param userId path string true "ID пользователя"
+// @Success 204
+// @Router /auth/rooms/{roomId}/members/{userId}/kick [post]
+// @Router /auth/chats/{chatId}/members/{userId}/kick [post]
+func (h *Handler) KickMember(w http.ResponseWriter, r *http.Request) {
+	scope, resourceID, targetID, ok := h.moderationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.Store.Members.Remove(r.Context(), scope, resourceID, targetID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Error removing %s member: %v", scope, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.disconnect(scope, resourceID, targetID, "kicked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) sanction(w http.ResponseWriter, r *http.Request, kind store.SanctionKind) {
+	var req SanctionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Duration < 0 {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	scope, resourceID, targetID, ok := h.moderationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	sn := store.Sanction{
+		Scope:      scope,
+		ResourceID: resourceID,
+		UserID:     targetID,
+		Kind:       kind,
+		IssuedBy:   r.Context().Value("user_id").(string),
+		Reason:     req.Reason,
+	}
+	if req.Duration > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.Duration) * time.Second)
+		sn.ExpiresAt = &expiresAt
+	}
+
+	if err := h.Store.Sanctions.Put(r.Context(), sn); err != nil {
+		log.Printf("Error saving %s sanction: %v", kind, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if kind == store.SanctionBan {
+		h.disconnect(scope, resourceID, targetID, "banned")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) liftSanction(w http.ResponseWriter, r *http.Request, kind store.SanctionKind) {
+	scope, resourceID, targetID, ok := h.moderationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.Store.Sanctions.Lift(r.Context(), scope, resourceID, targetID, kind)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Sanction not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error lifting %s sanction: %v", kind, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"server/internal/store"
+)
+
+func TestChatModeration(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	owner, ownerToken := s.user("owner")
+	moderator, moderatorToken := s.user("moderator")
+	member, memberToken := s.user("member")
+	newcomer, _ := s.user("newcomer")
+	_, strangerToken := s.user("stranger")
+	if err := s.store.Chats.Create(ctx, store.Chat{ID: "c", Type: store.ChatTypeGroup, Name: "group"}, owner.ID, moderator.ID, member.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   any
+		want   int
+	}{
+		{"stranger cannot list", "GET", "/auth/chats/c/members", strangerToken, nil, http.StatusForbidden},
+		{"missing chat", "GET", "/auth/chats/missing/members", ownerToken, nil, http.StatusNotFound},
+		{"member cannot promote", "PUT", "/auth/chats/c/members/" + moderator.ID + "/role", memberToken, RoleRequest{Role: store.RoleModerator}, http.StatusForbidden},
+		{"owner role is not assignable", "PUT", "/auth/chats/c/members/" + moderator.ID + "/role", ownerToken, RoleRequest{Role: store.RoleOwner}, http.StatusForbidden},
+		{"unknown role", "PUT", "/auth/chats/c/members/" + moderator.ID + "/role", ownerToken, RoleRequest{Role: "admin"}, http.StatusBadRequest},
+		{"owner promotes", "PUT", "/auth/chats/c/members/" + moderator.ID + "/role", ownerToken, RoleRequest{Role: store.RoleModerator}, http.StatusNoContent},
+		{"moderator cannot mute owner", "POST", "/auth/chats/c/members/" + owner.ID + "/mute", moderatorToken, nil, http.StatusForbidden},
+		{"moderator mutes member", "POST", "/auth/chats/c/members/" + member.ID + "/mute", moderatorToken, SanctionRequest{Duration: 60, Reason: "spam"}, http.StatusNoContent},
+		{"muted member still invites", "POST", "/auth/chats/c/members", memberToken, AddMemberRequest{UserID: newcomer.ID}, http.StatusNoContent},
+		{"moderator bans member", "POST", "/auth/chats/c/members/" + member.ID + "/ban", moderatorToken, nil, http.StatusNoContent},
+		{"banned member cannot list", "GET", "/auth/chats/c/members", memberToken, nil, http.StatusForbidden},
+		{"moderator kicks newcomer", "POST", "/auth/chats/c/members/" + newcomer.ID + "/kick", moderatorToken, nil, http.StatusNoContent},
+	}
+	for _, step := range steps {
+		if rec := s.do(step.method, step.path, step.token, step.body); rec.Code != step.want {
+			t.Fatalf("%s: status %d, want %d, body %q", step.name, rec.Code, step.want, rec.Body.String())
+		}
+	}
+
+	rec := s.do("GET", "/auth/chats/c/members", ownerToken, nil)
+	roles := map[string]store.Role{}
+	for _, m := range decode[[]store.Member](t, rec) {
+		roles[m.UserID] = m.Role
+	}
+	want := map[string]store.Role{owner.ID: store.RoleOwner, moderator.ID: store.RoleModerator, member.ID: store.RoleMember}
+	if len(roles) != len(want) {
+		t.Errorf("members = %v, want %v", roles, want)
+	}
+	for userID, role := range want {
+		if roles[userID] != role {
+			t.Errorf("role of %s = %q, want %q", userID, roles[userID], role)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"server/internal/presence"
+	"server/internal/store"
+	"strings"
+	"time"
+)
+
+// SetPresenceRequest — ручной статус: away, dnd или online, чтобы его сбросить
+type SetPresenceRequest struct {
+	Status store.PresenceStatus `json:"status"`
+}
+
+// PresenceFrame — кадр сокета присутствия.
+//
+// Сервер отправляет snapshot с присутствием друзей сразу после подключения,
+// затем presence при каждом изменении у друга или у самого пользователя
+// (например, статус сменили с другого устройства) и error на неверный кадр.
+// Клиент отправляет status, чтобы сменить ручной статус.
+type PresenceFrame struct {
+	Type     string               `json:"type"`
+	Presence *presence.Presence   `json:"presence,omitempty"`
+	Status   store.PresenceStatus `json:"status,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// Максимальный размер кадра сокета присутствия — в нем только смена статуса
+const maxPresenceFrameSize = 512
+
+// Наибольшее число пользователей в одном запросе присутствия
+const maxPresenceIDs = 100
+
+// @Summary Получить присутствие пользователей
+// @Description Статус (online, away, dnd, offline) и время последнего визита. ID передаются повторяющимся параметром или через запятую, не больше 100. Видно присутствие только свое, друзей и тех, с кем есть общий чат; остальные ID, как и несуществующие, пропускаются.
+// @Tags presence
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query string true "ID пользователей"
+// @Success 200 {array} presence.Presence
+// @Router /auth/presence [get]
+func (h *Handler) GetPresence(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		log.Printf("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var ids []string
+	for _, param := range r.URL.Query()["user_id"] {
+		for _, id := range strings.Split(param, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, "Missing user_id parameter", http.StatusBadRequest)
+		return
+	}
+	if len(ids) > maxPresenceIDs {
+		http.Error(w, fmt.Sprintf("At most %d user IDs per request", maxPresenceIDs), http.StatusBadRequest)
+		return
+	}
+
+	ids, err := h.visiblePresence(r.Context(), userID, ids)
+	if err != nil {
+		log.Printf("Error checking presence visibility: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	list, err := h.presence.Get(r.Context(), ids)
+	if err != nil {
+		log.Printf("Error loading presence: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		log.Printf("Error encoding presence: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// visiblePresence оставляет из ids тех, чье присутствие видно пользователю:
+// его самого, друзей и собеседников по общим чатам
+func (h *Handler) visiblePresence(ctx context.Context, userID string, ids []string) ([]string, error) {
+	visible := map[string]bool{userID: true}
+
+	friends, err := h.Store.Friendships.ListAccepted(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range friends {
+		visible[f.ID] = true
+	}
+
+	var rest []string
+	for _, id := range ids {
+		if !visible[id] {
+			rest = append(rest, id)
+		}
+	}
+	if len(rest) > 0 {
+		shared, err := h.Store.Chats.SharingChats(ctx, userID, rest)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range shared {
+			visible[id] = true
+		}
+	}
+
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if visible[id] {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// @Summary Сменить свой статус
+// @Description Ручной статус действует, пока пользователь в сети; без подключений он offline. Друзья получают изменение через сокет присутствия.
+// @Tags presence
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param data body routes.SetPresenceRequest true "Статус"
+// @Success 200 {object} presence.Presence
+// @Router /auth/presence [put]
+func (h *Handler) SetPresence(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		log.Printf("User ID not found in context")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req SetPresenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.presence.SetStatus(r.Context(), userID, req.Status); err != nil {
+		if errors.Is(err, presence.ErrInvalidStatus) {
+			http.Error(w, "Status must be online, away or dnd", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error setting presence status: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	list, err := h.presence.Get(r.Context(), []string{userID})
+	if err != nil || len(list) == 0 {
+		log.Printf("Error loading presence: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list[0]); err != nil {
+		log.Printf("Error encoding presence: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ConnectPresence — сокет присутствия: держит пользователя в сети и
+// присылает изменения присутствия друзей (см. PresenceFrame)
+func (h *Handler) ConnectPresence(w http.ResponseWriter, r *http.Request) {
+	conn, identity, err := h.ws.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Presence connection rejected: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	release := h.presence.Connect(identity.UserID)
+	defer release()
+
+	watcher, snapshot, err := h.presence.Watch(r.Context(), identity.UserID)
+	if err != nil {
+		log.Printf("Error watching presence of %s: %v", identity.UserID, err)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "presence unavailable"),
+			time.Now().Add(time.Second))
+		return
+	}
+	defer h.presence.Unwatch(watcher)
+
+	// Ответы на кадры клиента пишет та же горутина, что и обновления
+	replies := make(chan PresenceFrame, 1)
+	closed := make(chan struct{})
+	go h.readPresence(conn, identity.UserID, replies, closed)
+
+	cfg := h.Config.WebSocket
+	write := func(frame PresenceFrame) error {
+		conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
+		return conn.WriteJSON(frame)
+	}
+
+	// Список snapshot передается и пустым, поэтому кадр собирается отдельно
+	if snapshot == nil {
+		snapshot = []presence.Presence{}
+	}
+	conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
+	if err := conn.WriteJSON(struct {
+		Type     string              `json:"type"`
+		Snapshot []presence.Presence `json:"snapshot"`
+	}{"snapshot", snapshot}); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.PingPeriod())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case p, ok := <-watcher.Updates():
+			if !ok {
+				return
+			}
+			if err := write(PresenceFrame{Type: "presence", Presence: &p}); err != nil {
+				return
+			}
+		case frame := <-replies:
+			if err := write(frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// readPresence читает кадры сокета присутствия до его закрытия
+func (h *Handler) readPresence(conn *websocket.Conn, userID string, replies chan<- PresenceFrame, closed chan<- struct{}) {
+	defer close(closed)
+
+	cfg := h.Config.WebSocket
+	conn.SetReadLimit(maxPresenceFrameSize)
+	conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+		return nil
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+
+		var frame PresenceFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			frame = PresenceFrame{Type: "invalid"}
+		}
+
+		var reply PresenceFrame
+		switch frame.Type {
+		case "status":
+			err := h.presence.SetStatus(context.Background(), userID, frame.Status)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, presence.ErrInvalidStatus) {
+				log.Printf("Error setting presence status: %v", err)
+			}
+			reply = PresenceFrame{Type: "error", Error: err.Error()}
+		case "invalid":
+			reply = PresenceFrame{Type: "error", Error: "invalid frame"}
+		default:
+			reply = PresenceFrame{Type: "error", Error: "unknown frame type"}
+		}
+
+		select {
+		case replies <- reply:
+		default:
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package routes
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"server/internal/store"
 )
 
 // @Summary Получить имя, id пользователя
@@ -20,13 +21,10 @@ func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `SELECT id, name FROM users WHERE id = $1`
-	row := h.DB.QueryRow(query, userID)
-
-	var user User
-	if err := row.Scan(&user.ID, &user.Name); err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("User not found: %d", userID)
+	found, err := h.Store.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			log.Printf("User not found: %s", userID)
 			http.Error(w, "User not found", http.StatusNotFound)
 			return
 		}
@@ -34,6 +32,7 @@ func (h *Handler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	user := User{ID: found.ID, Name: found.Name}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(user); err != nil {
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"server/internal/signaling"
+
+	"github.com/gorilla/mux"
+)
+
+// ProtocolResponse — версии протокола сигнализации комнаты
+type ProtocolResponse struct {
+	Versions []int `json:"versions"`
+	Latest   int   `json:"latest"`
+}
+
+// @Summary Версии протокола сигнализации
+// @Description Версия выбирается параметром v при подключении к /auth/ws/{roomId}; без него — версия 1
+// @Tags protocol
+// @Produce json
+// @Success 200 {object} routes.ProtocolResponse
+// @Router /protocol [get]
+func (h *Handler) GetProtocol(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ProtocolResponse{
+		Versions: signaling.ProtocolVersions,
+		Latest:   signaling.ProtocolLatest,
+	})
+}
+
+// @Summary JSON Schema протокола сигнализации
+// @Description Схемы кадров версии 2: client — кадры клиента, server — кадры сервера
+// @Tags protocol
+// @Produce json
+// @Param name path string true "client или server"
+// @Success 200 {object} object
+// @Failure 404 {string} string "Неизвестная схема"
+// @Router /protocol/schema/{name} [get]
+func (h *Handler) GetProtocolSchema(w http.ResponseWriter, r *http.Request) {
+	schema, ok := signaling.Schema(mux.Vars(r)["name"])
+	if !ok {
+		http.Error(w, "Schema not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(schema)
+}
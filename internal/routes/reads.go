@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"server/internal/auth"
+	"server/internal/store"
+	"sort"
+)
+
+// MarkReadRequest — ID последнего прочитанного сообщения
+type MarkReadRequest struct {
+	MessageID int `json:"message_id"`
+}
+
+// ReadStateResponse — курсор прочтения участника и число непрочитанных
+type ReadStateResponse struct {
+	ChatID            string `json:"chat_id"`
+	LastReadMessageID int    `json:"last_read_message_id"`
+	Unread            int    `json:"unread"`
+}
+
+// @Summary Отметить чат прочитанным
+// @Description Сдвигает курсор прочтения до message_id (назад не сдвигается). Подключенные участники получают кадр read.
+// @Tags chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path string true "ID чата"
+// @Param data body routes.MarkReadRequest true "Последнее прочитанное сообщение"
+// @Success 200 {object} routes.ReadStateResponse
+// @Router /auth/chats/{chatId}/read [post]
+func (h *Handler) MarkChatRead(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chatID := mux.Vars(r)["chatId"]
+
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID <= 0 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !h.userHasAccessToChat(r.Context(), claims.UserID, chatID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	cursor, moved, err := h.Store.Chats.MarkRead(r.Context(), chatID, claims.UserID, req.MessageID)
+	if err != nil {
+		writeAccessError(w, "MarkChatRead", err)
+		return
+	}
+	if moved {
+		ev := newChatEvent(ChatEventRead, chatID, ChatMessageRef{MessageID: cursor})
+		ev.SenderID = claims.UserID
+		ev.Name = claims.UserName
+		broadcastToChat(h.bus, chatID, ev)
+	}
+
+	unread, err := h.Store.Chats.UnreadCount(r.Context(), chatID, claims.UserID)
+	if err != nil {
+		writeAccessError(w, "MarkChatRead", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReadStateResponse{
+		ChatID:            chatID,
+		LastReadMessageID: cursor,
+		Unread:            unread,
+	})
+}
+
+// @Summary Непрочитанные сообщения по чатам
+// @Description Сколько чужих сообщений после курсора прочтения в каждом чате пользователя: chat_id -> количество
+// @Tags chats
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int
+// @Router /auth/chats/unread [get]
+func (h *Handler) GetUnreadCounts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	counts, err := h.Store.Chats.UnreadCounts(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error counting unread messages: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
+
+// fillSeenBy отмечает в сообщениях группового чата, кто из участников их
+// прочитал; удаленные сообщения пропускаются
+func fillSeenBy(messages []store.Message, cursors map[string]int) {
+	users := make([]string, 0, len(cursors))
+	for userID := range cursors {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+
+	for i := range messages {
+		msg := &messages[i]
+		if msg.DeletedAt != nil {
+			continue
+		}
+		for _, userID := range users {
+			if userID != msg.SenderID && cursors[userID] >= msg.ID {
+				msg.SeenBy = append(msg.SeenBy, userID)
+			}
+		}
+	}
+}
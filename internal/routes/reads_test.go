@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"server/internal/store"
+)
+
+func TestMarkChatRead(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	author, _ := s.user("author")
+	reader, readerToken := s.user("reader")
+	_, strangerToken := s.user("stranger")
+	for _, chatID := range []string{"c", "other"} {
+		if err := s.store.Chats.Create(ctx, store.Chat{ID: chatID, Type: store.ChatTypeGroup}, author.ID, reader.ID); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 4; i++ {
+			if _, err := s.store.Messages.Create(ctx, store.Message{ChatID: chatID, SenderID: author.ID, Text: "hi"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// ID сообщений общие для всех чатов: в c это 1..4, в other — 5..8
+
+	steps := []struct {
+		name       string
+		body       any
+		wantCursor int
+		wantUnread int
+	}{
+		{"forward", MarkReadRequest{MessageID: 2}, 2, 2},
+		{"backward is ignored", MarkReadRequest{MessageID: 1}, 2, 2},
+		{"clamped to the last message", MarkReadRequest{MessageID: 100}, 4, 0},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			rec := s.do("POST", "/auth/chats/c/read", readerToken, step.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+			}
+			got := decode[ReadStateResponse](t, rec)
+			want := ReadStateResponse{ChatID: "c", LastReadMessageID: step.wantCursor, Unread: step.wantUnread}
+			if got != want {
+				t.Errorf("response = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// История чата отдает тот же курсор и счетчик
+	rec := s.do("GET", "/auth/chats?chat_id=c", readerToken, nil)
+	if history := decode[ChatHistoryResponse](t, rec); history.Unread != 0 || history.ReadCursors[reader.ID] != 4 {
+		t.Errorf("history unread = %d, cursors %v; want 0 and reader at 4", history.Unread, history.ReadCursors)
+	}
+	// Непрочитанные другого чата в ответ не попадают
+	rec = s.do("GET", "/auth/chats?chat_id=other", readerToken, nil)
+	if history := decode[ChatHistoryResponse](t, rec); history.Unread != 4 {
+		t.Errorf("other chat unread = %d, want 4", history.Unread)
+	}
+
+	rejected := []struct {
+		name  string
+		token string
+		body  any
+		want  int
+	}{
+		{"stranger", strangerToken, MarkReadRequest{MessageID: 1}, http.StatusForbidden},
+		{"zero message", readerToken, MarkReadRequest{}, http.StatusBadRequest},
+		{"malformed body", readerToken, []byte("{"), http.StatusBadRequest},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.do("POST", "/auth/chats/c/read", tt.token, tt.body); rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillSeenBy(t *testing.T) {
+	deletedAt := time.Now()
+	messages := []Message{
+		{ID: 3, SenderID: "a"},
+		{ID: 2, SenderID: "b"},
+		{ID: 1, SenderID: "a", DeletedAt: &deletedAt},
+	}
+	cursors := map[string]int{"a": 2, "b": 3, "c": 0, "d": 2}
+
+	fillSeenBy(messages, cursors)
+
+	want := map[int][]string{
+		3: {"b"},
+		2: {"a", "d"},
+		1: nil,
+	}
+	for _, msg := range messages {
+		if !reflect.DeepEqual(msg.SeenBy, want[msg.ID]) {
+			t.Errorf("message %d seen by %v, want %v", msg.ID, msg.SeenBy, want[msg.ID])
+		}
+	}
+}
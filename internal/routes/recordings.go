@@ -0,0 +1,197 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"server/internal/access"
+	"server/internal/recording"
+	"server/internal/signaling"
+
+	"github.com/gorilla/mux"
+)
+
+type RecordingManifest = recording.Manifest
+
+// RecordingResponse — ID начатой или остановленной записи
+type RecordingResponse struct {
+	ID string `json:"id"`
+}
+
+// @Summary Начать запись комнаты
+// @Description Только владелец. Комната должна быть открыта: запись идет, пока в ней есть участники, и останавливается вместе с сессией. Участникам рассылается индикатор recording.
+// @Tags recordings
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Success 200 {object} routes.RecordingResponse
+// @Failure 409 {string} string "Сессия не идет или запись уже включена"
+// @Router /auth/rooms/{roomId}/recording [post]
+func (h *Handler) StartRecording(w http.ResponseWriter, r *http.Request) {
+	h.switchRecording(w, r, true)
+}
+
+// @Summary Остановить запись комнаты
+// @Description Только владелец. Файлы записи дописываются и становятся доступны для скачивания.
+// @Tags recordings
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Success 200 {object} routes.RecordingResponse
+// @Failure 409 {string} string "Запись не идет"
+// @Router /auth/rooms/{roomId}/recording [delete]
+func (h *Handler) StopRecording(w http.ResponseWriter, r *http.Request) {
+	h.switchRecording(w, r, false)
+}
+
+func (h *Handler) switchRecording(w http.ResponseWriter, r *http.Request, start bool) {
+	if !h.recordingEnabled(w) {
+		return
+	}
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermManage)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value("user_id").(string)
+
+	id, err := h.rooms.Record(roomID, userID, start)
+	if err != nil {
+		writeRecordingError(w, "Record", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecordingResponse{ID: id})
+}
+
+// @Summary Записи комнаты
+// @Description Только владелец; новые первыми
+// @Tags recordings
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Success 200 {array} routes.RecordingManifest
+// @Router /auth/rooms/{roomId}/recordings [get]
+func (h *Handler) ListRecordings(w http.ResponseWriter, r *http.Request) {
+	if !h.recordingEnabled(w) {
+		return
+	}
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermManage)
+	if !ok {
+		return
+	}
+
+	manifests, err := h.recordings.List(roomID)
+	if err != nil {
+		writeRecordingError(w, "ListRecordings", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifests)
+}
+
+// @Summary Запись комнаты
+// @Description Манифест записи: дорожки участников и их файлы
+// @Tags recordings
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Param recordingId path string true "ID записи"
+// @Success 200 {object} routes.RecordingManifest
+// @Router /auth/rooms/{roomId}/recordings/{recordingId} [get]
+func (h *Handler) GetRecording(w http.ResponseWriter, r *http.Request) {
+	if !h.recordingEnabled(w) {
+		return
+	}
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermManage)
+	if !ok {
+		return
+	}
+
+	manifest, err := h.recordings.Get(roomID, mux.Vars(r)["recordingId"])
+	if err != nil {
+		writeRecordingError(w, "GetRecording", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// @Summary Скачать файл записи
+// @Description Файл дорожки из манифеста (ogg, ivf, h264) или manifest.json
+// @Tags recordings
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Param recordingId path string true "ID записи"
+// @Param file path string true "Имя файла"
+// @Success 200 {file} binary
+// @Router /auth/rooms/{roomId}/recordings/{recordingId}/files/{file} [get]
+func (h *Handler) DownloadRecording(w http.ResponseWriter, r *http.Request) {
+	if !h.recordingEnabled(w) {
+		return
+	}
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermManage)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	path, err := h.recordings.FilePath(roomID, vars["recordingId"], vars["file"])
+	if err != nil {
+		writeRecordingError(w, "DownloadRecording", err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="`+vars["file"]+`"`)
+	http.ServeFile(w, r, path)
+}
+
+// @Summary Удалить запись
+// @Description Только владелец; идущую запись сначала нужно остановить
+// @Tags recordings
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Param recordingId path string true "ID записи"
+// @Success 204
+// @Failure 409 {string} string "Запись еще идет"
+// @Router /auth/rooms/{roomId}/recordings/{recordingId} [delete]
+func (h *Handler) DeleteRecording(w http.ResponseWriter, r *http.Request) {
+	if !h.recordingEnabled(w) {
+		return
+	}
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermManage)
+	if !ok {
+		return
+	}
+
+	if err := h.recordings.Delete(roomID, mux.Vars(r)["recordingId"]); err != nil {
+		writeRecordingError(w, "DeleteRecording", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) recordingEnabled(w http.ResponseWriter) bool {
+	if h.recordings == nil {
+		http.Error(w, "Recording is disabled", http.StatusNotImplemented)
+		return false
+	}
+	return true
+}
+
+func writeRecordingError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, signaling.ErrRoomNotLive),
+		errors.Is(err, recording.ErrActive),
+		errors.Is(err, recording.ErrNotActive):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, signaling.ErrRecordingDisabled):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		writeAccessError(w, op, err)
+	}
+}
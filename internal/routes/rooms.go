@@ -1,20 +1,68 @@
 package routes
 
 import (
+	cryptorand "crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
 	"log"
 	"net/http"
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/store"
+	"strconv"
+	"time"
 )
 
+type Room = store.Room
+
+type CreateRoomRequest struct {
+	Name string `json:"name"`
+	// public (по умолчанию), invite_only или private
+	Visibility store.RoomVisibility `json:"visibility"`
+	// Лимит участников сессии; 0 — общий лимит сервера
+	MaxParticipants int `json:"max_participants"`
+	// Новые участники ждут допуска от владельца или модератора
+	Lobby bool `json:"lobby"`
+	// mesh (по умолчанию) или sfu — медиа через сервер, если он включен
+	MediaMode store.MediaMode `json:"media_mode"`
+}
+
+// UpdateRoomRequest — частичное изменение комнаты; отсутствующие поля не меняются
+type UpdateRoomRequest struct {
+	Name            *string               `json:"name,omitempty"`
+	Visibility      *store.RoomVisibility `json:"visibility,omitempty"`
+	MaxParticipants *int                  `json:"max_participants,omitempty"`
+	Lobby           *bool                 `json:"lobby,omitempty"`
+	MediaMode       *store.MediaMode      `json:"media_mode,omitempty"`
+	Archived        *bool                 `json:"archived,omitempty"`
+}
+
+// RoomResponse — комната вместе с ролью запросившего
+type RoomResponse struct {
+	Room
+	Role store.Role `json:"role,omitempty"`
+}
+
+type CreateInviteRequest struct {
+	// 0 — без ограничения
+	MaxUses int `json:"max_uses"`
+	// Срок действия в секундах; 0 — бессрочно
+	ExpiresIn int `json:"expires_in"`
+}
+
 // @Summary Создать комнату
+// @Description Создатель становится владельцем комнаты
 // @Tags rooms
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} routes.Room
+// @Param request body CreateRoomRequest true "Название и видимость"
+// @Success 200 {object} routes.Room
 // @Router /auth/rooms [post]
 func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request) {
-	var req Room
+	var req CreateRoomRequest
 	userID, ok := r.Context().Value("user_id").(string)
 	if !ok {
 		log.Printf("User ID not found in context")
@@ -33,13 +81,33 @@ func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var room Room
-
-	err := h.DB.QueryRow(
-		"INSERT INTO rooms (name, created_by) VALUES ($1, $2) RETURNING id, name, created_by",
-		req.Name, userID,
-	).Scan(&room.ID, &room.Name, &room.CreatedBy)
+	if req.Visibility == "" {
+		req.Visibility = store.RoomPublic
+	}
+	if !validVisibility(req.Visibility) {
+		http.Error(w, "Invalid visibility", http.StatusBadRequest)
+		return
+	}
+	if !h.validCapacity(req.MaxParticipants) {
+		http.Error(w, "Invalid max_participants", http.StatusBadRequest)
+		return
+	}
+	if req.MediaMode == "" {
+		req.MediaMode = store.MediaMesh
+	}
+	if !h.validMediaMode(req.MediaMode) {
+		http.Error(w, "Invalid media_mode", http.StatusBadRequest)
+		return
+	}
 
+	room, err := h.Store.Rooms.Create(r.Context(), store.Room{
+		Name:            req.Name,
+		CreatedBy:       userID,
+		Visibility:      req.Visibility,
+		MaxParticipants: req.MaxParticipants,
+		Lobby:           req.Lobby,
+		MediaMode:       req.MediaMode,
+	})
 	if err != nil {
 		log.Printf("Error creating room: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -54,7 +122,7 @@ func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// @Summary Получить все комнаты
+// @Summary Комнаты, в которых состоит пользователь
 // @Tags rooms
 // @Accept json
 // @Product json
@@ -69,36 +137,467 @@ func (h *Handler) GetRooms(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `SELECT id, name, created_by FROM rooms WHERE created_by = $1 ORDER BY id DESC`
-	rows, err := h.DB.Query(query, userID)
+	rooms, err := h.Store.Rooms.ListByMember(r.Context(), userID)
 	if err != nil {
 		log.Printf("Error fetching rooms: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var rooms []Room
-	for rows.Next() {
-		var room Room
-		if err := rows.Scan(&room.ID, &room.Name, &room.CreatedBy); err != nil {
-			log.Printf("Error scanning room: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rooms); err != nil {
+		log.Printf("Error encoding rooms: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// @Summary Получить комнату
+// @Description Доступно участникам; публичную комнату видят все
+// @Tags rooms
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Success 200 {object} routes.RoomResponse
+// @Router /auth/rooms/{roomId} [get]
+func (h *Handler) GetRoom(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	roomID := mux.Vars(r)["roomId"]
+
+	room, err := h.Store.Rooms.Get(r.Context(), roomID)
+	if err != nil {
+		writeAccessError(w, "GetRoom", err)
+		return
+	}
+
+	a, err := h.access.Resolve(r.Context(), store.ScopeRoom, roomID, userID)
+	if err != nil {
+		writeAccessError(w, "GetRoom", err)
+		return
+	}
+	if a.Banned || (!a.Member && room.Visibility != store.RoomPublic) {
+		// Не раскрываем существование закрытых комнат
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RoomResponse{Room: room, Role: a.Role})
+}
+
+// @Summary Изменить комнату
+// @Description Переименование, видимость, лимит участников, лобби, режим медиа и архивация; только владелец. Архивация отключает всех участников. Новый режим медиа действует со следующей сессии комнаты.
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Param request body UpdateRoomRequest true "Изменения"
+// @Success 200 {object} routes.Room
+// @Router /auth/rooms/{roomId} [patch]
+func (h *Handler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	var req UpdateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil && *req.Name == "" {
+		http.Error(w, "Name must not be empty", http.StatusBadRequest)
+		return
+	}
+	if req.Visibility != nil && !validVisibility(*req.Visibility) {
+		http.Error(w, "Invalid visibility", http.StatusBadRequest)
+		return
+	}
+	if req.MaxParticipants != nil && !h.validCapacity(*req.MaxParticipants) {
+		http.Error(w, "Invalid max_participants", http.StatusBadRequest)
+		return
+	}
+	if req.MediaMode != nil && !h.validMediaMode(*req.MediaMode) {
+		http.Error(w, "Invalid media_mode", http.StatusBadRequest)
+		return
+	}
+
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermManage)
+	if !ok {
+		return
+	}
+
+	upd := store.RoomUpdate{
+		Name:            req.Name,
+		Visibility:      req.Visibility,
+		MaxParticipants: req.MaxParticipants,
+		Lobby:           req.Lobby,
+		MediaMode:       req.MediaMode,
+		Archived:        req.Archived,
+	}
+
+	var room Room
+	var err error
+	if req.Archived != nil && *req.Archived {
+		err = h.rooms.CloseRoom(roomID, "room archived", func() error {
+			room, err = h.Store.Rooms.Update(r.Context(), roomID, upd)
+			return err
+		})
+	} else {
+		room, err = h.Store.Rooms.Update(r.Context(), roomID, upd)
+	}
+	if err != nil {
+		writeAccessError(w, "UpdateRoom", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+// @Summary Удалить комнату
+// @Description Удаляет комнату с участниками, приглашениями и записями этого узла; только владелец. Активная сессия закрывается.
+// @Tags rooms
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Success 204
+// @Router /auth/rooms/{roomId} [delete]
+func (h *Handler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermManage)
+	if !ok {
+		return
+	}
+
+	err := h.rooms.CloseRoom(roomID, "room deleted", func() error {
+		return h.Store.Rooms.Delete(r.Context(), roomID)
+	})
+	if err != nil {
+		writeAccessError(w, "DeleteRoom", err)
+		return
+	}
+	if h.recordings != nil {
+		if err := h.recordings.DeleteRoom(roomID); err != nil {
+			log.Printf("Error deleting recordings of room %s: %v", roomID, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Публичные комнаты, в которые можно войти
+// @Tags rooms
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Количество (по умолчанию 50)"
+// @Param offset query int false "Смещение"
+// @Success 200 {array} routes.Room
+// @Router /auth/rooms/joinable [get]
+func (h *Handler) GetJoinableRooms(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 50
+	offset := 0
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 100 {
+		limit = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v >= 0 {
+		offset = v
+	}
+
+	rooms, err := h.Store.Rooms.ListJoinable(r.Context(), userID, limit, offset)
+	if err != nil {
+		log.Printf("Error fetching joinable rooms: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if rooms == nil {
+		rooms = []Room{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rooms)
+}
+
+// @Summary Вступить в публичную комнату
+// @Tags rooms
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Success 200 {object} routes.Room
+// @Router /auth/rooms/{roomId}/join [post]
+func (h *Handler) JoinRoom(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	roomID := mux.Vars(r)["roomId"]
+
+	if _, err := h.rooms.Admit(r.Context(), roomID, userID); err != nil {
+		writeAccessError(w, "JoinRoom", err)
+		return
+	}
+
+	h.writeRoom(w, r, roomID)
+}
+
+// @Summary Создать ссылку-приглашение
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Param request body CreateInviteRequest false "Ограничения приглашения"
+// @Success 201 {object} store.RoomInvite
+// @Failure 409 {string} string "Комната в архиве"
+// @Router /auth/rooms/{roomId}/invites [post]
+func (h *Handler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	var req CreateInviteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MaxUses < 0 || req.ExpiresIn < 0 {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		rooms = append(rooms, room)
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Error fetching rooms: %v", err)
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermInvite)
+	if !ok {
+		return
+	}
+
+	room, err := h.Store.Rooms.Get(r.Context(), roomID)
+	if err != nil {
+		writeAccessError(w, "CreateInvite", err)
+		return
+	}
+	if room.ArchivedAt != nil {
+		http.Error(w, "Room is archived", http.StatusConflict)
+		return
+	}
+
+	code, err := inviteCode()
+	if err != nil {
+		log.Printf("Error generating invite code: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	inv := store.RoomInvite{
+		Code:      code,
+		RoomID:    roomID,
+		CreatedBy: r.Context().Value("user_id").(string),
+		MaxUses:   req.MaxUses,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		inv.ExpiresAt = &expiresAt
+	}
+
+	if err := h.Store.Invites.Create(r.Context(), inv); err != nil {
+		log.Printf("Error creating invite: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	created, err := h.Store.Invites.Get(r.Context(), code)
+	if err != nil {
+		log.Printf("Error loading invite: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(rooms); err != nil {
-		log.Printf("Error encoding rooms: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// @Summary Приглашения комнаты
+// @Tags rooms
+// @Produce json
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Success 200 {array} store.RoomInvite
+// @Router /auth/rooms/{roomId}/invites [get]
+func (h *Handler) GetInvites(w http.ResponseWriter, r *http.Request) {
+	_, roomID, _, ok := h.authorizeResource(w, r, access.PermModerate)
+	if !ok {
+		return
+	}
+
+	invites, err := h.Store.Invites.ListByRoom(r.Context(), roomID)
+	if err != nil {
+		log.Printf("Error listing invites: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if invites == nil {
+		invites = []store.RoomInvite{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invites)
+}
+
+// @Summary Отозвать приглашение
+// @Description Отозвать может автор приглашения или модератор комнаты
+// @Tags rooms
+// @Security BearerAuth
+// @Param roomId path string true "ID комнаты"
+// @Param code path string true "Код приглашения"
+// @Success 204
+// @Router /auth/rooms/{roomId}/invites/{code} [delete]
+func (h *Handler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	_, roomID, actor, ok := h.authorizeResource(w, r, access.PermJoin)
+	if !ok {
 		return
 	}
+
+	inv, err := h.Store.Invites.Get(r.Context(), mux.Vars(r)["code"])
+	if errors.Is(err, store.ErrNotFound) || (err == nil && inv.RoomID != roomID) {
+		http.Error(w, "Invite not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading invite: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if inv.CreatedBy != r.Context().Value("user_id").(string) && !actor.Can(access.PermModerate) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	err = h.Store.Invites.Revoke(r.Context(), inv.Code)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Error revoking invite: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Принять приглашение и вступить в комнату
+// @Tags rooms
+// @Produce json
+// @Security BearerAuth
+// @Param code path string true "Код приглашения"
+// @Success 200 {object} routes.Room
+// @Failure 410 {string} string "Приглашение отозвано, истекло или исчерпано, или комната в архиве"
+// @Router /auth/invites/{code}/accept [post]
+func (h *Handler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	code := mux.Vars(r)["code"]
+
+	inv, err := h.Store.Invites.Get(r.Context(), code)
+	if err != nil {
+		writeAccessError(w, "AcceptInvite", err)
+		return
+	}
+
+	// В архивную комнату не войти, поэтому приглашение не засчитываем
+	room, err := h.Store.Rooms.Get(r.Context(), inv.RoomID)
+	if err != nil {
+		writeAccessError(w, "AcceptInvite", err)
+		return
+	}
+	if room.ArchivedAt != nil {
+		http.Error(w, "Room is archived", http.StatusGone)
+		return
+	}
+
+	a, err := h.access.Resolve(r.Context(), store.ScopeRoom, inv.RoomID, userID)
+	if err != nil {
+		writeAccessError(w, "AcceptInvite", err)
+		return
+	}
+	if a.Banned {
+		http.Error(w, "Banned from this room", http.StatusForbidden)
+		return
+	}
+
+	// Участнику повторный вход не нужен, использование не засчитываем
+	if !a.Member {
+		if _, err := h.Store.Invites.Redeem(r.Context(), code, time.Now()); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				http.Error(w, "Invite is no longer valid", http.StatusGone)
+				return
+			}
+			writeAccessError(w, "AcceptInvite", err)
+			return
+		}
+
+		if err := h.Store.Members.Add(r.Context(), store.ScopeRoom, inv.RoomID, userID, store.RoleMember); err != nil {
+			writeAccessError(w, "AcceptInvite", err)
+			return
+		}
+	}
+
+	h.writeRoom(w, r, inv.RoomID)
+}
+
+func (h *Handler) writeRoom(w http.ResponseWriter, r *http.Request, roomID string) {
+	room, err := h.Store.Rooms.Get(r.Context(), roomID)
+	if err != nil {
+		writeAccessError(w, "writeRoom", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(room)
+}
+
+// writeAccessError отвечает 404/403 для ошибок хранилища и проверки прав, иначе 500
+func writeAccessError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, auth.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("%s: %v", op, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func validVisibility(v store.RoomVisibility) bool {
+	switch v {
+	case store.RoomPublic, store.RoomInviteOnly, store.RoomPrivate:
+		return true
+	}
+	return false
+}
+
+// validCapacity — лимит участников комнаты от 0 (общий лимит) до общего лимита сервера
+func (h *Handler) validCapacity(n int) bool {
+	return n >= 0 && n <= h.Config.Rooms.MaxParticipants
+}
+
+// validMediaMode — режим sfu доступен, только если на сервере включен SFU
+func (h *Handler) validMediaMode(m store.MediaMode) bool {
+	switch m {
+	case store.MediaMesh:
+		return true
+	case store.MediaSFU:
+		return h.Config.SFU.Enabled
+	}
+	return false
+}
+
+// inviteCode — случайный код приглашения, пригодный для URL
+func inviteCode() (string, error) {
+	b := make([]byte, 12)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
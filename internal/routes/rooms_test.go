@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"server/internal/store"
+)
+
+func TestInviteLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	owner, ownerToken := s.user("owner")
+	room, err := s.store.Rooms.Create(ctx, store.Room{Name: "room", CreatedBy: owner.ID, Visibility: store.RoomPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := s.do("POST", "/auth/rooms/"+room.ID+"/invites", ownerToken, CreateInviteRequest{MaxUses: 2})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create invite: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	inv := decode[store.RoomInvite](t, rec)
+
+	steps := []struct {
+		name string
+		user string
+		want int
+	}{
+		{"first guest", "guest1", http.StatusOK},
+		{"member again does not use the invite", "guest1", http.StatusOK},
+		{"second guest", "guest2", http.StatusOK},
+		{"exhausted", "guest3", http.StatusGone},
+	}
+	tokens := map[string]string{}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if tokens[step.user] == "" {
+				_, tokens[step.user] = s.user(step.user)
+			}
+			rec := s.do("POST", "/auth/invites/"+inv.Code+"/accept", tokens[step.user], nil)
+			if rec.Code != step.want {
+				t.Fatalf("accept: status %d, want %d, body %q", rec.Code, step.want, rec.Body.String())
+			}
+		})
+	}
+
+	// Не участник ссылку не создаст
+	if rec := s.do("POST", "/auth/rooms/"+room.ID+"/invites", tokens["guest3"], nil); rec.Code != http.StatusForbidden {
+		t.Errorf("invite by a stranger: status %d, want 403", rec.Code)
+	}
+	if rec := s.do("POST", "/auth/invites/missing/accept", tokens["guest1"], nil); rec.Code != http.StatusNotFound {
+		t.Errorf("accept of a missing invite: status %d, want 404", rec.Code)
+	}
+}
+
+func TestGetRoomVisibility(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	owner, ownerToken := s.user("owner")
+	_, strangerToken := s.user("stranger")
+	public, err := s.store.Rooms.Create(ctx, store.Room{Name: "public", CreatedBy: owner.ID, Visibility: store.RoomPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := s.store.Rooms.Create(ctx, store.Room{Name: "private", CreatedBy: owner.ID, Visibility: store.RoomPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		roomID   string
+		token    string
+		want     int
+		wantRole store.Role
+	}{
+		{"owner", private.ID, ownerToken, http.StatusOK, store.RoleOwner},
+		{"stranger sees a public room", public.ID, strangerToken, http.StatusOK, ""},
+		{"stranger does not see a private room", private.ID, strangerToken, http.StatusNotFound, ""},
+		{"missing room", "missing", ownerToken, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do("GET", "/auth/rooms/"+tt.roomID, tt.token, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %q", rec.Code, tt.want, rec.Body.String())
+			}
+			if rec.Code == http.StatusOK {
+				if got := decode[RoomResponse](t, rec); got.ID != tt.roomID || got.Role != tt.wantRole {
+					t.Errorf("room = %+v, want %s with role %q", got, tt.roomID, tt.wantRole)
+				}
+			}
+		})
+	}
+}
+
+func TestInvitesOfArchivedRoom(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	owner, ownerToken := s.user("owner")
+	_, guestToken := s.user("guest")
+	room, err := s.store.Rooms.Create(ctx, store.Room{Name: "room", CreatedBy: owner.ID, Visibility: store.RoomPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := s.do("POST", "/auth/rooms/"+room.ID+"/invites", ownerToken, CreateInviteRequest{MaxUses: 1})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create invite: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	inv := decode[store.RoomInvite](t, rec)
+
+	archived := true
+	if _, err := s.store.Rooms.Update(ctx, room.ID, store.RoomUpdate{Archived: &archived}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := s.do("POST", "/auth/rooms/"+room.ID+"/invites", ownerToken, nil); rec.Code != http.StatusConflict {
+		t.Errorf("invite to an archived room: status %d, want 409", rec.Code)
+	}
+	if rec := s.do("POST", "/auth/invites/"+inv.Code+"/accept", guestToken, nil); rec.Code != http.StatusGone {
+		t.Errorf("accept into an archived room: status %d, want 410", rec.Code)
+	}
+	if got, err := s.store.Invites.Get(ctx, inv.Code); err != nil || got.Uses != 0 {
+		t.Errorf("invite after a refused accept = %+v, %v; want no uses", got, err)
+	}
+}
@@ -1,13 +1,14 @@
 package routes
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 	"log"
 	"net/http"
-	"strconv"
+	"server/internal/store"
+	"time"
 )
 
 type RegisterRequest struct {
@@ -20,8 +21,10 @@ type LoginRequest struct {
 }
 
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	User         User      `json:"user"`
 }
 
 type FriendProfile struct {
@@ -52,52 +55,16 @@ func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDInt, err := strconv.Atoi(userID)
-	if err != nil {
-		log.Printf("Invalid user ID format: %v", err)
-		http.Error(w, "Bad request", http.StatusBadRequest)
-		return
-	}
-
-	query := `
-    SELECT u.id, u.name, 
-           (
-               SELECT f.status 
-               FROM friendship f 
-               WHERE ((f.user_id = $1 AND f.friend_id = u.id::text) OR 
-                      (f.user_id = u.id::text AND f.friend_id = $2) AND (f.status = 'accepted' OR f.status = 'pending'))
-               LIMIT 1
-           ) as is_friend
-    FROM users u
-    WHERE u.id != $3::integer
-    ORDER BY u.id DESC
-`
-
-	rows, err := h.DB.Query(query, userIDInt, userIDInt, userIDInt)
-
+	list, err := h.Store.Users.ListOthers(r.Context(), userID)
 	if err != nil {
 		log.Printf("Error fetching users: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	var users []User
-	for rows.Next() {
-		var user User
-		if err := rows.Scan(&user.ID, &user.Name, &user.IsFriend); err != nil {
-			log.Printf("Error scanning users: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		users = append(users, user)
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("Error fetching users: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	for _, u := range list {
+		users = append(users, User{ID: u.ID, Name: u.Name, IsFriend: u.FriendStatus})
 	}
 
 	if err := json.NewEncoder(w).Encode(users); err != nil {
@@ -119,27 +86,9 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	query := `SELECT u.id, u.name, 
-  (SELECT json_agg(json_build_object(
-      'id', friends.id::text, 
-      'name', friends.name
-    ))
-   FROM users friends
-   WHERE EXISTS (
-     SELECT 1 FROM friendship f
-     WHERE ((f.user_id = u.id::text AND f.friend_id = friends.id::text) OR 
-            (f.friend_id = u.id::text AND f.user_id = friends.id::text))
-       AND f.status = 'accepted'
-   )
-  ) as friends
-FROM users u 
-WHERE name = $1`
-	row := h.DB.QueryRow(query, name)
-
-	var user UserProfile
-	var friendsJSON []byte
-	if err := row.Scan(&user.Id, &user.Name, &friendsJSON); err != nil {
-		if err == sql.ErrNoRows {
+	found, err := h.Store.Users.GetByName(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
 			log.Printf("User not found: %s", name)
 			http.Error(w, "User not found", http.StatusNotFound)
 			return
@@ -149,13 +98,16 @@ WHERE name = $1`
 		return
 	}
 
-	if len(friendsJSON) > 0 {
-		err := json.Unmarshal(friendsJSON, &user.Friends)
-		if err != nil {
-			log.Printf("Error unmarshaling friends: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
+	friends, err := h.Store.Friendships.ListAccepted(r.Context(), found.ID)
+	if err != nil {
+		log.Printf("Error fetching friends: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user := UserProfile{Id: found.ID, Name: found.Name}
+	for _, f := range friends {
+		user.Friends = append(user.Friends, FriendProfile{Id: f.ID, Name: f.Name})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -186,19 +138,6 @@ func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var count int
-	err := h.DB.QueryRow("SELECT COUNT(*) FROM users WHERE name = $1", req.Name).Scan(&count)
-	if err != nil {
-		log.Printf("Error checking existing user: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	if count > 0 {
-		http.Error(w, "User with this name already exists", http.StatusConflict)
-		return
-	}
-
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		log.Printf("Error hashing password: %v", err)
@@ -206,28 +145,26 @@ func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user User
-	err = h.DB.QueryRow(
-		"INSERT INTO users (name, password) VALUES ($1, $2) RETURNING id, name",
-		req.Name, string(hashedPassword),
-	).Scan(&user.ID, &user.Name)
-
+	created, err := h.Store.Users.Create(r.Context(), req.Name, string(hashedPassword))
 	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "User with this name already exists", http.StatusConflict)
+			return
+		}
 		log.Printf("Error inserting user: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	user := User{ID: created.ID, Name: created.Name}
 
-	token, err := createToken(user.ID, user.Name)
+	tokens, err := h.Auth.Issue(r.Context(), user.ID, user.Name)
 	if err != nil {
 		log.Printf("Error creating token: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(AuthResponse{
-		Token: token, User: user,
-	})
+	json.NewEncoder(w).Encode(newAuthResponse(tokens, user))
 }
 
 // @Summary Аутентификация
@@ -251,13 +188,7 @@ func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Поиск пользователя по имени
-	var user User
-	var hashedPassword string
-	err := h.DB.QueryRow(
-		"SELECT id, name, password FROM users WHERE name = $1",
-		req.Name,
-	).Scan(&user.ID, &user.Name, &hashedPassword)
-
+	found, err := h.Store.Users.GetByName(r.Context(), req.Name)
 	if err != nil {
 		log.Printf("Error finding user: %v", err)
 		http.Error(w, "Invalid name or password", http.StatusUnauthorized)
@@ -265,15 +196,16 @@ func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Проверка пароля
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(found.PasswordHash), []byte(req.Password))
 	if err != nil {
 		log.Printf("Invalid password for user %s: %v", req.Name, err)
 		http.Error(w, "Invalid name or password", http.StatusUnauthorized)
 		return
 	}
+	user := User{ID: found.ID, Name: found.Name}
 
 	// Создание JWT токена
-	token, err := createToken(user.ID, user.Name)
+	tokens, err := h.Auth.Issue(r.Context(), user.ID, user.Name)
 	if err != nil {
 		log.Printf("Error creating token: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -282,8 +214,5 @@ func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 
 	// Отправка ответа
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	json.NewEncoder(w).Encode(newAuthResponse(tokens, user))
 }
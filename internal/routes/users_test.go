@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do("POST", "/users/register", "", RegisterRequest{Name: "alice", Password: "secret"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	registered := decode[AuthResponse](t, rec)
+	if registered.Token == "" || registered.RefreshToken == "" || registered.User.Name != "alice" || registered.User.ID == "" {
+		t.Fatalf("register response = %+v", registered)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body any
+		want int
+	}{
+		{"login", "/users/login", LoginRequest{Name: "alice", Password: "secret"}, http.StatusOK},
+		{"wrong password", "/users/login", LoginRequest{Name: "alice", Password: "nope"}, http.StatusUnauthorized},
+		{"unknown user", "/users/login", LoginRequest{Name: "bob", Password: "secret"}, http.StatusUnauthorized},
+		{"login without password", "/users/login", LoginRequest{Name: "alice"}, http.StatusBadRequest},
+		{"name taken", "/users/register", RegisterRequest{Name: "alice", Password: "other"}, http.StatusConflict},
+		{"register without name", "/users/register", RegisterRequest{Password: "secret"}, http.StatusBadRequest},
+		{"malformed body", "/users/register", []byte("{"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.do("POST", tt.path, "", tt.body); rec.Code != tt.want {
+				t.Errorf("status %d, want %d, body %q", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	// Токен из ответа проходит проверку
+	if rec := s.do("GET", "/auth/chats", registered.Token, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("request with the issued token: status %d, want 400 for the missing chat_id", rec.Code)
+	}
+	if rec := s.do("GET", "/auth/chats?chat_id=c", "garbage", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request with a bad token: status %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package sfu
+
+import "sync"
+
+// outbox доставляет сигналы по порядку из отдельной горутины, чтобы методы
+// сессии не ждали получателя, а колбэки pion не блокировались на нем
+type outbox struct {
+	deliver func(Signal)
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []Signal
+	closed bool
+}
+
+func newOutbox(deliver func(Signal)) *outbox {
+	o := &outbox{deliver: deliver}
+	o.cond = sync.NewCond(&o.mu)
+	go o.run()
+	return o
+}
+
+func (o *outbox) push(sig Signal) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return
+	}
+	o.queue = append(o.queue, sig)
+	o.cond.Signal()
+}
+
+func (o *outbox) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.closed = true
+	o.queue = nil
+	o.cond.Signal()
+}
+
+func (o *outbox) run() {
+	for {
+		o.mu.Lock()
+		for len(o.queue) == 0 && !o.closed {
+			o.cond.Wait()
+		}
+		if o.closed {
+			o.mu.Unlock()
+			return
+		}
+		sig := o.queue[0]
+		o.queue = o.queue[1:]
+		o.mu.Unlock()
+
+		o.deliver(sig)
+	}
+}
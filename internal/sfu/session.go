@@ -0,0 +1,435 @@
+package sfu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+var (
+	// ErrUnknownPeer — участника нет в сессии
+	ErrUnknownPeer = errors.New("sfu: unknown peer")
+	// ErrGlare — offer участника встретился с offer сервера. Сервер в паре
+	// невежливый: его offer остается в силе, участник откатывает свой.
+	ErrGlare = errors.New("sfu: offer collides with a pending server offer")
+)
+
+// Sink получает дорожки участников, например для записи. AddTrack
+// вызывается под блокировкой сессии; возвращенный Writer закрывается,
+// когда дорожка заканчивается или sink снимают.
+type Sink interface {
+	AddTrack(userID string, track *webrtc.TrackRemote) (media.Writer, error)
+}
+
+// Session — медиасессия одной комнаты
+type Session struct {
+	sfu   *SFU
+	relay bool
+
+	mu     sync.Mutex
+	peers  map[string]*peer
+	tracks map[string]*forwardedTrack // ID локальной дорожки -> дорожка
+	sink   Sink
+	closed bool
+
+	out       *outbox
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type peer struct {
+	userID string
+	pc     *webrtc.PeerConnection
+	// renegotiate — состав дорожек изменился, пока ждали ответа на прошлый offer
+	renegotiate bool
+	// restartICE — следующий offer перезапускает ICE
+	restartICE bool
+}
+
+// forwardedTrack — дорожка участника, которую сервер раздает остальным
+type forwardedTrack struct {
+	owner  string
+	remote *webrtc.TrackRemote
+	local  *webrtc.TrackLocalStaticRTP
+	// writer — запись дорожки в sink, пока он установлен
+	writer atomic.Pointer[media.Writer]
+}
+
+// Join создает PeerConnection участника и отправляет ему offer
+func (s *Session) Join(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("sfu: session is closed")
+	}
+	if old, ok := s.peers[userID]; ok {
+		old.pc.Close()
+		delete(s.peers, userID)
+		s.removeTracksLocked(userID)
+	}
+
+	pc, err := s.sfu.api.NewPeerConnection(s.sfu.config)
+	if err != nil {
+		return fmt.Errorf("sfu: peer connection: %w", err)
+	}
+
+	// Участник отправляет по одной аудио- и видеодорожке
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		if _, err := pc.AddTransceiverFromKind(kind, webrtc.RTPTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionRecvonly,
+		}); err != nil {
+			pc.Close()
+			return fmt.Errorf("sfu: transceiver: %w", err)
+		}
+	}
+
+	p := &peer{userID: userID, pc: pc}
+
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil {
+			s.out.push(Signal{UserID: userID, EndOfCandidates: true})
+			return
+		}
+		candidate := c.ToJSON()
+		s.out.push(Signal{UserID: userID, Candidate: &candidate})
+	})
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed {
+			log.Printf("SFU connection of %s failed", userID)
+			pc.Close()
+		}
+	})
+
+	pc.OnTrack(func(remote *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		s.forward(p, remote)
+	})
+
+	s.peers[userID] = p
+	if s.relay {
+		s.negotiateAllLocked()
+	} else {
+		s.negotiateLocked(p)
+	}
+	return nil
+}
+
+// Leave закрывает соединение участника и убирает его дорожки у остальных
+func (s *Session) Leave(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.peers[userID]
+	if !ok {
+		return
+	}
+	delete(s.peers, userID)
+	p.pc.Close()
+	s.removeTracksLocked(userID)
+	if s.relay {
+		s.negotiateAllLocked()
+	}
+}
+
+// Record направляет все дорожки сессии, текущие и будущие, в sink;
+// nil снимает sink и закрывает его writer'ы
+func (s *Session) Record(sink Sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sink = sink
+	for _, track := range s.tracks {
+		closeWriter(track)
+		s.attachLocked(track)
+	}
+}
+
+// Answer применяет ответ участника на offer сервера
+func (s *Session) Answer(userID string, answer webrtc.SessionDescription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.peers[userID]
+	if !ok {
+		return ErrUnknownPeer
+	}
+	if err := p.pc.SetRemoteDescription(answer); err != nil {
+		return fmt.Errorf("sfu: set answer: %w", err)
+	}
+
+	if p.renegotiate {
+		p.renegotiate = false
+		s.negotiateLocked(p)
+	}
+	return nil
+}
+
+// Offer применяет offer участника, например после добавления им дорожки,
+// и отправляет ответ. Если сервер сам ждет ответа, возвращает ErrGlare.
+func (s *Session) Offer(userID string, offer webrtc.SessionDescription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.peers[userID]
+	if !ok {
+		return ErrUnknownPeer
+	}
+	if p.pc.SignalingState() != webrtc.SignalingStateStable {
+		return ErrGlare
+	}
+	if err := p.pc.SetRemoteDescription(offer); err != nil {
+		return fmt.Errorf("sfu: set offer: %w", err)
+	}
+	answer, err := p.pc.CreateAnswer(nil)
+	if err != nil {
+		return fmt.Errorf("sfu: answer: %w", err)
+	}
+	if err := p.pc.SetLocalDescription(answer); err != nil {
+		return fmt.Errorf("sfu: local description: %w", err)
+	}
+	s.out.push(Signal{UserID: userID, Answer: &answer})
+
+	if p.renegotiate {
+		p.renegotiate = false
+		s.negotiateLocked(p)
+	}
+	return nil
+}
+
+// RestartICE отправляет участнику offer с перезапуском ICE; если сервер
+// ждет ответа на прошлый offer, перезапуск уйдет следующим
+func (s *Session) RestartICE(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.peers[userID]
+	if !ok {
+		return ErrUnknownPeer
+	}
+	p.restartICE = true
+	s.negotiateLocked(p)
+	return nil
+}
+
+// AddCandidate добавляет ICE-кандидата участника; пустой кандидат — конец кандидатов
+func (s *Session) AddCandidate(userID string, candidate webrtc.ICECandidateInit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.peers[userID]
+	if !ok {
+		return ErrUnknownPeer
+	}
+	return p.pc.AddICECandidate(candidate)
+}
+
+// Close закрывает все соединения сессии
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		for id, p := range s.peers {
+			p.pc.Close()
+			delete(s.peers, id)
+		}
+		for id, track := range s.tracks {
+			closeWriter(track)
+			delete(s.tracks, id)
+		}
+		s.sink = nil
+		s.mu.Unlock()
+
+		close(s.done)
+		s.out.close()
+	})
+}
+
+// forward пересылает RTP входящей дорожки всем остальным участникам
+func (s *Session) forward(owner *peer, remote *webrtc.TrackRemote) {
+	// Браузеры выбирают ID дорожек сами, поэтому добавляем владельца.
+	// StreamID — ID пользователя, чтобы клиент сопоставил поток с участником.
+	local, err := webrtc.NewTrackLocalStaticRTP(remote.Codec().RTPCodecCapability,
+		owner.userID+":"+remote.ID(), owner.userID)
+	if err != nil {
+		log.Printf("SFU track of %s: %v", owner.userID, err)
+		return
+	}
+
+	s.mu.Lock()
+	if s.closed || s.peers[owner.userID] != owner {
+		s.mu.Unlock()
+		return
+	}
+	track := &forwardedTrack{owner: owner.userID, remote: remote, local: local}
+	s.tracks[local.ID()] = track
+	s.attachLocked(track)
+	if s.relay {
+		s.negotiateAllLocked()
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if s.tracks[local.ID()] == track {
+			delete(s.tracks, local.ID())
+			if s.relay {
+				s.negotiateAllLocked()
+			}
+		}
+		closeWriter(track)
+		s.mu.Unlock()
+	}()
+
+	for {
+		packet, _, err := remote.ReadRTP()
+		if err != nil {
+			return
+		}
+		// Запись идет до пересылки: WriteRTP локальной дорожки меняет заголовок
+		if w := track.writer.Load(); w != nil {
+			if err := (*w).WriteRTP(packet); err != nil {
+				log.Printf("SFU recording of %s stopped: %v", local.ID(), err)
+				closeWriter(track)
+			}
+		}
+		if err := local.WriteRTP(packet); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			return
+		}
+	}
+}
+
+// attachLocked подключает дорожку к текущему sink
+func (s *Session) attachLocked(track *forwardedTrack) {
+	if s.sink == nil {
+		return
+	}
+	w, err := s.sink.AddTrack(track.owner, track.remote)
+	if err != nil {
+		log.Printf("SFU sink for %s: %v", track.local.ID(), err)
+		return
+	}
+	track.writer.Store(&w)
+}
+
+// closeWriter отключает дорожку от sink; writer закрывается ровно один раз
+func closeWriter(track *forwardedTrack) {
+	if w := track.writer.Swap(nil); w != nil {
+		if err := (*w).Close(); err != nil {
+			log.Printf("SFU closing recording of %s: %v", track.local.ID(), err)
+		}
+	}
+}
+
+func (s *Session) removeTracksLocked(userID string) {
+	for id, track := range s.tracks {
+		if track.owner == userID {
+			closeWriter(track)
+			delete(s.tracks, id)
+		}
+	}
+}
+
+func (s *Session) negotiateAllLocked() {
+	for _, p := range s.peers {
+		s.negotiateLocked(p)
+	}
+}
+
+// negotiateLocked приводит отправляемые участнику дорожки к текущему составу
+// сессии и отправляет новый offer. Пока участник не ответил на предыдущий,
+// новый откладывается до Answer.
+func (s *Session) negotiateLocked(p *peer) {
+	if p.pc.ConnectionState() == webrtc.PeerConnectionStateClosed {
+		return
+	}
+	if p.pc.SignalingState() != webrtc.SignalingStateStable {
+		p.renegotiate = true
+		return
+	}
+
+	sending := make(map[string]bool)
+	for _, sender := range p.pc.GetSenders() {
+		track := sender.Track()
+		if track == nil {
+			continue
+		}
+		if _, ok := s.tracks[track.ID()]; !ok {
+			if err := p.pc.RemoveTrack(sender); err != nil {
+				log.Printf("SFU remove track for %s: %v", p.userID, err)
+			}
+			continue
+		}
+		sending[track.ID()] = true
+	}
+
+	for id, track := range s.tracks {
+		if !s.relay || sending[id] || track.owner == p.userID {
+			continue
+		}
+		sender, err := p.pc.AddTrack(track.local)
+		if err != nil {
+			log.Printf("SFU add track for %s: %v", p.userID, err)
+			continue
+		}
+		go drainRTCP(sender)
+	}
+
+	offer, err := p.pc.CreateOffer(&webrtc.OfferOptions{ICERestart: p.restartICE})
+	if err != nil {
+		log.Printf("SFU offer for %s: %v", p.userID, err)
+		return
+	}
+	if err := p.pc.SetLocalDescription(offer); err != nil {
+		log.Printf("SFU local description for %s: %v", p.userID, err)
+		return
+	}
+	p.restartICE = false
+	s.out.push(Signal{UserID: p.userID, Offer: &offer})
+}
+
+// drainRTCP читает RTCP получателя, иначе не работают перехватчики (NACK и др.)
+func drainRTCP(sender *webrtc.RTPSender) {
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := sender.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+// requestKeyFrames периодически просит отправителей прислать ключевой кадр
+func (s *Session) requestKeyFrames() {
+	ticker := time.NewTicker(keyFrameInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		for _, p := range s.peers {
+			for _, receiver := range p.pc.GetReceivers() {
+				track := receiver.Track()
+				if track == nil || track.Kind() != webrtc.RTPCodecTypeVideo {
+					continue
+				}
+				p.pc.WriteRTCP([]rtcp.Packet{
+					&rtcp.PictureLossIndication{MediaSSRC: uint32(track.SSRC())},
+				})
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,88 @@
+// Package sfu — серверная пересылка медиа для видеокомнат. Сервер держит
+// по одному PeerConnection на участника, принимает его дорожки и
+// пересылает RTP остальным участникам сессии. Первое предложение (offer)
+// и пересогласование при смене дорожек других участников создает сервер;
+// клиент отвечает на них, а свои изменения (новая дорожка, ICE restart)
+// согласует собственным offer — см. Session.Offer. При встречных offer
+// клиент уступает: сервер отвечает ErrGlare.
+package sfu
+
+import (
+	"fmt"
+	"server/internal/config"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/webrtc/v4"
+)
+
+// keyFrameInterval — как часто запрашивать ключевой кадр у отправителей,
+// чтобы новые получатели быстро получали изображение
+const keyFrameInterval = 3 * time.Second
+
+// SFU — общие настройки WebRTC для всех сессий
+type SFU struct {
+	api    *webrtc.API
+	config webrtc.Configuration
+}
+
+func New(cfg config.SFUConfig) (*SFU, error) {
+	var settings webrtc.SettingEngine
+	if cfg.PortMin != 0 || cfg.PortMax != 0 {
+		if err := settings.SetEphemeralUDPPortRange(cfg.PortMin, cfg.PortMax); err != nil {
+			return nil, fmt.Errorf("sfu: port range: %w", err)
+		}
+	}
+	if len(cfg.PublicIPs) > 0 {
+		settings.SetNAT1To1IPs(cfg.PublicIPs, webrtc.ICECandidateTypeHost)
+	}
+
+	media := &webrtc.MediaEngine{}
+	if err := media.RegisterDefaultCodecs(); err != nil {
+		return nil, fmt.Errorf("sfu: codecs: %w", err)
+	}
+	interceptors := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(media, interceptors); err != nil {
+		return nil, fmt.Errorf("sfu: interceptors: %w", err)
+	}
+
+	var iceServers []webrtc.ICEServer
+	if len(cfg.ICEServers) > 0 {
+		iceServers = append(iceServers, webrtc.ICEServer{URLs: cfg.ICEServers})
+	}
+
+	return &SFU{
+		api: webrtc.NewAPI(
+			webrtc.WithSettingEngine(settings),
+			webrtc.WithMediaEngine(media),
+			webrtc.WithInterceptorRegistry(interceptors),
+		),
+		config: webrtc.Configuration{ICEServers: iceServers},
+	}, nil
+}
+
+// Signal — сообщение сервера участнику: offer, ответ на offer участника,
+// ICE-кандидат или конец кандидатов
+type Signal struct {
+	UserID          string
+	Offer           *webrtc.SessionDescription
+	Answer          *webrtc.SessionDescription
+	Candidate       *webrtc.ICECandidateInit
+	EndOfCandidates bool
+}
+
+// NewSession создает сессию комнаты. signal вызывается из отдельной горутины
+// по порядку и может блокироваться, не задерживая методы сессии. Если relay
+// выключен, сервер только принимает дорожки (для записи) и никому их не отдает.
+func (s *SFU) NewSession(signal func(Signal), relay bool) *Session {
+	session := &Session{
+		sfu:    s,
+		relay:  relay,
+		peers:  make(map[string]*peer),
+		tracks: make(map[string]*forwardedTrack),
+		out:    newOutbox(signal),
+		done:   make(chan struct{}),
+	}
+	go session.requestKeyFrames()
+	return session
+}
@@ -2,32 +2,26 @@ package signaling
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"log"
 	"net/http"
+	"server/internal/auth"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 const (
-	writeWait      = 10 * time.Second
-	pongWait       = 60 * time.Second
-	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 65536
+	// CloseRoomClosed — код закрытия, когда комнату удалили или архивировали
+	CloseRoomClosed = 4410
+	// CloseRoomFull — в комнате не осталось мест
+	CloseRoomFull = 4429
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 var (
 	newline = []byte{'\n'}
 	space   = []byte{' '}
@@ -37,10 +31,64 @@ type MessageType string
 
 const (
 	MessageTypeChat           MessageType = "chat"
+	MessageTypeVideoChat      MessageType = "videochat"
 	MessageTypeOffer          MessageType = "offer"
 	MessageTypeAnswer         MessageType = "answer"
 	MessageTypeIceCandidate   MessageType = "ice-candidate"
 	MessageTypeVideoChatStart MessageType = "video-chat-start"
+	MessageTypeError          MessageType = "error"
+
+	// Perfect negotiation: конец ICE-кандидатов и просьба перезапустить ICE.
+	// Получатель ice-restart вызывает restartIce() и присылает новый offer.
+	MessageTypeEndOfCandidates MessageType = "end-of-candidates"
+	MessageTypeIceRestart      MessageType = "ice-restart"
+
+	// Состав сессии. new-user и user-left — первое и последнее подключение
+	// пользователя, device-joined и device-left — остальные его подключения.
+	MessageTypeRegister     MessageType = "register"
+	MessageTypeNewUser      MessageType = "new-user"
+	MessageTypeUserLeft     MessageType = "user-left"
+	MessageTypeDeviceJoined MessageType = "device-joined"
+	MessageTypeDeviceLeft   MessageType = "device-left"
+
+	// Обрыв сокета: участник переподключается, место за ним удерживается
+	MessageTypeReconnecting MessageType = "reconnecting"
+	MessageTypeReconnected  MessageType = "reconnected"
+
+	// Служебные кадры протокола версии 2
+	MessageTypeHello MessageType = "hello"
+	MessageTypeAck   MessageType = "ack"
+
+	// Лимит участников и лобби
+	MessageTypeRoomFull      MessageType = "room-full"
+	MessageTypeLobbyWait     MessageType = "lobby-wait"
+	MessageTypeLobbyRequest  MessageType = "lobby-request"
+	MessageTypeLobbyLeft     MessageType = "lobby-left"
+	MessageTypeLobbyAdmit    MessageType = "lobby-admit"
+	MessageTypeLobbyReject   MessageType = "lobby-reject"
+	MessageTypeLobbyRejected MessageType = "lobby-rejected"
+
+	// Запись: recording-start и recording-stop шлет владелец, recording —
+	// индикатор для всех. В комнате mesh на время записи сервер присылает
+	// offer от "sfu"; после recording с active=false это соединение закрыто.
+	MessageTypeRecordingStart MessageType = "recording-start"
+	MessageTypeRecordingStop  MessageType = "recording-stop"
+	MessageTypeRecording      MessageType = "recording"
+
+	// Состояние медиа: клиент сообщает о своих изменениях, сервер рассылает
+	// media-state. force-mute шлет модератор, и его же получает заглушаемый.
+	MessageTypeMute             MessageType = "mute"
+	MessageTypeUnmute           MessageType = "unmute"
+	MessageTypeVideoOff         MessageType = "video-off"
+	MessageTypeVideoOn          MessageType = "video-on"
+	MessageTypeScreenShareStart MessageType = "screen-share-start"
+	MessageTypeScreenShareStop  MessageType = "screen-share-stop"
+	MessageTypeHandRaise        MessageType = "hand-raise"
+	MessageTypeHandLower        MessageType = "hand-lower"
+	MessageTypeSpeakingStart    MessageType = "speaking-start"
+	MessageTypeSpeakingStop     MessageType = "speaking-stop"
+	MessageTypeForceMute        MessageType = "force-mute"
+	MessageTypeMediaState       MessageType = "media-state"
 )
 
 type Client struct {
@@ -48,7 +96,26 @@ type Client struct {
 	conn   *websocket.Conn
 	send   chan interface{}
 	id     string
+	name   string
 	roomID string
+	// connID — ID подключения: пользователь может быть в комнате с
+	// нескольких вкладок и устройств. При возобновлении его заменяет хаб.
+	connID string
+	// version — версия протокола, выбранная при подключении
+	version int
+	// moderator — клиент получает заявки из лобби; вычисляется при входе
+	moderator bool
+	// media — состояние медиа клиента; меняет только хаб
+	media MediaState
+
+	// resume — токен возобновления из запроса, resumeToken — выданный в register
+	resume      string
+	resumeToken string
+
+	// Код и причина закрытия, если хаб отключает клиента сам (kick, ban).
+	// Записываются хабом до close(send).
+	closeCode   int
+	closeReason string
 }
 
 type Message struct {
@@ -56,6 +123,13 @@ type Message struct {
 	From string `json:"from"`
 }
 
+// LobbyDecision — решение модератора по пользователю, ожидающему в лобби
+type LobbyDecision struct {
+	Type   string `json:"type"`
+	UserID string `json:"userId"`
+	Reason string `json:"reason,omitempty"`
+}
+
 type RTCSessionDescription struct {
 	Type string `json:"type"`
 	SDP  string `json:"sdp"`
@@ -74,21 +148,32 @@ type VideoChatMessage struct {
 	Answer       *RTCSessionDescription `json:"answer,omitempty"`
 	IceCandidate *RTCIceCandidate       `json:"iceCandidate,omitempty"`
 	From         string                 `json:"from"`
-	To           string                 `json:"to"`
+	// To — ID подключения или пользователя; сообщение пользователю получают
+	// все его подключения
+	To string `json:"to"`
+	// FromConnection — подключение отправителя, проставляет сервер. Ответ
+	// отправителю адресуют ему, а не пользователю.
+	FromConnection string `json:"fromConnection,omitempty"`
+	// Polite — роль получателя в паре с отправителем, проставляет сервер
+	Polite *bool `json:"polite,omitempty"`
 }
 
-var jwtSecret = []byte("myapp-super-secret-jwt-key-2024-development")
-
 func (c *Client) readPump() {
+	// leaving — клиент закрыл сокет сам; передается хабу вместе с unregister
+	var leaving bool
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- leaveInput{client: c, leaving: leaving}:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	ws := c.hub.cfg.WebSocket
+	c.conn.SetReadLimit(ws.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(ws.PongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.conn.SetReadDeadline(time.Now().Add(ws.PongWait))
 		return nil
 	})
 
@@ -98,63 +183,198 @@ func (c *Client) readPump() {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("error: %v", err)
 			}
+			leaving = websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
 			break
 		}
 
 		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
 
-		var typeCheck struct {
-			Type string `json:"type"`
-		}
-
-		if err := json.Unmarshal(message, &typeCheck); err != nil {
+		in, err := c.decode(message)
+		if err != nil {
 			log.Printf("Error parsing JSON: %v", err)
+			c.fail(inbound{}, ErrorBadRequest, "invalid JSON")
 			continue
 		}
+		if in.version != c.version {
+			c.fail(in, ErrorUnsupportedVersion, fmt.Sprintf("connection uses protocol version %d", c.version))
+			continue
+		}
+		if !c.dispatch(in) {
+			return
+		}
+	}
+}
 
-		switch typeCheck.Type {
-		case "chat":
-			var msg Message
-			if err := json.Unmarshal(message, &msg); err != nil {
-				log.Printf("Error parsing chat message: %v", err)
-				continue
-			}
-			c.hub.message <- &msg
+// dispatch передает сообщение клиента хабу; false — хаб завершился
+func (c *Client) dispatch(in inbound) bool {
+	switch in.typ {
+	case MessageTypeChat:
+		var msg Message
+		if err := json.Unmarshal(in.payload, &msg); err != nil {
+			log.Printf("Error parsing chat message: %v", err)
+			c.fail(in, ErrorBadRequest, "invalid chat message")
+			return true
+		}
+		msg.From = c.id
+		select {
+		case c.hub.message <- chatInput{client: c, id: in.id, msg: &msg}:
+		case <-c.hub.done:
+			return false
+		}
 
-		case "videochat":
-			var msg VideoChatMessage
-			if err := json.Unmarshal(message, &msg); err != nil {
-				log.Printf("Error parsing videochat message: %v", err)
-				continue
-			}
-			msg.From = c.id
-			c.hub.videochat <- &msg
+	case MessageTypeLobbyAdmit, MessageTypeLobbyReject:
+		var msg LobbyDecision
+		if err := json.Unmarshal(in.payload, &msg); err != nil {
+			log.Printf("Error parsing lobby message: %v", err)
+			c.fail(in, ErrorBadRequest, "invalid lobby decision")
+			return true
+		}
+		if msg.UserID == "" {
+			c.fail(in, ErrorBadRequest, "userId is required")
+			return true
+		}
+		select {
+		case c.hub.lobby <- lobbyInput{
+			client: c,
+			id:     in.id,
+			userID: msg.UserID,
+			admit:  in.typ == MessageTypeLobbyAdmit,
+			reason: msg.Reason,
+		}:
+		case <-c.hub.done:
+			return false
+		}
+
+	case MessageTypeRecordingStart, MessageTypeRecordingStop:
+		select {
+		case c.hub.record <- recordRequest{
+			client: c,
+			id:     in.id,
+			userID: c.id,
+			start:  in.typ == MessageTypeRecordingStart,
+		}:
+		case <-c.hub.done:
+			return false
+		}
+
+	case MessageTypeMute, MessageTypeUnmute, MessageTypeVideoOff, MessageTypeVideoOn,
+		MessageTypeScreenShareStart, MessageTypeScreenShareStop,
+		MessageTypeHandRaise, MessageTypeHandLower,
+		MessageTypeSpeakingStart, MessageTypeSpeakingStop:
+		select {
+		case c.hub.mediaUpdates <- mediaInput{client: c, id: in.id, typ: in.typ}:
+		case <-c.hub.done:
+			return false
+		}
+
+	case MessageTypeForceMute:
+		var msg struct {
+			UserID string `json:"userId"`
+		}
+		if err := json.Unmarshal(in.payload, &msg); err != nil {
+			log.Printf("Error parsing force-mute message: %v", err)
+			c.fail(in, ErrorBadRequest, "invalid force-mute message")
+			return true
+		}
+		if msg.UserID == "" {
+			c.fail(in, ErrorBadRequest, "userId is required")
+			return true
+		}
+		select {
+		case c.hub.mediaUpdates <- mediaInput{client: c, id: in.id, typ: in.typ, userID: msg.UserID}:
+		case <-c.hub.done:
+			return false
 		}
+
+	case MessageTypeVideoChat:
+		var msg VideoChatMessage
+		if err := json.Unmarshal(in.payload, &msg); err != nil {
+			log.Printf("Error parsing videochat message: %v", err)
+			c.fail(in, ErrorBadRequest, "invalid videochat message")
+			return true
+		}
+		if err := msg.validate(); err != nil {
+			c.fail(in, ErrorBadRequest, err.Error())
+			return true
+		}
+		msg.From = c.id
+		msg.Polite = nil
+		select {
+		case c.hub.videochat <- videoInput{client: c, id: in.id, msg: &msg}:
+		case <-c.hub.done:
+			return false
+		}
+
+	default:
+		c.fail(in, ErrorUnknownType, "unknown message type "+strconv.Quote(string(in.typ)))
+	}
+	return true
+}
+
+// fail отвечает ошибкой на некорректный кадр. Клиенты версии 1 таких
+// ответов не ждут, и для них кадр просто отбрасывается.
+func (c *Client) fail(in inbound, code ErrorCode, text string) {
+	if c.version < ProtocolV2 {
+		return
+	}
+	select {
+	case c.hub.replies <- reply{client: c, answer: AnswerType{
+		Type:      MessageTypeError,
+		RequestID: in.id,
+		Code:      code,
+		Error:     text,
+	}}:
+	case <-c.hub.done:
 	}
 }
 
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ws := c.hub.cfg.WebSocket
+	ticker := time.NewTicker(ws.PingPeriod())
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
 	}()
 
+	if c.version >= ProtocolV2 {
+		hello, err := c.hello()
+		if err != nil {
+			log.Printf("Error encoding hello: %v", err)
+			return
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(ws.WriteWait))
+		if err := c.conn.WriteMessage(websocket.TextMessage, hello); err != nil {
+			return
+		}
+	}
+
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(ws.WriteWait))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeCode != 0 {
+					closeMessage = websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
-			if err := c.conn.WriteJSON(message); err != nil {
+			data, err := c.encode(message)
+			if err != nil {
+				log.Printf("Error encoding message for %s: %v", c.id, err)
+				continue
+			}
+			if data == nil {
+				continue
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
 				return
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(ws.WriteWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -162,56 +382,69 @@ func (c *Client) writePump() {
 	}
 }
 
-func ServerWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+// ServerWs аутентифицирует подключение и проверяет членство в комнате и только
+// потом подключает клиента, чтобы посторонние запросы не создавали хабы.
+// До апгрейда выполняется только проверка доступа: хаб и членство в публичной
+// комнате появляются, когда сокет уже открыт.
+func ServerWs(rm *RoomManager, w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	roomID := vars["roomId"]
 
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		log.Println("Token not provided in WebSocket connection")
-		http.Error(w, "Token required", http.StatusUnauthorized)
+	version, err := ParseProtocolVersion(r.URL.Query().Get("v"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%v; supported versions: %v", err, ProtocolVersions), http.StatusBadRequest)
 		return
 	}
 
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
+	conn, identity, err := rm.ws.Upgrade(w, r, func(ctx context.Context, id *auth.Identity) error {
+		return rm.CheckAdmission(ctx, roomID, id.UserID)
 	})
-
-	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		log.Println("Invalid token claims")
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
-
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		log.Println("User ID not found in context")
-		conn.Close()
+		log.Printf("WebSocket connection to room %s rejected: %v", roomID, err)
 		return
 	}
 
-	log.Printf("User %s connecting to room %s", userID, roomID)
+	log.Printf("User %s connecting to room %s", identity.UserID, roomID)
 
 	client := &Client{
-		id:     userID,
-		roomID: roomID,
-		hub:    hub,
-		conn:   conn,
-		send:   make(chan interface{}, 256),
+		id:      identity.UserID,
+		name:    identity.UserName,
+		roomID:  roomID,
+		connID:  newConnectionID(),
+		version: version,
+		resume:  r.URL.Query().Get("resume"),
+		conn:    conn,
+		send:    make(chan interface{}, rm.cfg.WebSocket.SendBufferSize),
 	}
 
-	client.hub.register <- client
+	// Подключение держит пользователя в сети, даже пока его место в комнате
+	// удерживается после обрыва: сокет к тому времени уже новый
+	release := rm.presence.Connect(identity.UserID)
 
-	go client.writePump()
-	go client.readPump()
+	// Хаб мог опустеть и завершиться между получением и регистрацией —
+	// тогда берем новый
+	for {
+		hub, err := rm.GetOrCreateRoom(r.Context(), roomID, identity.UserID)
+		if err != nil {
+			log.Printf("User %s could not join room %s: %v", identity.UserID, roomID, err)
+			release()
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(CloseRoomClosed, "room closed"),
+				time.Now().Add(time.Second))
+			conn.Close()
+			return
+		}
+
+		client.hub = hub
+		select {
+		case hub.register <- client:
+			go client.writePump()
+			go func() {
+				defer release()
+				client.readPump()
+			}()
+			return
+		case <-hub.done:
+		}
+	}
 }
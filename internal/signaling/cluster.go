@@ -0,0 +1,262 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"server/internal/backplane"
+	"time"
+)
+
+// syncWait — сколько новый хаб ждет ответов других узлов на hello, прежде
+// чем принимать клиентов. Иначе первый вошедший на узле не увидит участников
+// других узлов в ответе register.
+const syncWait = 500 * time.Millisecond
+
+// eventKind — тип события комнаты, которым обмениваются узлы через шину
+type eventKind string
+
+const (
+	// hello — на узле поднялся хаб комнаты; остальные отвечают present и pending
+	eventHello eventKind = "hello"
+	// join и leave — подключение Conn пользователя вошло или вышло; узлы
+	// рассылают new-user и user-left или device-joined и device-left
+	eventJoin  eventKind = "join"
+	eventLeave eventKind = "leave"
+	// present — подключение другого узла, без уведомления клиентов
+	eventPresent eventKind = "present"
+	// synced — узел Node получил полный ответ на свой hello
+	eventSynced eventKind = "synced"
+
+	// wait и unwait — пользователь встал в лобби или покинул его (Admit — впущен)
+	eventWait   eventKind = "wait"
+	eventUnwait eventKind = "unwait"
+	// pending — ожидающий в лобби другого узла, без уведомления модераторов
+	eventPending eventKind = "pending"
+	// decide — решение модератора по ожидающему на другом узле
+	eventDecide eventKind = "decide"
+
+	// broadcast — сообщение всем участникам, signal — одному подключению
+	eventBroadcast eventKind = "broadcast"
+	eventSignal    eventKind = "signal"
+
+	// kick и close — отключение пользователя и закрытие комнаты
+	eventKick  eventKind = "kick"
+	eventClose eventKind = "close"
+
+	// media — состояние медиа подключения (в Payload); force-mute — модератор
+	// By заглушает подключения пользователя на другом узле
+	eventMedia     eventKind = "media"
+	eventForceMute eventKind = "force-mute"
+
+	// reconnecting и reconnected — у подключения оборвался сокет, и оно вернулось
+	// на удержанное место; при истечении ожидания приходит leave
+	eventReconnecting eventKind = "reconnecting"
+	eventReconnected  eventKind = "reconnected"
+)
+
+type event struct {
+	Kind   eventKind `json:"kind"`
+	Node   string    `json:"node,omitempty"`
+	UserID string    `json:"user_id,omitempty"`
+	// Conn — подключение участника, адресата signal или модератора в decide
+	Conn    string          `json:"conn,omitempty"`
+	By      string          `json:"by,omitempty"`
+	Admit   bool            `json:"admit,omitempty"`
+	Reason  string          `json:"reason,omitempty"`
+	Request string          `json:"request,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// remoteEvent — событие вместе с узлом-отправителем
+type remoteEvent struct {
+	node string
+	event
+}
+
+func roomTopic(roomID string) string {
+	return "room:" + roomID
+}
+
+// publishEvent отправляет событие хабам комнаты на других узлах.
+// Ошибка шины не мешает работе комнаты на своем узле, поэтому только логируется.
+func publishEvent(bus backplane.Backplane, roomID string, ev event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", ev.Kind, err)
+		return
+	}
+	if err := bus.Publish(context.Background(), roomTopic(roomID), data); err != nil {
+		log.Printf("Error publishing %s event for room %s: %v", ev.Kind, roomID, err)
+	}
+}
+
+func (h *Hub) publish(ev event) {
+	publishEvent(h.bus, h.roomID, ev)
+}
+
+// publishBroadcast рассылает сообщение участникам комнаты на других узлах
+func (h *Hub) publishBroadcast(message interface{}) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error encoding broadcast: %v", err)
+		return
+	}
+	h.publish(event{Kind: eventBroadcast, Payload: payload})
+}
+
+// publishTo передает сообщение подключению к другому узлу
+func (h *Hub) publishTo(connID string, message interface{}) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error encoding signal: %v", err)
+		return
+	}
+	h.publish(event{Kind: eventSignal, Conn: connID, Payload: payload})
+}
+
+// subscribe подписывает хаб на события комнаты и запрашивает у других
+// узлов их участников; пока ответы не получены, Run не принимает клиентов.
+// Вызывается до запуска Run.
+func (h *Hub) subscribe() error {
+	unsubscribe, err := h.bus.Subscribe(roomTopic(h.roomID), func(msg backplane.Message) {
+		var ev event
+		if err := json.Unmarshal(msg.Data, &ev); err != nil {
+			log.Printf("Invalid event for room %s from %s: %v", h.roomID, msg.Node, err)
+			return
+		}
+		select {
+		case h.remote <- remoteEvent{node: msg.Node, event: ev}:
+		case <-h.done:
+		}
+	})
+	if err != nil {
+		return err
+	}
+	h.unsubscribe = unsubscribe
+
+	peers, err := h.bus.Peers(context.Background(), roomTopic(h.roomID))
+	if err != nil {
+		log.Printf("Error counting nodes for room %s: %v", h.roomID, err)
+		return nil
+	}
+	if peers > 0 {
+		h.syncing = peers
+		h.publish(event{Kind: eventHello})
+	}
+	return nil
+}
+
+// handleRemote применяет событие другого узла к локальным клиентам
+func (h *Hub) handleRemote(ev remoteEvent) {
+	switch ev.Kind {
+	case eventHello:
+		for connID, client := range h.localClients() {
+			payload, err := json.Marshal(client.media)
+			if err != nil {
+				log.Printf("Error encoding media state: %v", err)
+			}
+			h.publish(event{Kind: eventPresent, UserID: client.id, Conn: connID, Payload: payload})
+		}
+		waiting := make(map[string]bool)
+		for _, client := range h.pending {
+			waiting[client.id] = true
+		}
+		for id := range waiting {
+			h.publish(event{Kind: eventPending, UserID: id})
+		}
+		h.publish(event{Kind: eventSynced, Node: ev.node})
+
+	case eventSynced:
+		if ev.Node == h.bus.NodeID() && h.syncing > 0 {
+			h.syncing--
+		}
+
+	case eventPresent:
+		h.peers[ev.Conn] = remotePeer{userID: ev.UserID, node: ev.node}
+		if state, ok := decodeMediaState(ev.Payload); ok {
+			h.peerMedia[ev.Conn] = state
+		}
+
+	case eventJoin:
+		h.peers[ev.Conn] = remotePeer{userID: ev.UserID, node: ev.node}
+		delete(h.peerMedia, ev.Conn)
+		h.announcePresence(ev.UserID, ev.Conn, true)
+
+	case eventLeave:
+		if h.peers[ev.Conn].node != ev.node {
+			return
+		}
+		delete(h.peers, ev.Conn)
+		delete(h.peerMedia, ev.Conn)
+		h.announcePresence(ev.UserID, ev.Conn, false)
+
+	case eventPending:
+		h.remotePending[ev.UserID] = ev.node
+
+	case eventWait:
+		h.remotePending[ev.UserID] = ev.node
+		h.notifyModerators(AnswerType{
+			Type:    MessageTypeLobbyRequest,
+			Clients: map[string]bool{ev.UserID: true},
+		})
+
+	case eventUnwait:
+		delete(h.remotePending, ev.UserID)
+		h.notifyModerators(AnswerType{
+			Type:    MessageTypeLobbyLeft,
+			Clients: map[string]bool{ev.UserID: ev.Admit},
+		})
+
+	case eventDecide:
+		if len(h.waiting(ev.UserID)) > 0 {
+			h.settle(ev.UserID, ev.Admit, ev.Reason, ev.By, ev.Conn, ev.Request)
+		}
+
+	case eventBroadcast:
+		h.broadcast(ev.Payload)
+
+	case eventSignal:
+		if client, ok := h.clients[ev.Conn]; ok {
+			h.deliver(client, ev.Payload)
+		} else {
+			h.queue(ev.Conn, ev.Payload)
+		}
+
+	case eventReconnecting, eventReconnected:
+		if h.peers[ev.Conn].node != ev.node {
+			return
+		}
+		typ := MessageTypeReconnecting
+		if ev.Kind == eventReconnected {
+			typ = MessageTypeReconnected
+		}
+		h.broadcast(AnswerType{
+			Type:         typ,
+			Clients:      map[string]bool{ev.UserID: ev.Kind == eventReconnected},
+			ConnectionID: ev.Conn,
+		})
+
+	case eventMedia:
+		if h.peers[ev.Conn].node != ev.node {
+			return
+		}
+		if state, ok := decodeMediaState(ev.Payload); ok {
+			h.peerMedia[ev.Conn] = state
+			h.broadcast(AnswerType{Type: MessageTypeMediaState, UserID: ev.UserID, ConnectionID: ev.Conn, MediaState: &state})
+		}
+
+	case eventForceMute:
+		h.muteLocal(ev.UserID, ev.By)
+
+	case eventKick:
+		h.kickLocal(kickRequest{userID: ev.UserID, reason: ev.Reason})
+
+	case eventClose:
+		if h.manager != nil {
+			h.manager.closeHub(h, ev.Reason)
+		} else {
+			h.shutdown(CloseRoomClosed, ev.Reason)
+		}
+	}
+}
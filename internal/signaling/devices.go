@@ -0,0 +1,145 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
+
+// remotePeer — подключение пользователя к другому узлу
+type remotePeer struct {
+	userID string
+	node   string
+}
+
+// randomID — случайный идентификатор из size байт в hex; пустой, если
+// генератор недоступен
+func randomID(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating random ID: %v", err)
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// newConnectionID выдает ID подключению. Один пользователь может войти в
+// комнату с нескольких вкладок и устройств, и у каждого подключения свой ID.
+func newConnectionID() string {
+	return randomID(8)
+}
+
+// devices — подключения пользователя к этому узлу
+func (h *Hub) devices(userID string) []*Client {
+	var result []*Client
+	for _, client := range h.clients {
+		if client.id == userID {
+			result = append(result, client)
+		}
+	}
+	return result
+}
+
+// waiting — подключения пользователя, ожидающие в лобби этого узла
+func (h *Hub) waiting(userID string) []*Client {
+	var result []*Client
+	for _, client := range h.pending {
+		if client.id == userID {
+			result = append(result, client)
+		}
+	}
+	return result
+}
+
+// connectedElsewhere сообщает, что пользователь подключен и к другим узлам
+func (h *Hub) connectedElsewhere(userID string) bool {
+	for _, p := range h.peers {
+		if p.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// localClients — подключения этого узла, включая те, чье место удерживается
+func (h *Hub) localClients() map[string]*Client {
+	result := make(map[string]*Client, len(h.clients)+len(h.held))
+	for connID, slot := range h.held {
+		result[connID] = slot.client
+	}
+	for connID, client := range h.clients {
+		result[connID] = client
+	}
+	return result
+}
+
+// connections — подключения к комнате на всех узлах: ID подключения -> пользователь
+func (h *Hub) connections(except string) map[string]string {
+	result := make(map[string]string)
+	for connID, p := range h.peers {
+		result[connID] = p.userID
+	}
+	for connID, client := range h.localClients() {
+		result[connID] = client.id
+	}
+	delete(result, except)
+	return result
+}
+
+// deviceCounts — сколько подключений у каждого участника на всех узлах
+func (h *Hub) deviceCounts() map[string]int {
+	result := make(map[string]int)
+	for _, userID := range h.connections("") {
+		result[userID]++
+	}
+	return result
+}
+
+// targets — подключения адресата сигнального сообщения: to — ID подключения
+// или пользователя, тогда сообщение получают все его подключения
+func (h *Hub) targets(to string) []string {
+	conns := h.connections("")
+	if _, ok := conns[to]; ok {
+		return []string{to}
+	}
+	var result []string
+	for connID, userID := range conns {
+		if userID == to {
+			result = append(result, connID)
+		}
+	}
+	return result
+}
+
+// announcePresence сообщает клиентам этого узла, что подключение connID
+// пользователя userID вошло или вышло. О первом и последнем подключении
+// пользователя приходят new-user и user-left, об остальных — device-joined
+// и device-left; devices — сколько подключений у пользователя теперь.
+func (h *Hub) announcePresence(userID, connID string, joined bool) {
+	count := h.deviceCounts()[userID]
+
+	var typ MessageType
+	switch {
+	case joined && count <= 1:
+		typ = MessageTypeNewUser
+	case joined:
+		typ = MessageTypeDeviceJoined
+	case count == 0:
+		typ = MessageTypeUserLeft
+	default:
+		typ = MessageTypeDeviceLeft
+	}
+
+	message := AnswerType{
+		Type:         typ,
+		Clients:      map[string]bool{userID: count > 0},
+		ConnectionID: connID,
+		Devices:      map[string]int{userID: count},
+	}
+	for id, client := range h.clients {
+		if id == connID {
+			continue
+		}
+		h.deliver(client, message)
+	}
+}
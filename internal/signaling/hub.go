@@ -1,21 +1,123 @@
 package signaling
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"errors"
 	"log"
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/ice"
+	"server/internal/recording"
+	"server/internal/sfu"
+	"server/internal/store"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 type Hub struct {
 	roomID     string
-	clients    map[string]*Client
+	clients    map[string]*Client // ID подключения -> клиент
+	pending    map[string]*Client // ожидающие допуска в лобби, по ID подключения
 	register   chan *Client
-	unregister chan *Client
-	message    chan *Message
+	unregister chan leaveInput
+	message    chan chatInput
 	messages   []Message
-	videochat  chan *VideoChatMessage
+	videochat  chan videoInput
+	replies    chan reply
+	kick       chan kickRequest
+	lobby      chan lobbyInput
 	manager    *RoomManager
-	db         *sql.DB
+
+	// Состояние медиа участников; у локальных оно хранится в Client
+	mediaUpdates chan mediaInput
+	peerMedia    map[string]MediaState // подключения других узлов
+
+	// Места подключений с оборванным сокетом, ждущие переподключения
+	held   map[string]*heldSlot
+	expire chan heldExpiry
+
+	// Состояние комнаты на других узлах, по событиям шины
+	bus           backplane.Backplane
+	remote        chan remoteEvent
+	peers         map[string]remotePeer // ID подключения -> пользователь и узел
+	remotePending map[string]string     // ожидающие в лобби на других узлах, userID -> узел
+	syncing       int                   // сколько узлов еще не ответили на hello
+	unsubscribe   func()
+
+	// Медиа сессии: mesh или SFU; выбирается при первом входе
+	sfu        *sfu.SFU
+	media      store.MediaMode
+	session    *sfu.Session
+	sfuSignals chan sessionSignal
+	ice        *ice.Provider
+
+	// Запись сессии; recordOnly — сессия SFU поднята только для записи
+	recorder   *recording.Manager
+	recording  *recording.Recording
+	recordedBy string
+	recordOnly bool
+	record     chan recordRequest
+
+	// quit закрывается в shutdown, done — когда Run завершился.
+	// Все отправки в каналы хаба должны учитывать done.
+	// stopped закрывается последним, когда запись завершена и сессия закрыта.
+	quit        chan struct{}
+	done        chan struct{}
+	stopped     chan struct{}
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+
+	rooms  store.RoomStore
+	access *access.Checker
+	cfg    *config.Config
+}
+
+// chatInput — сообщение чата комнаты вместе с отправителем; id — ID
+// запроса, на который клиенту версии 2 приходит ack или error
+type chatInput struct {
+	client *Client
+	id     string
+	msg    *Message
+}
+
+// leaveInput — сокет клиента закрылся; leaving — клиент закрыл его сам,
+// и его место не удерживается
+type leaveInput struct {
+	client  *Client
+	leaving bool
+}
+
+// videoInput — сигнальное сообщение WebRTC вместе с отправителем
+type videoInput struct {
+	client *Client
+	id     string
+	msg    *VideoChatMessage
+}
+
+// reply — ответ на кадр, который клиент не смог передать хабу
+type reply struct {
+	client *Client
+	answer AnswerType
+}
+
+// kickRequest — принудительное отключение пользователя от комнаты
+type kickRequest struct {
+	userID string
+	reason string
+}
+
+// lobbyInput — решение модератора: впустить или отклонить ожидающего
+type lobbyInput struct {
+	client *Client
+	id     string
+	userID string
+	admit  bool
+	reason string
 }
 
 type ChatMessage struct {
@@ -27,6 +129,40 @@ type AnswerType struct {
 	Messages []Message       `json:"messages,omitempty"`
 	Type     MessageType     `json:"type"`
 	Clients  map[string]bool `json:"clients,omitempty"`
+	// ConnectionID — подключение получателя в ответе register, подключение
+	// участника в new-user, user-left, device-joined, device-left и media-state
+	ConnectionID string `json:"connectionId,omitempty"`
+	// Connections — подключения остальных к комнате (ID подключения ->
+	// пользователь), Devices — сколько подключений у каждого пользователя
+	Connections map[string]string `json:"connections,omitempty"`
+	Devices     map[string]int    `json:"devices,omitempty"`
+	// Pending — ожидающие в лобби; приходит только модераторам
+	Pending map[string]bool `json:"pending,omitempty"`
+	// Limit — лимит участников комнаты, в ответе room-full
+	Limit int `json:"limit,omitempty"`
+	// Media — режим медиа сессии, в ответе register. В режиме sfu клиент
+	// ждет offer от "sfu" вместо соединений с каждым участником.
+	Media store.MediaMode `json:"media,omitempty"`
+	// ICEServers — STUN/TURN для RTCPeerConnection, в ответе register
+	ICEServers []ice.Server `json:"iceServers,omitempty"`
+	// Polite — роль получателя в паре с каждым подключением (см. Polite), в ответе register
+	Polite map[string]bool `json:"polite,omitempty"`
+	// Recording — индикатор записи, в ответах register и recording
+	Recording *RecordingState `json:"recording,omitempty"`
+	// UserID и MediaState — чье состояние медиа изменилось, в ответе media-state
+	UserID     string      `json:"userId,omitempty"`
+	MediaState *MediaState `json:"mediaState,omitempty"`
+	// MediaStates — состояние медиа остальных подключений, в ответе register
+	MediaStates map[string]MediaState `json:"mediaStates,omitempty"`
+	// Resume — токен возобновления сессии, в ответе register
+	Resume *ResumeInfo `json:"resume,omitempty"`
+	// By — модератор, в ответе force-mute
+	By    string `json:"by,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Code — код ошибки в ответе error
+	Code ErrorCode `json:"code,omitempty"`
+	// RequestID — ID запроса клиента, на который отвечают ack или error
+	RequestID string `json:"requestId,omitempty"`
 }
 
 type AnswerVideoChatType struct {
@@ -34,139 +170,630 @@ type AnswerVideoChatType struct {
 	Data VideoChatMessage `json:"data"`
 }
 
-func NewHub(db *sql.DB) *Hub {
+// NewHub создает хаб комнаты; media — nil, если SFU на сервере выключен
+func NewHub(rooms store.RoomStore, checker *access.Checker, cfg *config.Config, bus backplane.Backplane,
+	media *sfu.SFU, iceServers *ice.Provider, recorder *recording.Manager) *Hub {
 	return &Hub{
 		register:   make(chan *Client),
-		unregister: make(chan *Client),
+		unregister: make(chan leaveInput),
 		clients:    make(map[string]*Client),
+		pending:    make(map[string]*Client),
 		messages:   make([]Message, 0),
-		message:    make(chan *Message),
-		videochat:  make(chan *VideoChatMessage),
-		db:         db,
+		message:    make(chan chatInput),
+		videochat:  make(chan videoInput),
+		replies:    make(chan reply),
+		kick:       make(chan kickRequest),
+		lobby:      make(chan lobbyInput),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		bus:        bus,
+		remote:     make(chan remoteEvent),
+		peers:      make(map[string]remotePeer),
+		sfu:        media,
+		sfuSignals: make(chan sessionSignal),
+		ice:        iceServers,
+		recorder:   recorder,
+		record:     make(chan recordRequest),
+		rooms:      rooms,
+		access:     checker,
+		cfg:        cfg,
+
+		remotePending: make(map[string]string),
+		mediaUpdates:  make(chan mediaInput),
+		peerMedia:     make(map[string]MediaState),
+		held:          make(map[string]*heldSlot),
+		expire:        make(chan heldExpiry),
 	}
 }
 
+// shutdown отключает всех клиентов с кодом и причиной и завершает хаб
+func (h *Hub) shutdown(code int, reason string) {
+	h.closeOnce.Do(func() {
+		h.closeCode = code
+		h.closeReason = reason
+		close(h.quit)
+	})
+}
+
 func (h *Hub) Run() {
+	defer func() {
+		close(h.done)
+		if h.unsubscribe != nil {
+			h.unsubscribe()
+		}
+		if h.recording != nil {
+			h.finishRecording()
+		}
+		if h.session != nil {
+			h.session.Close()
+		}
+		close(h.stopped)
+	}()
+
+	var syncTimeout <-chan time.Time
+	if h.syncing > 0 {
+		timer := time.NewTimer(syncWait)
+		defer timer.Stop()
+		syncTimeout = timer.C
+	}
+
 	for {
+		// Пока другие узлы не прислали участников, новые клиенты ждут
+		register := h.register
+		if h.syncing > 0 {
+			register = nil
+		}
+
 		select {
-		case client := <-h.register:
-			log.Printf("Client %s registered", client.id)
-			h.clients[client.id] = client
-
-			// Уведомляем нового клиента о существующих участниках
-			existingClients := make(map[string]bool)
-			for id := range h.clients {
-				if id != client.id {
-					existingClients[id] = true
-				}
+		case <-syncTimeout:
+			if h.syncing > 0 {
+				log.Printf("Room %s: %d nodes did not answer in time", h.roomID, h.syncing)
+				h.syncing = 0
 			}
 
-			// Отправляем новому клиенту список существующих участников
-			newAnswer := AnswerType{
-				Type:     "register",
-				Messages: h.messages,
-				Clients:  existingClients,
+		case <-h.quit:
+			for id, client := range h.clients {
+				client.closeCode = h.closeCode
+				client.closeReason = h.closeReason
+				delete(h.clients, id)
+				close(client.send)
 			}
-			client.send <- newAnswer
-
-			// Уведомляем всех остальных о новом участнике
-			newUserMessage := AnswerType{
-				Type:    "new-user",
-				Clients: map[string]bool{client.id: true},
+			for id, client := range h.pending {
+				client.closeCode = h.closeCode
+				client.closeReason = h.closeReason
+				delete(h.pending, id)
+				close(client.send)
 			}
-			for id, c := range h.clients {
-				if id != client.id {
-					select {
-					case c.send <- newUserMessage:
-					default:
-						close(c.send)
-						delete(h.clients, id)
-					}
-				}
+			for id := range h.held {
+				h.unhold(id)
 			}
+			return
 
-		case msg := <-h.message:
-			var messagesJSON json.RawMessage
-			err := h.db.QueryRow(`SELECT COALESCE(chat_messages, '[]'::json) FROM rooms WHERE id = $1`, h.roomID).Scan(&messagesJSON)
-			if err != nil {
-				log.Printf("Error getting chat messages: %v", err)
+		case client := <-register:
+			h.join(client)
+			if h.releaseIfEmpty() {
+				return
+			}
+
+		case in := <-h.message:
+			if !h.member(in.client, in.id) {
 				continue
 			}
-			var currentMessages []Message
-			if err := json.Unmarshal(messagesJSON, &currentMessages); err != nil {
-				log.Printf("Error parsing chat messages: %v", err)
+			_, err := h.access.Require(context.Background(), store.ScopeRoom, h.roomID, in.client.id, access.PermSendMessage)
+			if err != nil {
+				if !errors.Is(err, auth.ErrForbidden) {
+					log.Printf("Error checking chat permission: %v", err)
+					h.sendError(in.client, in.id, ErrorInternal, "internal error")
+					continue
+				}
+				h.sendError(in.client, in.id, ErrorForbidden, err.Error())
 				continue
 			}
-			currentMessages = append(currentMessages, *msg)
-			updatedJSON, _ := json.Marshal(currentMessages)
-			_, err = h.db.Exec(`UPDATE rooms SET chat_messages = $1::json WHERE id = $2`, updatedJSON, h.roomID)
+
+			stored, err := h.rooms.AppendMessage(context.Background(), h.roomID, store.RoomMessage(*in.msg))
 			if err != nil {
 				log.Printf("Error updating chat messages: %v", err)
+				h.sendError(in.client, in.id, ErrorInternal, "message was not saved")
 				continue
 			}
+			currentMessages := make([]Message, 0, len(stored))
+			for _, m := range stored {
+				currentMessages = append(currentMessages, Message(m))
+			}
 			newAnswer := AnswerType{
-				Type:     "chat",
+				Type:     MessageTypeChat,
 				Messages: currentMessages,
 				Clients:  h.getActiveClients(),
 			}
 			h.broadcast(newAnswer)
+			h.publishBroadcast(newAnswer)
+			h.ack(in.client, in.id)
 
-		case client := <-h.unregister:
-			if _, ok := h.clients[client.id]; ok {
-				log.Printf("Client %s unregistered", client.id)
-				delete(h.clients, client.id)
-				close(client.send)
-
-				// Уведомляем остальных об отключении
-				userLeftMessage := AnswerType{
-					Type:    "user-left",
-					Clients: map[string]bool{client.id: false},
+		case in := <-h.unregister:
+			client := in.client
+			if h.pending[client.connID] == client {
+				delete(h.pending, client.connID)
+				// Пользователь остается в лобби, пока ждет хотя бы одно его подключение
+				if len(h.waiting(client.id)) == 0 {
+					h.notifyModerators(AnswerType{
+						Type:    MessageTypeLobbyLeft,
+						Clients: map[string]bool{client.id: false},
+					})
+					h.publish(event{Kind: eventUnwait, UserID: client.id})
 				}
-				h.broadcast(userLeftMessage)
+			}
+			if h.clients[client.connID] == client {
+				log.Printf("Client %s unregistered (connection %s)", client.id, client.connID)
+				h.drop(client, in.leaving)
+			} else if slot := h.held[client.connID]; in.leaving && slot != nil && slot.client == client {
+				// Хаб уже отключил медленного клиента и держит его место,
+				// но клиент ушел сам: место освобождается сразу
+				h.unhold(client.connID)
+				h.leave(client)
+			}
+			h.leaveMedia(client.connID)
+			if h.releaseIfEmpty() {
+				return
+			}
 
-				if len(h.clients) == 0 && h.manager != nil {
-					h.manager.RemoveRoomIfEmpty(h.roomID)
-				}
+		case exp := <-h.expire:
+			h.expireHeld(exp)
+			if h.releaseIfEmpty() {
+				return
 			}
 
-		case videoMsg := <-h.videochat:
-			log.Printf("Video message from %s to %s", videoMsg.From, videoMsg.To)
+		case in := <-h.lobby:
+			h.decide(in)
 
-			// Находим получателя
-			if targetClient, ok := h.clients[videoMsg.To]; ok {
-				answer := AnswerVideoChatType{
-					Type: "videochat",
-					Data: *videoMsg,
-				}
+		case ev := <-h.remote:
+			h.handleRemote(ev)
+			if ev.Kind == eventKick && h.releaseIfEmpty() {
+				return
+			}
 
-				select {
-				case targetClient.send <- answer:
-				default:
-					close(targetClient.send)
-					delete(h.clients, videoMsg.To)
-				}
-			} else {
+		case req := <-h.kick:
+			h.kickLocal(req)
+			if h.releaseIfEmpty() {
+				return
+			}
+
+		case sig := <-h.sfuSignals:
+			h.deliverSFUSignal(sig)
+
+		case req := <-h.record:
+			h.handleRecord(req)
+
+		case in := <-h.mediaUpdates:
+			h.handleMedia(in)
+
+		case r := <-h.replies:
+			if h.clients[r.client.connID] == r.client || h.pending[r.client.connID] == r.client {
+				h.send(r.client, r.answer)
+			}
+
+		case in := <-h.videochat:
+			if !h.member(in.client, in.id) {
+				continue
+			}
+			videoMsg := in.msg
+			videoMsg.FromConnection = in.client.connID
+			log.Printf("Video message from %s to %s", videoMsg.FromConnection, videoMsg.To)
+
+			if videoMsg.To == SFUPeerID {
+				h.handleSFUMessage(in)
+				continue
+			}
+
+			if !h.relay(videoMsg) {
 				log.Printf("Target client %s not found", videoMsg.To)
+				h.sendError(in.client, in.id, ErrorNotFound, "user is not in the room")
+				continue
 			}
+			h.ack(in.client, in.id)
 		}
 	}
 }
 
-func (h *Hub) broadcast(message interface{}) {
-	for id, client := range h.clients {
-		select {
-		case client.send <- message:
-		default:
-			close(client.send)
-			delete(h.clients, id)
+// relay передает сигнальное сообщение каждому подключению адресата, на этом
+// или другом узле; false — адресата нет в комнате
+func (h *Hub) relay(msg *VideoChatMessage) bool {
+	targets := h.targets(msg.To)
+	if len(targets) == 0 {
+		return false
+	}
+	for _, connID := range targets {
+		if connID == msg.FromConnection {
+			continue
+		}
+		data := *msg
+		polite := Polite(connID, msg.FromConnection)
+		data.Polite = &polite
+		answer := AnswerVideoChatType{
+			Type: MessageTypeVideoChat,
+			Data: data,
+		}
+
+		if targetClient, ok := h.clients[connID]; ok {
+			h.deliver(targetClient, answer)
+		} else if !h.queue(connID, answer) {
+			// Получатель подключен к другому узлу
+			h.publishTo(connID, answer)
+		}
+	}
+	return true
+}
+
+// join решает, куда попадает новый клиент: в комнату, в лобби или получает
+// отказ, если мест нет. Владельцы и модераторы проходят мимо лобби.
+func (h *Hub) join(client *Client) {
+	room, err := h.rooms.Get(context.Background(), h.roomID)
+	if err != nil {
+		log.Printf("Error loading room %s: %v", h.roomID, err)
+		h.reject(client, AnswerType{Type: MessageTypeError, Code: ErrorUnavailable, Error: "room is not available"}, CloseRoomClosed, "room closed")
+		return
+	}
+
+	a, err := h.access.Resolve(context.Background(), store.ScopeRoom, h.roomID, client.id)
+	if err != nil {
+		log.Printf("Error resolving access to room %s: %v", h.roomID, err)
+		h.reject(client, AnswerType{Type: MessageTypeError, Code: ErrorInternal, Error: "internal error"}, websocket.CloseInternalServerErr, "internal error")
+		return
+	}
+	client.moderator = a.Can(access.PermModerate)
+	h.startMedia(room)
+
+	// Пользователь, уже вошедший в сессию с другого подключения или
+	// возобновляющий свое, проходит мимо лобби
+	slot := h.resumable(client)
+	rejoin := slot != nil || h.deviceCounts()[client.id] > 0
+	if room.Lobby && !client.moderator && !rejoin {
+		log.Printf("Client %s is waiting in the lobby of room %s", client.id, h.roomID)
+		first := len(h.waiting(client.id)) == 0
+		h.pending[client.connID] = client
+		client.send <- AnswerType{Type: MessageTypeLobbyWait}
+		if first {
+			h.notifyModerators(AnswerType{
+				Type:    MessageTypeLobbyRequest,
+				Clients: map[string]bool{client.id: true},
+			})
+			h.publish(event{Kind: eventWait, UserID: client.id})
 		}
+		return
+	}
+
+	if limit := h.limit(room); h.full(client.id, limit) {
+		log.Printf("Client %s rejected: room %s is full", client.id, h.roomID)
+		h.reject(client, AnswerType{Type: MessageTypeRoomFull, Error: "room is full", Limit: limit}, CloseRoomFull, "room full")
+		return
+	}
+
+	if slot != nil {
+		h.unhold(slot.client.connID)
+		h.resume(client, slot)
+		return
+	}
+	h.enter(client)
+}
+
+// enter добавляет клиента в сессию и рассылает участникам new-user или
+// device-joined, если пользователь уже в сессии с другого подключения
+func (h *Hub) enter(client *Client) {
+	log.Printf("Client %s registered (connection %s)", client.id, client.connID)
+	h.clients[client.connID] = client
+
+	// Отправляем новому клиенту список существующих участников
+	client.send <- h.registerAnswer(client, false)
+
+	// Уведомляем всех остальных о новом подключении
+	h.announcePresence(client.id, client.connID, true)
+	h.publish(event{Kind: eventJoin, UserID: client.id, Conn: client.connID})
+	h.joinMedia(client)
+}
+
+// registerAnswer — ответ register вошедшему клиенту: участники, в том числе
+// на других узлах, и состояние сессии; resumed — клиент вернулся на свое место
+func (h *Hub) registerAnswer(client *Client, resumed bool) AnswerType {
+	existingClients := h.getActiveClients()
+	delete(existingClients, client.id)
+
+	connections := h.connections(client.connID)
+	newAnswer := AnswerType{
+		Type:         MessageTypeRegister,
+		Messages:     h.messages,
+		Clients:      existingClients,
+		ConnectionID: client.connID,
+		Connections:  connections,
+		Devices:      h.deviceCounts(),
+		Media:        h.media,
+		Recording:    h.recordingState(),
+		MediaStates:  h.mediaStates(client.connID),
+		Resume:       h.resumeInfo(client, resumed),
+	}
+	if len(connections) > 0 {
+		newAnswer.Polite = make(map[string]bool, len(connections))
+		for connID := range connections {
+			newAnswer.Polite[connID] = Polite(client.connID, connID)
+		}
+	}
+	if h.ice != nil {
+		newAnswer.ICEServers = h.ice.Servers(client.id)
+	}
+	if client.moderator && len(h.pending)+len(h.remotePending) > 0 {
+		newAnswer.Pending = make(map[string]bool)
+		for id := range h.pending {
+			newAnswer.Pending[id] = true
+		}
+		for id := range h.remotePending {
+			newAnswer.Pending[id] = true
+		}
+	}
+	return newAnswer
+}
+
+// decide применяет решение модератора по ожидающему в лобби
+func (h *Hub) decide(in lobbyInput) {
+	if !h.member(in.client, in.id) {
+		return
+	}
+	_, err := h.access.Require(context.Background(), store.ScopeRoom, h.roomID, in.client.id, access.PermModerate)
+	if err != nil {
+		if !errors.Is(err, auth.ErrForbidden) {
+			log.Printf("Error checking lobby permission: %v", err)
+			h.sendError(in.client, in.id, ErrorInternal, "internal error")
+			return
+		}
+		h.sendError(in.client, in.id, ErrorForbidden, err.Error())
+		return
+	}
+
+	if len(h.waiting(in.userID)) > 0 {
+		h.settle(in.userID, in.admit, in.reason, in.client.id, in.client.connID, in.id)
+		return
+	}
+	if _, ok := h.remotePending[in.userID]; ok {
+		// Решение применит узел, к которому подключен ожидающий; он же ответит на запрос
+		h.publish(event{
+			Kind:    eventDecide,
+			UserID:  in.userID,
+			Admit:   in.admit,
+			Reason:  in.reason,
+			By:      in.client.id,
+			Conn:    in.client.connID,
+			Request: in.id,
+		})
+		return
+	}
+	h.sendError(in.client, in.id, ErrorNotFound, "user is not waiting in the lobby")
+}
+
+// settle впускает или отклоняет все подключения ожидающего на этом узле;
+// by — модератор, возможно подключенный к другому узлу, которому на
+// подключение conn отвечают на запрос request
+func (h *Hub) settle(userID string, admit bool, reason, by, conn, request string) {
+	waiting := h.waiting(userID)
+
+	if !admit {
+		if reason == "" {
+			reason = "rejected"
+		}
+		log.Printf("Client %s rejected from the lobby of room %s by %s", userID, h.roomID, by)
+		for _, client := range waiting {
+			delete(h.pending, client.connID)
+			h.reject(client, AnswerType{Type: MessageTypeLobbyRejected, Error: reason}, auth.CloseForbidden, reason)
+		}
+		h.notifyModerators(AnswerType{
+			Type:    MessageTypeLobbyLeft,
+			Clients: map[string]bool{userID: false},
+		})
+		h.publish(event{Kind: eventUnwait, UserID: userID})
+		h.ackTo(conn, request)
+		return
+	}
+
+	room, err := h.rooms.Get(context.Background(), h.roomID)
+	if err != nil {
+		log.Printf("Error loading room %s: %v", h.roomID, err)
+		h.sendErrorTo(conn, request, ErrorInternal, "internal error")
+		return
+	}
+	// При нехватке мест пользователь остается в лобби
+	if h.full(userID, h.limit(room)) {
+		h.sendErrorTo(conn, request, ErrorRoomFull, "room is full")
+		return
+	}
+
+	log.Printf("Client %s admitted to room %s by %s", userID, h.roomID, by)
+	for _, client := range waiting {
+		delete(h.pending, client.connID)
+	}
+	h.notifyModerators(AnswerType{
+		Type:    MessageTypeLobbyLeft,
+		Clients: map[string]bool{userID: true},
+	})
+	h.publish(event{Kind: eventUnwait, UserID: userID, Admit: true})
+	for _, client := range waiting {
+		h.enter(client)
+	}
+	h.ackTo(conn, request)
+}
+
+// kickLocal отключает все подключения пользователя к комнате на этом узле, в том числе из лобби
+func (h *Hub) kickLocal(req kickRequest) {
+	if waiting := h.waiting(req.userID); len(waiting) > 0 {
+		for _, client := range waiting {
+			delete(h.pending, client.connID)
+			h.reject(client, AnswerType{Type: MessageTypeLobbyRejected, Error: req.reason}, auth.CloseForbidden, req.reason)
+		}
+		h.notifyModerators(AnswerType{
+			Type:    MessageTypeLobbyLeft,
+			Clients: map[string]bool{req.userID: false},
+		})
+		h.publish(event{Kind: eventUnwait, UserID: req.userID})
+	}
+	for connID, slot := range h.held {
+		if slot.client.id == req.userID {
+			log.Printf("Held slot of %s in room %s released: %s", req.userID, h.roomID, req.reason)
+			h.unhold(connID)
+			h.leave(slot.client)
+		}
+	}
+
+	for _, client := range h.devices(req.userID) {
+		log.Printf("Client %s kicked from room %s: %s", client.id, h.roomID, req.reason)
+		client.closeCode = auth.CloseForbidden
+		client.closeReason = req.reason
+		delete(h.clients, client.connID)
+		close(client.send)
+
+		h.leave(client)
+	}
+}
+
+// limit — действующий лимит участников: настройка комнаты, но не больше общего
+func (h *Hub) limit(room store.Room) int {
+	if room.MaxParticipants > 0 && room.MaxParticipants < h.cfg.Rooms.MaxParticipants {
+		return room.MaxParticipants
+	}
+	return h.cfg.Rooms.MaxParticipants
+}
+
+// full сообщает, что для пользователя нет места; повторное подключение
+// уже присутствующего пользователя место не занимает. Участники других
+// узлов учитываются по событиям шины, поэтому при одновременном входе
+// на разных узлах лимит может быть превышен.
+func (h *Hub) full(userID string, limit int) bool {
+	active := h.getActiveClients()
+	if active[userID] {
+		return false
+	}
+	return limit > 0 && len(active) >= limit
+}
+
+// reject отправляет клиенту, не вошедшему в сессию, ответ и закрывает соединение
+func (h *Hub) reject(client *Client, answer AnswerType, code int, reason string) {
+	select {
+	case client.send <- answer:
+	default:
+	}
+	client.closeCode = code
+	client.closeReason = reason
+	close(client.send)
+}
+
+// member проверяет, что запрос пришел от участника сессии. Ожидающему в
+// лобби отвечает ошибкой, запросы уже отключенных клиентов отбрасывает.
+func (h *Hub) member(client *Client, id string) bool {
+	if h.clients[client.connID] == client {
+		return true
+	}
+	if h.pending[client.connID] == client {
+		h.sendError(client, id, ErrorForbidden, "waiting in the lobby")
+	}
+	return false
+}
+
+// send отправляет клиенту ответ; при переполненном буфере ответ теряется
+func (h *Hub) send(client *Client, answer AnswerType) {
+	select {
+	case client.send <- answer:
+	default:
+	}
+}
+
+// sendTo отправляет ответ подключению на этом или другом узле
+func (h *Hub) sendTo(connID string, answer AnswerType) {
+	if client, ok := h.clients[connID]; ok {
+		h.send(client, answer)
+		return
+	}
+	if h.queue(connID, answer) {
+		return
+	}
+	h.publishTo(connID, answer)
+}
+
+// ack подтверждает выполненный запрос. Запросы без id и клиенты версии 1
+// подтверждений не получают.
+func (h *Hub) ack(client *Client, id string) {
+	if id != "" {
+		h.send(client, AnswerType{Type: MessageTypeAck, RequestID: id})
+	}
+}
+
+func (h *Hub) ackTo(connID, id string) {
+	if id != "" {
+		h.sendTo(connID, AnswerType{Type: MessageTypeAck, RequestID: id})
+	}
+}
+
+// sendError отвечает ошибкой на запрос id; пустой id — ошибка не связана с запросом
+func (h *Hub) sendError(client *Client, id string, code ErrorCode, text string) {
+	h.send(client, AnswerType{Type: MessageTypeError, RequestID: id, Code: code, Error: text})
+}
+
+// sendErrorTo отправляет ошибку подключению на этом или другом узле
+func (h *Hub) sendErrorTo(connID, id string, code ErrorCode, text string) {
+	h.sendTo(connID, AnswerType{Type: MessageTypeError, RequestID: id, Code: code, Error: text})
+}
+
+// notifyModerators рассылает события лобби участникам, которые могут впускать
+func (h *Hub) notifyModerators(message interface{}) {
+	for _, client := range h.clients {
+		if !client.moderator {
+			continue
+		}
+		h.deliver(client, message)
+	}
+}
+
+// releaseIfEmpty убирает пустой хаб из менеджера; true — хаб должен завершиться
+func (h *Hub) releaseIfEmpty() bool {
+	if len(h.clients) > 0 || len(h.pending) > 0 || len(h.held) > 0 || h.manager == nil {
+		return false
+	}
+	h.manager.release(h)
+	return true
+}
+
+// deliver отправляет сообщение подключению этого узла. Переполненный буфер
+// значит, что клиент не успевает читать: его отключаем так же, как при обрыве
+func (h *Hub) deliver(client *Client, message interface{}) {
+	select {
+	case client.send <- message:
+	default:
+		log.Printf("Client %s is too slow, dropping connection %s", client.id, client.connID)
+		h.drop(client, false)
+	}
+}
+
+// drop убирает подключение из комнаты: удерживает его место для
+// возобновления или, если клиент ушел сам (leaving) или место не
+// удерживается, сообщает остальным об уходе.
+// readPump потом пришлет unregister, но подключения в h.clients уже не будет.
+func (h *Hub) drop(client *Client, leaving bool) {
+	if h.clients[client.connID] != client {
+		return
+	}
+	delete(h.clients, client.connID)
+	close(client.send)
+
+	if leaving || !h.hold(client) {
+		h.leave(client)
+	}
+}
+
+func (h *Hub) broadcast(message interface{}) {
+	for _, client := range h.clients {
+		h.deliver(client, message)
 	}
 }
 
+// getActiveClients — участники комнаты на всех узлах, в том числе переподключающиеся
 func (h *Hub) getActiveClients() map[string]bool {
 	result := make(map[string]bool)
-	for id := range h.clients {
-		result[id] = true
+	for _, userID := range h.connections("") {
+		result[userID] = true
 	}
 	return result
 }
@@ -0,0 +1,75 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+
+	"server/internal/access"
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/store/memory"
+)
+
+// testHub — хаб без Run: тест вызывает его методы сам, как это делал бы цикл хаба
+type testHub struct {
+	*Hub
+	t *testing.T
+}
+
+func newTestHub(t *testing.T, grace time.Duration) *testHub {
+	t.Helper()
+	st := memory.New()
+	bus := backplane.NewMemoryBus().Node("test")
+	cfg := &config.Config{WebSocket: config.WebSocketConfig{ResumeGrace: grace}}
+	h := NewHub(st.Rooms, access.NewChecker(st.Members, st.Sanctions), cfg, bus, nil, nil, nil)
+	h.roomID = "room"
+	t.Cleanup(func() {
+		for id := range h.held {
+			h.unhold(id)
+		}
+		close(h.done)
+		bus.Close()
+	})
+	return &testHub{Hub: h, t: t}
+}
+
+// addClient добавляет в комнату подключение с буфером на 16 сообщений
+func (h *testHub) addClient(userID, connID string) *Client {
+	return h.addClientBuffered(userID, connID, 16)
+}
+
+func (h *testHub) addClientBuffered(userID, connID string, size int) *Client {
+	c := &Client{hub: h.Hub, id: userID, connID: connID, roomID: h.roomID, send: make(chan interface{}, size)}
+	h.clients[connID] = c
+	return c
+}
+
+// received вынимает все сообщения из буфера клиента
+func received(c *Client) []interface{} {
+	var result []interface{}
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				return result
+			}
+			result = append(result, msg)
+		default:
+			return result
+		}
+	}
+}
+
+// types — типы сообщений, отправленных клиенту
+func types(msgs []interface{}) []MessageType {
+	var result []MessageType
+	for _, msg := range msgs {
+		switch m := msg.(type) {
+		case AnswerType:
+			result = append(result, m.Type)
+		case AnswerVideoChatType:
+			result = append(result, m.Type)
+		}
+	}
+	return result
+}
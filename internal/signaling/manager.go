@@ -1,8 +1,18 @@
 package signaling
 
 import (
-	"database/sql"
+	"context"
+	"fmt"
 	"log"
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/ice"
+	"server/internal/presence"
+	"server/internal/recording"
+	"server/internal/sfu"
+	"server/internal/store"
 	"sync"
 )
 
@@ -10,44 +20,198 @@ import (
 type RoomManager struct {
 	rooms map[string]*Hub // roomID -> Hub
 	mutex sync.RWMutex
+	// generation растет при каждом закрытии комнаты; по нему GetOrCreateRoom
+	// узнает, что проверка доступа могла устареть
+	generation uint64
+
+	cfg     *config.Config
+	store   store.RoomStore
+	members store.MemberStore
+	ws      *auth.WSAuthenticator
+	access  *access.Checker
+	bus     backplane.Backplane
+	// sfu — nil, если серверная пересылка медиа выключена
+	sfu *sfu.SFU
+	ice *ice.Provider
+	// recorder — nil, если запись выключена
+	recorder *recording.Manager
+	presence *presence.Service
 }
 
-func NewRoomManager() *RoomManager {
+func NewRoomManager(cfg *config.Config, rooms store.RoomStore, members store.MemberStore,
+	ws *auth.WSAuthenticator, checker *access.Checker, bus backplane.Backplane, media *sfu.SFU,
+	iceServers *ice.Provider, recorder *recording.Manager, presenceService *presence.Service) *RoomManager {
 	return &RoomManager{
-		rooms: make(map[string]*Hub),
+		rooms:    make(map[string]*Hub),
+		cfg:      cfg,
+		store:    rooms,
+		members:  members,
+		ws:       ws,
+		access:   checker,
+		bus:      bus,
+		sfu:      media,
+		ice:      iceServers,
+		recorder: recorder,
+		presence: presenceService,
 	}
 }
 
-// Получить или создать комнату
-func (rm *RoomManager) GetOrCreateRoom(roomID string, db *sql.DB) *Hub {
-	rm.mutex.Lock()
-	defer rm.mutex.Unlock()
+// Admit проверяет, что пользователь может войти в комнату. В публичную комнату
+// пользователь вступает автоматически; в остальные — только участником
+// (по приглашению или добавлением). Для несуществующей комнаты — store.ErrNotFound.
+func (rm *RoomManager) Admit(ctx context.Context, roomID, userID string) (access.Access, error) {
+	join, err := rm.admission(ctx, roomID, userID)
+	if err != nil {
+		return access.Access{}, err
+	}
+	if join {
+		if err := rm.members.Add(ctx, store.ScopeRoom, roomID, userID, store.RoleMember); err != nil {
+			return access.Access{}, err
+		}
+	}
+
+	return rm.access.Require(ctx, store.ScopeRoom, roomID, userID, access.PermJoin)
+}
+
+// CheckAdmission — проверка Admit без побочных эффектов: в участники публичной
+// комнаты пользователь не записывается. Вызывается до апгрейда сокета, когда
+// подключение еще может не состояться.
+func (rm *RoomManager) CheckAdmission(ctx context.Context, roomID, userID string) error {
+	_, err := rm.admission(ctx, roomID, userID)
+	return err
+}
 
-	// Если комната уже существует, возвращаем её
-	if hub, exists := rm.rooms[roomID]; exists {
-		return hub
+// admission решает, пускать ли пользователя; join — он еще не участник
+// публичной комнаты и вступит в нее при входе
+func (rm *RoomManager) admission(ctx context.Context, roomID, userID string) (join bool, err error) {
+	room, err := rm.store.Get(ctx, roomID)
+	if err != nil {
+		return false, err
 	}
 
-	// Создаем новую комнату
-	log.Printf("Creating new room: %s", roomID)
-	hub := NewHub(db)
-	rm.rooms[roomID] = hub
+	if room.ArchivedAt != nil {
+		return false, fmt.Errorf("%w: room is archived", auth.ErrForbidden)
+	}
+
+	a, err := rm.access.Resolve(ctx, store.ScopeRoom, roomID, userID)
+	if err != nil {
+		return false, err
+	}
+	if !a.Member && !a.Banned && room.Visibility == store.RoomPublic {
+		return true, nil
+	}
+
+	_, err = rm.access.Require(ctx, store.ScopeRoom, roomID, userID, access.PermJoin)
+	return false, err
+}
+
+// GetOrCreateRoom пускает пользователя в комнату и возвращает ее хаб.
+// Хаб создается только после успешной проверки членства. Если комнату
+// закрыли, пока шла проверка, она повторяется, чтобы не поднять хаб
+// для удаленной или архивной комнаты. Вызывается после апгрейда сокета:
+// неудачный апгрейд не должен оставлять пустой хаб.
+func (rm *RoomManager) GetOrCreateRoom(ctx context.Context, roomID, userID string) (*Hub, error) {
+	for {
+		rm.mutex.RLock()
+		generation := rm.generation
+		rm.mutex.RUnlock()
+
+		if _, err := rm.Admit(ctx, roomID, userID); err != nil {
+			return nil, err
+		}
+
+		rm.mutex.Lock()
+		if rm.generation != generation {
+			rm.mutex.Unlock()
+			continue
+		}
+
+		// Если комната уже существует, возвращаем её
+		hub, exists := rm.rooms[roomID]
+		if !exists {
+			// Создаем новую комнату
+			log.Printf("Creating new room: %s", roomID)
+			hub = NewHub(rm.store, rm.access, rm.cfg, rm.bus, rm.sfu, rm.ice, rm.recorder)
+			hub.roomID = roomID
+			hub.manager = rm
+			rm.rooms[roomID] = hub
 
-	// Запускаем Hub для этой комнаты
-	go hub.Run()
+			// Без шины комната продолжает работать в пределах узла
+			if err := hub.subscribe(); err != nil {
+				log.Printf("Room %s is not shared with other nodes: %v", roomID, err)
+			}
 
-	return hub
+			// Запускаем Hub для этой комнаты
+			go hub.Run()
+		}
+		rm.mutex.Unlock()
+
+		return hub, nil
+	}
 }
 
-// Удалить пустую комнату
-func (rm *RoomManager) RemoveRoomIfEmpty(roomID string) {
+// release убирает опустевший хаб из менеджера, если его еще не заменили или не закрыли
+func (rm *RoomManager) release(hub *Hub) {
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
 
-	if hub, exists := rm.rooms[roomID]; exists {
-		if len(hub.clients) == 0 {
-			log.Printf("Removing empty room: %s", roomID)
-			delete(rm.rooms, roomID)
+	if rm.rooms[hub.roomID] == hub {
+		log.Printf("Removing empty room: %s", hub.roomID)
+		delete(rm.rooms, hub.roomID)
+	}
+}
+
+// CloseRoom применяет изменение комнаты в базе (удаление, архивация) и отключает
+// всех участников активной сессии с кодом CloseRoomClosed и причиной,
+// в том числе на других узлах.
+// Изменение выполняется под блокировкой менеджера, поэтому новый хаб для
+// комнаты не может появиться между изменением базы и закрытием хаба.
+// CloseRoom возвращается, когда хаб остановился и завершил запись: после
+// этого файлы записей комнаты больше не пишутся и их можно удалять.
+func (rm *RoomManager) CloseRoom(roomID, reason string, apply func() error) error {
+	rm.mutex.Lock()
+	if err := apply(); err != nil {
+		rm.mutex.Unlock()
+		return err
+	}
+	hub, exists := rm.rooms[roomID]
+	delete(rm.rooms, roomID)
+	rm.generation++
+	rm.mutex.Unlock()
+
+	if exists {
+		log.Printf("Closing room %s: %s", roomID, reason)
+		hub.shutdown(CloseRoomClosed, reason)
+		<-hub.stopped
+	}
+	publishEvent(rm.bus, roomID, event{Kind: eventClose, Reason: reason})
+	return nil
+}
+
+// closeHub закрывает хаб по событию close с другого узла
+func (rm *RoomManager) closeHub(hub *Hub, reason string) {
+	rm.mutex.Lock()
+	if rm.rooms[hub.roomID] == hub {
+		delete(rm.rooms, hub.roomID)
+	}
+	rm.generation++
+	rm.mutex.Unlock()
+
+	log.Printf("Closing room %s: %s", hub.roomID, reason)
+	hub.shutdown(CloseRoomClosed, reason)
+}
+
+// Disconnect отключает пользователя от активной комнаты на всех узлах с кодом 4403 и причиной
+func (rm *RoomManager) Disconnect(roomID, userID, reason string) {
+	rm.mutex.RLock()
+	hub, exists := rm.rooms[roomID]
+	rm.mutex.RUnlock()
+
+	if exists {
+		select {
+		case hub.kick <- kickRequest{userID: userID, reason: reason}:
+		case <-hub.done:
 		}
 	}
+	publishEvent(rm.bus, roomID, event{Kind: eventKick, UserID: userID, Reason: reason})
 }
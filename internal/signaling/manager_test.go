@@ -0,0 +1,145 @@
+package signaling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/backplane"
+	"server/internal/config"
+	"server/internal/presence"
+	"server/internal/store"
+	"server/internal/store/memory"
+)
+
+// newTestManager поднимает ServerWs поверх хранилища в памяти
+func newTestManager(t *testing.T) (*RoomManager, *store.Store, *auth.Manager, string) {
+	t.Helper()
+	st := memory.New()
+	cfg := config.Default()
+	cfg.JWT.Secret = "test-secret-test-secret-test-secret-1234"
+	bus := backplane.NewMemoryBus().Node("test")
+	t.Cleanup(func() { bus.Close() })
+
+	manager := auth.NewManager(cfg.JWT, st.Tokens, st.Users)
+	ws := auth.NewWSAuthenticator(manager, cfg.WebSocket, nil)
+	checker := access.NewChecker(st.Members, st.Sanctions)
+	rm := NewRoomManager(cfg, st.Rooms, st.Members, ws, checker, bus, nil, nil, nil,
+		presence.NewService(st.Users, st.Friendships, bus))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ws/{roomId}", func(w http.ResponseWriter, r *http.Request) {
+		ServerWs(rm, w, r)
+	})
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return rm, st, manager, srv.URL
+}
+
+func (rm *RoomManager) roomCount() int {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return len(rm.rooms)
+}
+
+func TestServerWsCreatesHubAfterUpgrade(t *testing.T) {
+	rm, st, manager, url := newTestManager(t)
+	ctx := context.Background()
+	owner, err := st.Users.Create(ctx, "owner", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest, err := st.Users.Create(ctx, "guest", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := st.Rooms.Create(ctx, store.Room{Name: "public", CreatedBy: owner.ID, Visibility: store.RoomPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := st.Rooms.Create(ctx, store.Room{Name: "private", CreatedBy: owner.ID, Visibility: store.RoomPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := manager.Issue(ctx, guest.ID, guest.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Обычный GET с валидным токеном: доступ есть, но апгрейд не удается
+	resp, err := http.Get(url + "/ws/" + public.ID + "?token=" + tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET: status %d, want 400", resp.StatusCode)
+	}
+
+	// Нет доступа: отказ до апгрейда
+	wsURL := "ws" + strings.TrimPrefix(url, "http")
+	_, resp, err = websocket.DefaultDialer.Dial(wsURL+"/ws/"+private.ID+"?token="+tokens.AccessToken, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("private room: %v, response %v; want status 403", err, resp)
+	}
+
+	if n := rm.roomCount(); n != 0 {
+		t.Fatalf("%d hubs left after failed upgrades, want 0", n)
+	}
+	if _, err := st.Members.Get(ctx, store.ScopeRoom, public.ID, guest.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("guest joined the public room without a socket: err = %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"/ws/"+public.ID+"?token="+tokens.AccessToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("read register: %v", err)
+	}
+	if n := rm.roomCount(); n != 1 {
+		t.Errorf("%d hubs after a successful upgrade, want 1", n)
+	}
+	if m, err := st.Members.Get(ctx, store.ScopeRoom, public.ID, guest.ID); err != nil || m.Role != store.RoleMember {
+		t.Errorf("guest membership = %+v, %v; want member", m, err)
+	}
+}
+
+func TestCloseRoomWaitsForHub(t *testing.T) {
+	rm, st, _, _ := newTestManager(t)
+	ctx := context.Background()
+	owner, err := st.Users.Create(ctx, "owner", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, err := st.Rooms.Create(ctx, store.Room{Name: "room", CreatedBy: owner.ID, Visibility: store.RoomPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := rm.GetOrCreateRoom(ctx, room.ID, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rm.CloseRoom(room.ID, "room deleted", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	// Записи удаляются сразу после CloseRoom: хаб к этому времени остановлен
+	select {
+	case <-hub.stopped:
+	default:
+		t.Fatal("CloseRoom returned before the hub stopped")
+	}
+	if n := rm.roomCount(); n != 0 {
+		t.Errorf("%d hubs after CloseRoom, want 0", n)
+	}
+}
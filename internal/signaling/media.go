@@ -0,0 +1,185 @@
+package signaling
+
+import (
+	"errors"
+	"log"
+	"server/internal/sfu"
+	"server/internal/store"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// SFUPeerID — адресат и отправитель videochat-сообщений сервера в режиме sfu.
+// У SFU каждое подключение клиента — отдельный участник со своим ID.
+// Клиент отвечает на offer с from = "sfu" и шлет свои ICE-кандидаты на to = "sfu".
+const SFUPeerID = "sfu"
+
+// startMedia выбирает режим медиа для сессии комнаты. Режим читается при
+// первом входе и не меняется, пока хаб жив: изменение настройки комнаты
+// действует со следующей сессии. SFU работает в пределах одного узла:
+// участники, подключенные к другим репликам, получают медиа своего узла.
+func (h *Hub) startMedia(room store.Room) {
+	if h.media != "" {
+		return
+	}
+	h.media = store.MediaMesh
+	if room.MediaMode != store.MediaSFU {
+		return
+	}
+	if h.sfu == nil {
+		log.Printf("Room %s requests SFU, but it is disabled; using mesh", h.roomID)
+		return
+	}
+
+	h.media = store.MediaSFU
+	h.session = h.newSession(true)
+}
+
+// sessionSignal — сигнал SFU вместе с сессией, которая его создала
+type sessionSignal struct {
+	session *sfu.Session
+	sfu.Signal
+}
+
+// newSession создает сессию SFU, сигналы которой приходят в Run.
+// Сигналы уже закрытой сессии отбрасываются в deliverSFUSignal.
+func (h *Hub) newSession(relay bool) *sfu.Session {
+	var session *sfu.Session
+	session = h.sfu.NewSession(func(sig sfu.Signal) {
+		select {
+		case h.sfuSignals <- sessionSignal{session: session, Signal: sig}:
+		case <-h.done:
+		}
+	}, relay)
+	return session
+}
+
+// joinMedia подключает вошедшее подключение к SFU; сервер пришлет ему offer
+func (h *Hub) joinMedia(client *Client) {
+	if h.session == nil {
+		return
+	}
+	if err := h.session.Join(client.connID); err != nil {
+		log.Printf("Error joining %s to SFU of room %s: %v", client.id, h.roomID, err)
+		h.sendError(client, "", ErrorUnavailable, "media server is not available")
+	}
+}
+
+// leaveMedia отключает подключение от SFU, если оно вышло и его место не удерживается
+func (h *Hub) leaveMedia(connID string) {
+	if h.session == nil {
+		return
+	}
+	if _, ok := h.held[connID]; ok {
+		return
+	}
+	if _, ok := h.clients[connID]; !ok {
+		h.session.Leave(connID)
+	}
+}
+
+// handleSFUMessage передает SFU сигнальное сообщение клиента. Сервер в паре
+// невежливый: встречный offer клиента отклоняется, клиент откатывает его и
+// отвечает на offer сервера.
+func (h *Hub) handleSFUMessage(in videoInput) {
+	msg := in.msg
+	if h.session == nil {
+		h.sendError(in.client, in.id, ErrorConflict, "room does not use the media server")
+		return
+	}
+
+	var err error
+	switch msg.kind() {
+	case MessageTypeOffer:
+		err = h.session.Offer(msg.FromConnection, webrtc.SessionDescription{
+			Type: webrtc.SDPTypeOffer,
+			SDP:  msg.Offer.SDP,
+		})
+	case MessageTypeAnswer:
+		err = h.session.Answer(msg.FromConnection, webrtc.SessionDescription{
+			Type: webrtc.SDPTypeAnswer,
+			SDP:  msg.Answer.SDP,
+		})
+	case MessageTypeIceCandidate:
+		err = h.session.AddCandidate(msg.FromConnection, iceCandidateInit(msg.IceCandidate))
+	case MessageTypeEndOfCandidates:
+		err = h.session.AddCandidate(msg.FromConnection, webrtc.ICECandidateInit{})
+	case MessageTypeIceRestart:
+		err = h.session.RestartICE(msg.FromConnection)
+	}
+	if errors.Is(err, sfu.ErrGlare) {
+		h.sendError(in.client, in.id, ErrorConflict, "offer collides with a pending server offer")
+		return
+	}
+	if err != nil {
+		log.Printf("SFU message from %s in room %s: %v", msg.FromConnection, h.roomID, err)
+		h.sendError(in.client, in.id, ErrorBadRequest, "invalid media message")
+		return
+	}
+	h.ack(in.client, in.id)
+}
+
+// deliverSFUSignal передает подключению offer или ICE-кандидата сервера; для
+// переподключающегося клиента сигнал копится до возобновления
+func (h *Hub) deliverSFUSignal(sig sessionSignal) {
+	if sig.session != h.session {
+		return
+	}
+	client, ok := h.clients[sig.UserID]
+	if _, held := h.held[sig.UserID]; !ok && !held {
+		return
+	}
+
+	polite := Polite(sig.UserID, SFUPeerID)
+	msg := VideoChatMessage{From: SFUPeerID, To: sig.UserID, Polite: &polite}
+	switch {
+	case sig.Offer != nil:
+		msg.Type = string(MessageTypeOffer)
+		msg.Offer = &RTCSessionDescription{Type: sig.Offer.Type.String(), SDP: sig.Offer.SDP}
+	case sig.Answer != nil:
+		msg.Type = string(MessageTypeAnswer)
+		msg.Answer = &RTCSessionDescription{Type: sig.Answer.Type.String(), SDP: sig.Answer.SDP}
+	case sig.Candidate != nil:
+		msg.Type = string(MessageTypeIceCandidate)
+		msg.IceCandidate = rtcIceCandidate(*sig.Candidate)
+	case sig.EndOfCandidates:
+		msg.Type = string(MessageTypeEndOfCandidates)
+	default:
+		return
+	}
+
+	answer := AnswerVideoChatType{Type: MessageTypeVideoChat, Data: msg}
+	if !ok {
+		h.queue(sig.UserID, answer)
+		return
+	}
+	h.deliver(client, answer)
+}
+
+func iceCandidateInit(c *RTCIceCandidate) webrtc.ICECandidateInit {
+	init := webrtc.ICECandidateInit{
+		Candidate:     c.Candidate,
+		SDPMLineIndex: c.SdpMLineIndex,
+	}
+	if c.SdpMid != "" {
+		init.SDPMid = &c.SdpMid
+	}
+	if c.UsernameFragment != "" {
+		init.UsernameFragment = &c.UsernameFragment
+	}
+	return init
+}
+
+func rtcIceCandidate(c webrtc.ICECandidateInit) *RTCIceCandidate {
+	candidate := &RTCIceCandidate{
+		Candidate:     c.Candidate,
+		SdpMLineIndex: c.SDPMLineIndex,
+	}
+	if c.SDPMid != nil {
+		candidate.SdpMid = *c.SDPMid
+	}
+	if c.UsernameFragment != nil {
+		candidate.UsernameFragment = *c.UsernameFragment
+	}
+	return candidate
+}
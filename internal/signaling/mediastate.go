@@ -0,0 +1,163 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"server/internal/access"
+	"server/internal/auth"
+	"server/internal/store"
+)
+
+// MediaState — состояние медиа участника, которое видят остальные
+type MediaState struct {
+	AudioMuted  bool `json:"audioMuted"`
+	VideoOff    bool `json:"videoOff"`
+	ScreenShare bool `json:"screenShare"`
+	HandRaised  bool `json:"handRaised"`
+	Speaking    bool `json:"speaking"`
+}
+
+// mediaInput — изменение состояния медиа от клиента; для force-mute userID —
+// кого заглушить
+type mediaInput struct {
+	client *Client
+	id     string
+	typ    MessageType
+	userID string
+}
+
+// apply меняет состояние по сообщению клиента; false — состояние не изменилось
+func (s *MediaState) apply(typ MessageType) bool {
+	before := *s
+	switch typ {
+	case MessageTypeMute:
+		s.AudioMuted = true
+		s.Speaking = false
+	case MessageTypeUnmute:
+		s.AudioMuted = false
+	case MessageTypeVideoOff:
+		s.VideoOff = true
+	case MessageTypeVideoOn:
+		s.VideoOff = false
+	case MessageTypeScreenShareStart:
+		s.ScreenShare = true
+	case MessageTypeScreenShareStop:
+		s.ScreenShare = false
+	case MessageTypeHandRaise:
+		s.HandRaised = true
+	case MessageTypeHandLower:
+		s.HandRaised = false
+	case MessageTypeSpeakingStart:
+		// Заглушенный участник не может говорить
+		s.Speaking = !s.AudioMuted
+	case MessageTypeSpeakingStop:
+		s.Speaking = false
+	}
+	return *s != before
+}
+
+// handleMedia применяет изменение состояния медиа участника
+func (h *Hub) handleMedia(in mediaInput) {
+	if !h.member(in.client, in.id) {
+		return
+	}
+	if in.typ == MessageTypeForceMute {
+		h.forceMute(in)
+		return
+	}
+
+	if in.client.media.apply(in.typ) {
+		h.announceMedia(in.client)
+	}
+	h.ack(in.client, in.id)
+}
+
+// forceMute заглушает участника по запросу модератора. Сервер не может
+// выключить микрофон сам: клиент получает force-mute и выключает его, а
+// остальные видят, что участник заглушен.
+func (h *Hub) forceMute(in mediaInput) {
+	_, err := h.access.Require(context.Background(), store.ScopeRoom, h.roomID, in.client.id, access.PermModerate)
+	if err != nil {
+		if !errors.Is(err, auth.ErrForbidden) {
+			log.Printf("Error checking mute permission: %v", err)
+			h.sendError(in.client, in.id, ErrorInternal, "internal error")
+			return
+		}
+		h.sendError(in.client, in.id, ErrorForbidden, err.Error())
+		return
+	}
+
+	muted := h.muteLocal(in.userID, in.client.id)
+	if h.connectedElsewhere(in.userID) {
+		// Остальные подключения участника заглушат узлы, к которым они подключены
+		h.publish(event{Kind: eventForceMute, UserID: in.userID, By: in.client.id})
+		muted = true
+	}
+	if !muted {
+		h.sendError(in.client, in.id, ErrorNotFound, "user is not in the room")
+		return
+	}
+	h.ack(in.client, in.id)
+}
+
+// muteLocal заглушает подключения участника к этому узлу; false — их нет.
+// Переподключающийся получит force-mute после возобновления.
+func (h *Hub) muteLocal(userID, by string) bool {
+	muted := false
+	for connID, client := range h.localClients() {
+		if client.id != userID {
+			continue
+		}
+		muted = true
+		log.Printf("Client %s force-muted in room %s by %s", userID, h.roomID, by)
+		answer := AnswerType{Type: MessageTypeForceMute, By: by}
+		if !h.queue(connID, answer) {
+			h.send(client, answer)
+		}
+		if client.media.apply(MessageTypeMute) {
+			h.announceMedia(client)
+		}
+	}
+	return muted
+}
+
+// announceMedia рассылает состояние медиа подключения на всех узлах
+func (h *Hub) announceMedia(client *Client) {
+	state := client.media
+	h.broadcast(AnswerType{Type: MessageTypeMediaState, UserID: client.id, ConnectionID: client.connID, MediaState: &state})
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Error encoding media state: %v", err)
+		return
+	}
+	h.publish(event{Kind: eventMedia, UserID: client.id, Conn: client.connID, Payload: payload})
+}
+
+// mediaStates — снимок состояния медиа всех подключений для ответа register
+func (h *Hub) mediaStates(except string) map[string]MediaState {
+	states := make(map[string]MediaState)
+	for connID := range h.peers {
+		states[connID] = h.peerMedia[connID]
+	}
+	for connID, client := range h.localClients() {
+		states[connID] = client.media
+	}
+	delete(states, except)
+	return states
+}
+
+// decodeMediaState разбирает состояние участника другого узла из события
+func decodeMediaState(payload json.RawMessage) (MediaState, bool) {
+	var state MediaState
+	if len(payload) == 0 {
+		return state, false
+	}
+	if err := json.Unmarshal(payload, &state); err != nil {
+		log.Printf("Invalid media state: %v", err)
+		return state, false
+	}
+	return state, true
+}
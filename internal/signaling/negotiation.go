@@ -0,0 +1,101 @@
+package signaling
+
+import (
+	"errors"
+
+	"github.com/pion/sdp/v3"
+)
+
+// maxSDPSize — предельный размер SDP в offer и answer. Браузерное описание
+// с десятком дорожек занимает несколько килобайт.
+const maxSDPSize = 32 << 10
+
+// Polite — роль подключения connID в паре с peerID при perfect negotiation.
+// При встречных offer вежливый участник откатывает свой и отвечает на чужой,
+// невежливый чужой offer игнорирует. Роль зависит только от пары ID, поэтому
+// участники на разных узлах получают согласованные роли. С сервером SFU
+// клиент всегда вежлив.
+func Polite(connID, peerID string) bool {
+	if peerID == SFUPeerID {
+		return true
+	}
+	return connID < peerID
+}
+
+// kind — вид сигнального сообщения. В версии 2 он задан полем type; в
+// версии 1 type совпадает с типом кадра ("videochat"), и вид определяется по
+// заполненному полю.
+func (m *VideoChatMessage) kind() MessageType {
+	switch t := MessageType(m.Type); t {
+	case MessageTypeOffer, MessageTypeAnswer, MessageTypeIceCandidate,
+		MessageTypeEndOfCandidates, MessageTypeIceRestart:
+		return t
+	}
+	switch {
+	case m.Offer != nil:
+		return MessageTypeOffer
+	case m.Answer != nil:
+		return MessageTypeAnswer
+	case m.IceCandidate != nil:
+		return MessageTypeIceCandidate
+	}
+	return ""
+}
+
+// validate проверяет сигнальное сообщение клиента и приводит type к его виду
+func (m *VideoChatMessage) validate() error {
+	if m.To == "" {
+		return errors.New("to is required")
+	}
+
+	kind := m.kind()
+	switch kind {
+	case MessageTypeOffer:
+		if err := validateDescription(m.Offer, "offer"); err != nil {
+			return err
+		}
+	case MessageTypeAnswer:
+		if err := validateDescription(m.Answer, "answer", "pranswer"); err != nil {
+			return err
+		}
+	case MessageTypeIceCandidate:
+		if m.IceCandidate == nil {
+			return errors.New("iceCandidate is required")
+		}
+	case MessageTypeEndOfCandidates, MessageTypeIceRestart:
+	default:
+		return errors.New("unknown videochat message type")
+	}
+	m.Type = string(kind)
+	return nil
+}
+
+// validateDescription проверяет тип, размер и разбор SDP
+func validateDescription(d *RTCSessionDescription, types ...string) error {
+	if d == nil {
+		return errors.New(types[0] + " is required")
+	}
+
+	valid := false
+	for _, t := range types {
+		if d.Type == t {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return errors.New("invalid session description type " + d.Type)
+	}
+
+	if len(d.SDP) > maxSDPSize {
+		return errors.New("sdp is too large")
+	}
+	var parsed sdp.SessionDescription
+	if err := parsed.UnmarshalString(d.SDP); err != nil {
+		return errors.New("malformed sdp: " + err.Error())
+	}
+	if len(parsed.MediaDescriptions) == 0 {
+		return errors.New("malformed sdp: no media sections")
+	}
+	return nil
+}
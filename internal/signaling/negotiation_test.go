@@ -0,0 +1,115 @@
+package signaling
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSDP = "v=0\r\n" +
+	"o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n"
+
+func TestVideoChatMessageValidate(t *testing.T) {
+	noMedia := "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n"
+
+	tests := []struct {
+		name     string
+		msg      VideoChatMessage
+		wantType MessageType
+		wantErr  string
+	}{
+		{"offer", VideoChatMessage{Type: "offer", To: "b", Offer: &RTCSessionDescription{Type: "offer", SDP: testSDP}}, MessageTypeOffer, ""},
+		{"v1 offer by field", VideoChatMessage{Type: "videochat", To: "b", Offer: &RTCSessionDescription{Type: "offer", SDP: testSDP}}, MessageTypeOffer, ""},
+		{"answer", VideoChatMessage{To: "b", Answer: &RTCSessionDescription{Type: "answer", SDP: testSDP}}, MessageTypeAnswer, ""},
+		{"pranswer", VideoChatMessage{To: "b", Answer: &RTCSessionDescription{Type: "pranswer", SDP: testSDP}}, MessageTypeAnswer, ""},
+		{"candidate", VideoChatMessage{To: "b", IceCandidate: &RTCIceCandidate{}}, MessageTypeIceCandidate, ""},
+		{"end of candidates", VideoChatMessage{Type: "end-of-candidates", To: "b"}, MessageTypeEndOfCandidates, ""},
+		{"no recipient", VideoChatMessage{Offer: &RTCSessionDescription{Type: "offer", SDP: testSDP}}, "", "to is required"},
+		{"unknown", VideoChatMessage{Type: "videochat", To: "b"}, "", "unknown videochat message type"},
+		{"offer without description", VideoChatMessage{Type: "offer", To: "b"}, "", "offer is required"},
+		{"answer typed as offer", VideoChatMessage{To: "b", Answer: &RTCSessionDescription{Type: "offer", SDP: testSDP}}, "", "invalid session description type"},
+		{"candidate without body", VideoChatMessage{Type: string(MessageTypeIceCandidate), To: "b"}, "", "iceCandidate is required"},
+		{"malformed sdp", VideoChatMessage{To: "b", Offer: &RTCSessionDescription{Type: "offer", SDP: "hello"}}, "", "malformed sdp"},
+		{"no media sections", VideoChatMessage{To: "b", Offer: &RTCSessionDescription{Type: "offer", SDP: noMedia}}, "", "no media sections"},
+		{"too large", VideoChatMessage{To: "b", Offer: &RTCSessionDescription{Type: "offer", SDP: testSDP + strings.Repeat("a=x\r\n", maxSDPSize/5)}}, "", "sdp is too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			err := msg.validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if MessageType(msg.Type) != tt.wantType {
+				t.Errorf("type = %q, want %q", msg.Type, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestPolite(t *testing.T) {
+	tests := []struct {
+		connID, peerID string
+		want           bool
+	}{
+		{"a", "b", true},
+		{"b", "a", false},
+		{"conn-10", "conn-9", true},
+		{"a", SFUPeerID, true},
+		{"z", SFUPeerID, true},
+	}
+	for _, tt := range tests {
+		if got := Polite(tt.connID, tt.peerID); got != tt.want {
+			t.Errorf("Polite(%q, %q) = %v, want %v", tt.connID, tt.peerID, got, tt.want)
+		}
+	}
+
+	// В каждой паре участников вежлив ровно один
+	ids := []string{"a", "b", "conn-1", "conn-10", "conn-9"}
+	for _, a := range ids {
+		for _, b := range ids {
+			if a != b && Polite(a, b) == Polite(b, a) {
+				t.Errorf("Polite(%q, %q) and Polite(%q, %q) are both %v", a, b, b, a, Polite(a, b))
+			}
+		}
+	}
+}
+
+func TestRelaySetsPoliteRole(t *testing.T) {
+	h := newTestHub(t, 0)
+	alice := h.addClient("alice", "a1")
+	bob := h.addClient("bob", "b1")
+	laptop := h.addClient("bob", "b0")
+
+	// Сообщение пользователю получают все его подключения, кроме отправителя
+	if !h.relay(&VideoChatMessage{To: "bob", FromConnection: "a1"}) {
+		t.Fatal("relay to bob returned false")
+	}
+	for _, c := range []*Client{bob, laptop} {
+		msgs := received(c)
+		if len(msgs) != 1 {
+			t.Fatalf("%s received %d messages, want 1", c.connID, len(msgs))
+		}
+		data := msgs[0].(AnswerVideoChatType).Data
+		if data.Polite == nil || *data.Polite != Polite(c.connID, "a1") {
+			t.Errorf("%s got polite %v, want %v", c.connID, data.Polite, Polite(c.connID, "a1"))
+		}
+	}
+	if msgs := received(alice); len(msgs) != 0 {
+		t.Errorf("sender received its own message: %v", msgs)
+	}
+
+	if h.relay(&VideoChatMessage{To: "carol", FromConnection: "a1"}) {
+		t.Error("relay to a user outside the room returned true")
+	}
+}
@@ -0,0 +1,203 @@
+package signaling
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"server/internal/auth"
+	"server/internal/recording"
+	"server/internal/store"
+	"strconv"
+)
+
+// Версии протокола сигнализации. Версия выбирается при подключении
+// параметром запроса v (/auth/ws/{roomId}?v=2); без него соединение
+// работает по версии 1, чтобы старые клиенты не сломались.
+const (
+	// ProtocolV1 — плоские сообщения {"type": ...}; ack не приходят,
+	// некорректные и неизвестные сообщения отбрасываются молча
+	ProtocolV1 = 1
+	// ProtocolV2 — все кадры в конверте Envelope. Сервер первым присылает
+	// hello с выбранной версией, а на каждый запрос с id отвечает ack или error.
+	ProtocolV2 = 2
+
+	ProtocolLatest = ProtocolV2
+)
+
+// ProtocolVersions — версии, которые поддерживает сервер
+var ProtocolVersions = []int{ProtocolV1, ProtocolV2}
+
+//go:embed schema
+var schemas embed.FS
+
+// Envelope — кадр протокола версии 2. ID задает клиент; ack и error на
+// запрос приходят с тем же ID. В кадрах, которые сервер рассылает сам, ID пуст.
+type Envelope struct {
+	Type    MessageType     `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Version int             `json:"version"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// HelloPayload — первый кадр сервера в версии 2
+type HelloPayload struct {
+	Version  int   `json:"version"`
+	Versions []int `json:"versions"`
+}
+
+// ErrorCode — машиночитаемая причина ошибки в кадре error
+type ErrorCode string
+
+const (
+	ErrorBadRequest         ErrorCode = "bad_request"
+	ErrorUnknownType        ErrorCode = "unknown_type"
+	ErrorUnsupportedVersion ErrorCode = "unsupported_version"
+	ErrorForbidden          ErrorCode = "forbidden"
+	ErrorNotFound           ErrorCode = "not_found"
+	ErrorConflict           ErrorCode = "conflict"
+	ErrorRoomFull           ErrorCode = "room_full"
+	ErrorUnavailable        ErrorCode = "unavailable"
+	ErrorInternal           ErrorCode = "internal"
+)
+
+// ErrorPayload — содержимое кадра error
+type ErrorPayload struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// inbound — входящее сообщение, приведенное к общему виду для обеих версий
+type inbound struct {
+	typ     MessageType
+	id      string
+	version int
+	payload json.RawMessage
+}
+
+// ParseProtocolVersion разбирает параметр v запроса на подключение
+func ParseProtocolVersion(v string) (int, error) {
+	if v == "" {
+		return ProtocolV1, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errors.New("invalid protocol version")
+	}
+	for _, supported := range ProtocolVersions {
+		if version == supported {
+			return version, nil
+		}
+	}
+	return 0, errors.New("unsupported protocol version " + v)
+}
+
+// Schema возвращает JSON Schema сообщений версии 2: "client" — кадры
+// клиента, "server" — кадры сервера
+func Schema(name string) ([]byte, bool) {
+	if name != "client" && name != "server" {
+		return nil, false
+	}
+	data, err := schemas.ReadFile("schema/v2/" + name + ".schema.json")
+	return data, err == nil
+}
+
+// decode разбирает кадр клиента. В версии 1 полезная нагрузка — сам кадр.
+func (c *Client) decode(message []byte) (inbound, error) {
+	if c.version < ProtocolV2 {
+		var head struct {
+			Type MessageType `json:"type"`
+		}
+		if err := json.Unmarshal(message, &head); err != nil {
+			return inbound{}, err
+		}
+		return inbound{typ: head.Type, version: ProtocolV1, payload: message}, nil
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(message, &env); err != nil {
+		return inbound{}, err
+	}
+	return inbound{typ: env.Type, id: env.ID, version: env.Version, payload: env.Payload}, nil
+}
+
+// encode готовит сообщение хаба к отправке клиенту. Хаб работает с
+// сообщениями версии 1 (AnswerType, AnswerVideoChatType или их JSON с других
+// узлов), а версию протокола клиента учитывает только запись в сокет.
+// nil без ошибки — сообщение этому клиенту не отправляется.
+func (c *Client) encode(message interface{}) ([]byte, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	var typ MessageType
+	if err := json.Unmarshal(fields["type"], &typ); err != nil {
+		return nil, err
+	}
+
+	if c.version < ProtocolV2 {
+		// Подтверждения есть только во второй версии
+		if typ == MessageTypeAck {
+			return nil, nil
+		}
+		return data, nil
+	}
+
+	env := Envelope{Type: typ, Version: c.version}
+	if id, ok := fields["requestId"]; ok {
+		if err := json.Unmarshal(id, &env.ID); err != nil {
+			return nil, err
+		}
+	}
+	delete(fields, "type")
+	delete(fields, "requestId")
+
+	switch typ {
+	case MessageTypeVideoChat:
+		env.Payload = fields["data"]
+	case MessageTypeError:
+		payload := ErrorPayload{Code: ErrorInternal}
+		if code, ok := fields["code"]; ok {
+			json.Unmarshal(code, &payload.Code)
+		}
+		json.Unmarshal(fields["error"], &payload.Message)
+		if env.Payload, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	default:
+		if len(fields) > 0 {
+			if env.Payload, err = json.Marshal(fields); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return json.Marshal(env)
+}
+
+// hello — первый кадр соединения версии 2
+func (c *Client) hello() ([]byte, error) {
+	payload, err := json.Marshal(HelloPayload{Version: c.version, Versions: ProtocolVersions})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{Type: MessageTypeHello, Version: c.version, Payload: payload})
+}
+
+// errorCode сопоставляет ошибку хаба коду кадра error
+func errorCode(err error) ErrorCode {
+	switch {
+	case errors.Is(err, auth.ErrForbidden):
+		return ErrorForbidden
+	case errors.Is(err, store.ErrNotFound):
+		return ErrorNotFound
+	case errors.Is(err, recording.ErrActive), errors.Is(err, recording.ErrNotActive):
+		return ErrorConflict
+	case errors.Is(err, ErrRecordingDisabled), errors.Is(err, ErrRoomNotLive):
+		return ErrorUnavailable
+	}
+	return ErrorInternal
+}
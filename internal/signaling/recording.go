@@ -0,0 +1,173 @@
+package signaling
+
+import (
+	"context"
+	"errors"
+	"log"
+	"server/internal/access"
+	"server/internal/recording"
+	"server/internal/store"
+)
+
+var (
+	// ErrRoomNotLive — у комнаты нет живой сессии на этом узле
+	ErrRoomNotLive = errors.New("room has no active session")
+	// ErrRecordingDisabled — запись на сервере выключена
+	ErrRecordingDisabled = errors.New("recording is disabled")
+)
+
+// RecordingState — индикатор записи, который видят все участники
+type RecordingState struct {
+	Active bool   `json:"active"`
+	ID     string `json:"id,omitempty"`
+	By     string `json:"by,omitempty"`
+}
+
+// recordRequest — включить или выключить запись. reply == nil — запрос
+// пришел из сокета от client, и ответ отправляется ему кадром ack или error.
+type recordRequest struct {
+	client *Client
+	id     string
+	userID string
+	start  bool
+	reply  chan recordReply
+}
+
+type recordReply struct {
+	id  string
+	err error
+}
+
+// Record включает или выключает запись живой сессии комнаты на этом узле
+// и возвращает ID записи. Записываются участники, подключенные к этому узлу.
+func (rm *RoomManager) Record(roomID, userID string, start bool) (string, error) {
+	rm.mutex.RLock()
+	hub, exists := rm.rooms[roomID]
+	rm.mutex.RUnlock()
+	if !exists {
+		return "", ErrRoomNotLive
+	}
+
+	reply := make(chan recordReply, 1)
+	select {
+	case hub.record <- recordRequest{userID: userID, start: start, reply: reply}:
+	case <-hub.done:
+		return "", ErrRoomNotLive
+	}
+
+	select {
+	case r := <-reply:
+		return r.id, r.err
+	case <-hub.done:
+		select {
+		case r := <-reply:
+			return r.id, r.err
+		default:
+			return "", ErrRoomNotLive
+		}
+	}
+}
+
+// handleRecord выполняет запрос записи; включать и выключать ее может владелец
+func (h *Hub) handleRecord(req recordRequest) {
+	if req.reply == nil && !h.member(req.client, req.id) {
+		return
+	}
+
+	var id string
+	_, err := h.access.Require(context.Background(), store.ScopeRoom, h.roomID, req.userID, access.PermManage)
+	if err == nil {
+		if req.start {
+			id, err = h.startRecording(req.userID)
+		} else {
+			id, err = h.stopRecording()
+		}
+	}
+
+	if req.reply != nil {
+		req.reply <- recordReply{id: id, err: err}
+		return
+	}
+	if err != nil {
+		log.Printf("Recording request from %s in room %s: %v", req.userID, h.roomID, err)
+		h.sendError(req.client, req.id, errorCode(err), err.Error())
+		return
+	}
+	h.ack(req.client, req.id)
+}
+
+// startRecording начинает запись. В режиме mesh сервер поднимает
+// принимающую сессию SFU только на время записи: участники получают
+// offer от "sfu" и отправляют серверу свои дорожки, не отдавая их остальным.
+func (h *Hub) startRecording(userID string) (string, error) {
+	if h.recorder == nil || h.sfu == nil {
+		return "", ErrRecordingDisabled
+	}
+	if h.recording != nil {
+		return "", recording.ErrActive
+	}
+
+	rec, err := h.recorder.Start(h.roomID, userID)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Recording %s of room %s started by %s", rec.ID(), h.roomID, userID)
+
+	if h.session == nil {
+		h.session = h.newSession(false)
+		h.recordOnly = true
+		for _, client := range h.clients {
+			h.joinMedia(client)
+		}
+	}
+	h.session.Record(rec)
+	h.recording = rec
+	h.recordedBy = userID
+
+	h.announceRecording(RecordingState{Active: true, ID: rec.ID(), By: userID})
+	return rec.ID(), nil
+}
+
+// stopRecording останавливает запись и закрывает временную сессию SFU
+func (h *Hub) stopRecording() (string, error) {
+	if h.recording == nil {
+		return "", recording.ErrNotActive
+	}
+	id := h.recording.ID()
+	h.finishRecording()
+	h.announceRecording(RecordingState{Active: false, ID: id})
+	return id, nil
+}
+
+// finishRecording закрывает файлы записи; вызывается и при завершении хаба
+func (h *Hub) finishRecording() {
+	h.session.Record(nil)
+	if _, err := h.recording.Stop(); err != nil {
+		log.Printf("Error stopping recording %s of room %s: %v", h.recording.ID(), h.roomID, err)
+	} else {
+		log.Printf("Recording %s of room %s stopped", h.recording.ID(), h.roomID)
+	}
+	h.recording = nil
+	h.recordedBy = ""
+
+	if h.recordOnly {
+		h.session.Close()
+		h.session = nil
+		h.recordOnly = false
+	}
+}
+
+// recordingState — индикатор для ответа register; nil, если записи нет
+func (h *Hub) recordingState() *RecordingState {
+	if h.recording == nil {
+		return nil
+	}
+	return &RecordingState{Active: true, ID: h.recording.ID(), By: h.recordedBy}
+}
+
+// announceRecording рассылает индикатор записи участникам на всех узлах
+func (h *Hub) announceRecording(state RecordingState) {
+	answer := AnswerType{Type: MessageTypeRecording, Recording: &state}
+	h.broadcast(answer)
+	h.publishBroadcast(answer)
+}
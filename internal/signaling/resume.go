@@ -0,0 +1,170 @@
+package signaling
+
+import (
+	"log"
+	"time"
+)
+
+// heldQueueSize — сколько адресованных участнику сообщений копится, пока
+// его место удерживается; остальные теряются
+const heldQueueSize = 256
+
+// ResumeInfo — возобновление сессии после обрыва сокета, в ответе register.
+// Клиент переподключается с ?resume=<token> в пределах ttl секунд.
+type ResumeInfo struct {
+	Token string `json:"token"`
+	TTL   int    `json:"ttl"`
+	// Resumed — место сохранилось: соединения WebRTC с участниками живы,
+	// а пропущенные сигнальные сообщения приходят следом за register
+	Resumed bool `json:"resumed"`
+}
+
+// heldSlot — место подключения, чей сокет оборвался. Остальные видят его
+// в состоянии reconnecting; адресованные ему сообщения копятся в queue.
+type heldSlot struct {
+	client *Client
+	queue  []interface{}
+	timer  *time.Timer
+}
+
+// heldExpiry — истекло ожидание переподключения; token отличает слот
+// от более позднего слота того же подключения
+type heldExpiry struct {
+	connID string
+	token  string
+}
+
+// resumeInfo выдает клиенту новый токен возобновления
+func (h *Hub) resumeInfo(client *Client, resumed bool) *ResumeInfo {
+	grace := h.cfg.WebSocket.ResumeGrace
+	if grace <= 0 {
+		return nil
+	}
+	client.resumeToken = randomID(16)
+	if client.resumeToken == "" {
+		return nil
+	}
+	return &ResumeInfo{Token: client.resumeToken, TTL: int(grace.Seconds()), Resumed: resumed}
+}
+
+// hold удерживает место клиента, чей сокет оборвался; false — возобновление
+// выключено, и клиента нужно убрать сразу
+func (h *Hub) hold(client *Client) bool {
+	grace := h.cfg.WebSocket.ResumeGrace
+	if grace <= 0 || client.resumeToken == "" {
+		return false
+	}
+
+	log.Printf("Client %s disconnected from room %s; holding connection %s for %v", client.id, h.roomID, client.connID, grace)
+	expiry := heldExpiry{connID: client.connID, token: client.resumeToken}
+	h.held[client.connID] = &heldSlot{
+		client: client,
+		timer: time.AfterFunc(grace, func() {
+			select {
+			case h.expire <- expiry:
+			case <-h.done:
+			}
+		}),
+	}
+
+	h.broadcast(AnswerType{
+		Type:         MessageTypeReconnecting,
+		Clients:      map[string]bool{client.id: false},
+		ConnectionID: client.connID,
+	})
+	h.publish(event{Kind: eventReconnecting, UserID: client.id, Conn: client.connID})
+	return true
+}
+
+// unhold снимает удержание и возвращает слот; nil — места не держали
+func (h *Hub) unhold(connID string) *heldSlot {
+	slot, ok := h.held[connID]
+	if !ok {
+		return nil
+	}
+	slot.timer.Stop()
+	delete(h.held, connID)
+	return slot
+}
+
+// resumable ищет удержанное место, которое клиент возобновляет своим токеном
+func (h *Hub) resumable(client *Client) *heldSlot {
+	if client.resume == "" {
+		return nil
+	}
+	for _, slot := range h.held {
+		if slot.client.id == client.id && slot.client.resumeToken == client.resume {
+			return slot
+		}
+	}
+	return nil
+}
+
+// expireHeld освобождает место, если клиент не вернулся вовремя
+func (h *Hub) expireHeld(exp heldExpiry) {
+	slot, ok := h.held[exp.connID]
+	if !ok || slot.client.resumeToken != exp.token {
+		return
+	}
+	h.unhold(exp.connID)
+	log.Printf("Client %s did not reconnect to room %s in time", slot.client.id, h.roomID)
+	h.leave(slot.client)
+}
+
+// leave сообщает на всех узлах, что подключение клиента вышло, и отключает
+// его от медиа
+func (h *Hub) leave(client *Client) {
+	h.announcePresence(client.id, client.connID, false)
+	h.publish(event{Kind: eventLeave, UserID: client.id, Conn: client.connID})
+	h.leaveMedia(client.connID)
+}
+
+// resume возвращает клиента на удержанное место: подключение сохраняет ID,
+// соединения WebRTC и состояние медиа, участники получают reconnected
+// вместо new-user
+func (h *Hub) resume(client *Client, slot *heldSlot) {
+	log.Printf("Client %s resumed connection %s in room %s", client.id, slot.client.connID, h.roomID)
+	client.connID = slot.client.connID
+	client.media = slot.client.media
+	h.clients[client.connID] = client
+	client.send <- h.registerAnswer(client, true)
+	h.replay(client, slot.queue)
+
+	reconnected := AnswerType{
+		Type:         MessageTypeReconnected,
+		Clients:      map[string]bool{client.id: true},
+		ConnectionID: client.connID,
+	}
+	for id, c := range h.clients {
+		if id != client.connID {
+			h.send(c, reconnected)
+		}
+	}
+	h.publish(event{Kind: eventReconnected, UserID: client.id, Conn: client.connID})
+}
+
+// queue копит сообщение для удерживаемого подключения; false — подключение не держат
+func (h *Hub) queue(connID string, message interface{}) bool {
+	slot, ok := h.held[connID]
+	if !ok {
+		return false
+	}
+	if len(slot.queue) >= heldQueueSize {
+		log.Printf("Resume queue of %s in room %s is full; dropping message", slot.client.id, h.roomID)
+		return true
+	}
+	slot.queue = append(slot.queue, message)
+	return true
+}
+
+// replay отправляет возобновленному клиенту накопленные сообщения
+func (h *Hub) replay(client *Client, queue []interface{}) {
+	for i, message := range queue {
+		select {
+		case client.send <- message:
+		default:
+			log.Printf("Send buffer of %s is full; %d queued messages lost", client.id, len(queue)-i)
+			return
+		}
+	}
+}
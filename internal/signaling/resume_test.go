@@ -0,0 +1,180 @@
+package signaling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHold(t *testing.T) {
+	tests := []struct {
+		name    string
+		grace   time.Duration
+		leaving bool
+		token   bool
+		want    bool
+	}{
+		{"held", time.Minute, false, true, true},
+		{"resume disabled", 0, false, true, false},
+		{"left on purpose", time.Minute, true, true, false},
+		{"no resume token", time.Minute, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHub(t, tt.grace)
+			alice := h.addClient("alice", "a1")
+			bob := h.addClient("bob", "b1")
+			if tt.token {
+				alice.resumeToken = "token"
+			}
+
+			h.drop(alice, tt.leaving)
+
+			if _, held := h.held["a1"]; held != tt.want {
+				t.Fatalf("held = %v, want %v", held, tt.want)
+			}
+			if _, ok := h.clients["a1"]; ok {
+				t.Error("dropped client is still in the room")
+			}
+			want := MessageTypeUserLeft
+			if tt.want {
+				want = MessageTypeReconnecting
+			}
+			if got := types(received(bob)); len(got) != 1 || got[0] != want {
+				t.Errorf("bob received %v, want [%s]", got, want)
+			}
+		})
+	}
+}
+
+func TestResume(t *testing.T) {
+	h := newTestHub(t, time.Minute)
+	alice := h.addClient("alice", "a1")
+	bob := h.addClient("bob", "b1")
+	alice.resumeToken = "token"
+	h.drop(alice, false)
+	received(bob)
+
+	// Сообщения для удержанного подключения копятся
+	if !h.relay(&VideoChatMessage{To: "a1", FromConnection: "b1", Type: string(MessageTypeOffer)}) {
+		t.Fatal("relay to a held connection returned false")
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		resume string
+		found  bool
+	}{
+		{"no token", "alice", "", false},
+		{"wrong token", "alice", "other", false},
+		{"another user", "bob", "token", false},
+		{"owner with token", "alice", "token", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{id: tt.userID, connID: "new", resume: tt.resume}
+			if slot := h.resumable(c); (slot != nil) != tt.found {
+				t.Errorf("resumable = %v, want found %v", slot, tt.found)
+			}
+		})
+	}
+
+	back := &Client{hub: h.Hub, id: "alice", connID: "a2", resume: "token", send: make(chan interface{}, 16)}
+	slot := h.unhold(h.resumable(back).client.connID)
+	h.resume(back, slot)
+
+	if back.connID != "a1" || h.clients["a1"] != back {
+		t.Fatalf("resumed client has connection %q, want a1", back.connID)
+	}
+	msgs := received(back)
+	if got := types(msgs); len(got) != 2 || got[0] != MessageTypeRegister || got[1] != MessageTypeVideoChat {
+		t.Fatalf("resumed client received %v, want register and the queued videochat", got)
+	}
+	register := msgs[0].(AnswerType)
+	if register.Resume == nil || !register.Resume.Resumed || register.Resume.Token == "token" {
+		t.Errorf("register resume = %+v, want resumed with a new token", register.Resume)
+	}
+	if got := types(received(bob)); len(got) != 1 || got[0] != MessageTypeReconnected {
+		t.Errorf("bob received %v, want [reconnected]", got)
+	}
+	if len(h.held) != 0 {
+		t.Errorf("slots still held: %v", h.held)
+	}
+}
+
+func TestExpireHeld(t *testing.T) {
+	h := newTestHub(t, time.Minute)
+	alice := h.addClient("alice", "a1")
+	bob := h.addClient("bob", "b1")
+	alice.resumeToken = "token"
+	h.drop(alice, false)
+	received(bob)
+
+	// Истечение от прошлого удержания того же подключения не действует
+	h.expireHeld(heldExpiry{connID: "a1", token: "stale"})
+	if _, ok := h.held["a1"]; !ok {
+		t.Fatal("stale expiry released the slot")
+	}
+	if got := received(bob); len(got) != 0 {
+		t.Fatalf("bob received %v after a stale expiry", types(got))
+	}
+
+	h.expireHeld(heldExpiry{connID: "a1", token: "token"})
+	if _, ok := h.held["a1"]; ok {
+		t.Fatal("slot is still held after expiry")
+	}
+	if got := types(received(bob)); len(got) != 1 || got[0] != MessageTypeUserLeft {
+		t.Errorf("bob received %v, want [user-left]", got)
+	}
+}
+
+func TestHeldQueueIsBounded(t *testing.T) {
+	h := newTestHub(t, time.Minute)
+	alice := h.addClient("alice", "a1")
+	h.addClient("bob", "b1")
+	alice.resumeToken = "token"
+	h.drop(alice, false)
+
+	for i := 0; i < heldQueueSize+10; i++ {
+		h.relay(&VideoChatMessage{To: "a1", FromConnection: "b1"})
+	}
+	if n := len(h.held["a1"].queue); n != heldQueueSize {
+		t.Errorf("queue length = %d, want %d", n, heldQueueSize)
+	}
+}
+
+func TestDeliverDropsSlowClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		grace    time.Duration
+		wantHeld bool
+	}{
+		{"held for resume", time.Minute, true},
+		{"left without resume", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHub(t, tt.grace)
+			slow := h.addClientBuffered("alice", "a1", 1)
+			slow.resumeToken = "token"
+			h.addClient("bob", "b1")
+
+			h.deliver(slow, AnswerType{Type: MessageTypeChat})
+			h.deliver(slow, AnswerType{Type: MessageTypeChat})
+
+			if _, ok := h.clients["a1"]; ok {
+				t.Fatal("slow client is still in the room")
+			}
+			if _, held := h.held["a1"]; held != tt.wantHeld {
+				t.Errorf("held = %v, want %v", held, tt.wantHeld)
+			}
+			// Буфер закрыт: писатель закроет сокет, дочитав то, что успело попасть
+			if got := received(slow); len(got) != 1 {
+				t.Errorf("slow client has %d buffered messages, want 1", len(got))
+			}
+			if _, open := <-slow.send; open {
+				t.Error("send channel is not closed")
+			}
+		})
+	}
+}
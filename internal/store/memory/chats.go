@@ -0,0 +1,249 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+	"sort"
+)
+
+type chatStore struct{ *db }
+
+func (s *chatStore) Get(_ context.Context, chatID string) (store.Chat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, ok := s.chats[chatID]
+	if !ok {
+		return store.Chat{}, store.ErrNotFound
+	}
+	return chat, nil
+}
+
+func (s *chatStore) Create(_ context.Context, chat store.Chat, ownerID string, memberIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chats[chat.ID]; ok {
+		return store.ErrConflict
+	}
+
+	s.chats[chat.ID] = chat
+	s.members[memberKey{store.ScopeChat, chat.ID}] = make(map[string]store.Member)
+	if ownerID != "" {
+		s.addMember(store.ScopeChat, chat.ID, ownerID, store.RoleOwner)
+	}
+	for _, userID := range memberIDs {
+		s.addMember(store.ScopeChat, chat.ID, userID, store.RoleMember)
+	}
+	return nil
+}
+
+func (s *chatStore) CreatePrivate(_ context.Context, chat store.Chat, userID, friendID string) (store.Chat, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.areFriends(userID, friendID) {
+		return store.Chat{}, false, store.ErrNotFound
+	}
+
+	for id, existing := range s.chats {
+		if existing.Type != store.ChatTypePrivate {
+			continue
+		}
+		members := s.members[memberKey{store.ScopeChat, id}]
+		_, hasUser := members[userID]
+		_, hasFriend := members[friendID]
+		if hasUser && hasFriend {
+			return existing, false, nil
+		}
+	}
+
+	if _, ok := s.chats[chat.ID]; ok {
+		return store.Chat{}, false, store.ErrConflict
+	}
+	chat.Type = store.ChatTypePrivate
+	s.chats[chat.ID] = chat
+	s.addMember(store.ScopeChat, chat.ID, userID, store.RoleMember)
+	s.addMember(store.ScopeChat, chat.ID, friendID, store.RoleMember)
+	return chat, true, nil
+}
+
+func (s *chatStore) AddParticipant(_ context.Context, chatID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chats[chatID]; !ok {
+		return store.ErrNotFound
+	}
+	s.addMember(store.ScopeChat, chatID, userID, store.RoleMember)
+	return nil
+}
+
+func (s *chatStore) IsParticipant(_ context.Context, chatID, userID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.members[memberKey{store.ScopeChat, chatID}][userID]
+	return ok, nil
+}
+
+func (s *chatStore) SharingChats(_ context.Context, userID string, others []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var shared []string
+	for _, other := range others {
+		for key, members := range s.members {
+			if key.scope != store.ScopeChat {
+				continue
+			}
+			_, hasUser := members[userID]
+			_, hasOther := members[other]
+			if hasUser && hasOther {
+				shared = append(shared, other)
+				break
+			}
+		}
+	}
+	return shared, nil
+}
+
+func (s *chatStore) MarkRead(_ context.Context, chatID, userID string, messageID int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[memberKey{store.ScopeChat, chatID}][userID]; !ok {
+		return 0, false, store.ErrNotFound
+	}
+
+	last := 0
+	if all := s.messages[chatID]; len(all) > 0 {
+		last = all[len(all)-1].ID
+	}
+	cursor := s.readCursors[chatID][userID]
+	target := min(messageID, last)
+	if target <= cursor {
+		return cursor, false, nil
+	}
+
+	if s.readCursors[chatID] == nil {
+		s.readCursors[chatID] = make(map[string]int)
+	}
+	s.readCursors[chatID][userID] = target
+	for i := range s.messages[chatID] {
+		msg := &s.messages[chatID][i]
+		if msg.ID <= target && msg.SenderID != userID {
+			msg.IsRead = true
+		}
+	}
+	return target, true, nil
+}
+
+func (s *chatStore) ReadCursors(_ context.Context, chatID string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cursors := make(map[string]int)
+	for userID := range s.members[memberKey{store.ScopeChat, chatID}] {
+		cursors[userID] = s.readCursors[chatID][userID]
+	}
+	return cursors, nil
+}
+
+func (s *chatStore) UnreadCount(_ context.Context, chatID, userID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.members[memberKey{store.ScopeChat, chatID}][userID]; !ok {
+		return 0, store.ErrNotFound
+	}
+	return s.unread(chatID, userID), nil
+}
+
+// unread считает чужие неудаленные сообщения после курсора; вызывается под s.mu
+func (s *chatStore) unread(chatID, userID string) int {
+	cursor := s.readCursors[chatID][userID]
+	n := 0
+	for _, msg := range s.messages[chatID] {
+		if msg.ID > cursor && msg.SenderID != userID && msg.DeletedAt == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *chatStore) UnreadCounts(_ context.Context, userID string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for key, members := range s.members {
+		if key.scope != store.ScopeChat {
+			continue
+		}
+		if _, ok := members[userID]; !ok {
+			continue
+		}
+		counts[key.id] = s.unread(key.id, userID)
+	}
+	return counts, nil
+}
+
+func (s *chatStore) ListForUser(_ context.Context, userID string, limit, offset int) ([]store.ChatSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var chats []store.ChatSummary
+	for key, members := range s.members {
+		if key.scope != store.ScopeChat {
+			continue
+		}
+		me, ok := members[userID]
+		if !ok {
+			continue
+		}
+
+		summary := store.ChatSummary{
+			Chat:           s.chats[key.id],
+			Unread:         s.unread(key.id, userID),
+			LastActivityAt: me.JoinedAt,
+		}
+		if all := s.messages[key.id]; len(all) > 0 {
+			last := all[len(all)-1]
+			summary.LastMessage = &last
+			summary.LastActivityAt = last.CreatedAt
+		}
+
+		list := make([]store.Member, 0, len(members))
+		for _, m := range members {
+			list = append(list, m)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if !list[i].JoinedAt.Equal(list[j].JoinedAt) {
+				return list[i].JoinedAt.Before(list[j].JoinedAt)
+			}
+			return list[i].UserID < list[j].UserID
+		})
+		for _, m := range list {
+			summary.Participants = append(summary.Participants,
+				store.ChatParticipant{UserID: m.UserID, Name: s.users[m.UserID].Name})
+		}
+
+		chats = append(chats, summary)
+	}
+
+	sort.Slice(chats, func(i, j int) bool {
+		if !chats[i].LastActivityAt.Equal(chats[j].LastActivityAt) {
+			return chats[i].LastActivityAt.After(chats[j].LastActivityAt)
+		}
+		return chats[i].ID < chats[j].ID
+	})
+	if offset >= len(chats) {
+		return nil, nil
+	}
+	chats = chats[offset:]
+	if limit < len(chats) {
+		chats = chats[:limit]
+	}
+	return chats, nil
+}
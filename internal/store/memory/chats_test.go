@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"server/internal/store"
+)
+
+func TestCreatePrivate(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	for _, f := range []store.Friendship{
+		{UserID: "1", FriendID: "2", Status: store.FriendStatusAccepted},
+		{UserID: "1", FriendID: "3", Status: store.FriendStatusAccepted},
+		{UserID: "1", FriendID: "4", Status: store.FriendStatusPending},
+	} {
+		if _, err := st.Friendships.Create(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Chats.Create(ctx, store.Chat{ID: "private", Type: store.ChatTypePrivate}, "", "1", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Chats.Create(ctx, store.Chat{ID: "group", Type: store.ChatTypeGroup}, "1", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		userID, friendID string
+		want             string
+		created          bool
+	}{
+		{"existing", "1", "2", "private", false},
+		{"reverse order", "2", "1", "private", false},
+		{"group chat is not private", "1", "3", "new", true},
+		{"pending friendship", "1", "4", "", false},
+		{"stranger", "1", "5", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat, created, err := st.Chats.CreatePrivate(ctx, store.Chat{ID: "new"}, tt.userID, tt.friendID)
+			if tt.want == "" {
+				if !errors.Is(err, store.ErrNotFound) {
+					t.Errorf("CreatePrivate = %+v, %v; want ErrNotFound", chat, err)
+				}
+				return
+			}
+			if err != nil || chat.ID != tt.want || created != tt.created {
+				t.Errorf("CreatePrivate = %+v, %v, %v; want %s, %v", chat, created, err, tt.want, tt.created)
+			}
+			for _, userID := range []string{tt.userID, tt.friendID} {
+				if ok, _ := st.Chats.IsParticipant(ctx, chat.ID, userID); !ok {
+					t.Errorf("%s is not a participant of %s", userID, chat.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestCreatePrivateConcurrent(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	if _, err := st.Friendships.Create(ctx, store.Friendship{UserID: "1", FriendID: "2", Status: store.FriendStatusAccepted}); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 16
+	ids := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			userID, friendID := "1", "2"
+			if i%2 == 1 {
+				userID, friendID = friendID, userID
+			}
+			chat, _, err := st.Chats.CreatePrivate(ctx, store.Chat{ID: strconv.Itoa(i)}, userID, friendID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- chat.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	first := <-ids
+	for id := range ids {
+		if id != first {
+			t.Fatalf("concurrent requests created chats %s and %s", first, id)
+		}
+	}
+}
+
+func TestParticipants(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	if err := st.Chats.Create(ctx, store.Chat{ID: "c", Type: store.ChatTypeGroup}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Chats.Create(ctx, store.Chat{ID: "c"}, "2"); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("second Create: err = %v, want ErrConflict", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := st.Chats.AddParticipant(ctx, "c", "2"); err != nil {
+			t.Fatalf("AddParticipant #%d: %v", i+1, err)
+		}
+	}
+	if err := st.Chats.AddParticipant(ctx, "missing", "2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("AddParticipant to a missing chat: err = %v, want ErrNotFound", err)
+	}
+
+	for userID, want := range map[string]bool{"1": true, "2": true, "3": false} {
+		if ok, err := st.Chats.IsParticipant(ctx, "c", userID); err != nil || ok != want {
+			t.Errorf("IsParticipant(%s) = %v, %v; want %v", userID, ok, err, want)
+		}
+	}
+}
+
+func TestMarkReadIsMonotonic(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	newChat(t, st, "c", 5, "author", "reader")
+
+	steps := []struct {
+		name       string
+		messageID  int
+		wantCursor int
+		wantMoved  bool
+		wantUnread int
+	}{
+		{"forward", 2, 2, true, 3},
+		{"same", 2, 2, false, 3},
+		{"backward", 1, 2, false, 3},
+		{"clamped to last message", 100, 5, true, 0},
+		{"beyond last again", 200, 5, false, 0},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			cursor, moved, err := st.Chats.MarkRead(ctx, "c", "reader", step.messageID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cursor != step.wantCursor || moved != step.wantMoved {
+				t.Errorf("MarkRead(%d) = %d, %v; want %d, %v", step.messageID, cursor, moved, step.wantCursor, step.wantMoved)
+			}
+			unread, err := st.Chats.UnreadCount(ctx, "c", "reader")
+			if err != nil || unread != step.wantUnread {
+				t.Errorf("UnreadCount = %d, %v; want %d", unread, err, step.wantUnread)
+			}
+		})
+	}
+
+	cursors, err := st.Chats.ReadCursors(ctx, "c")
+	if err != nil || cursors["reader"] != 5 || cursors["author"] != 0 {
+		t.Errorf("ReadCursors = %v, %v", cursors, err)
+	}
+
+	// Чужие сообщения до курсора прочитаны
+	messages, _ := st.Messages.List(ctx, "c", store.MessageQuery{Limit: 10})
+	for _, msg := range messages {
+		if !msg.IsRead {
+			t.Errorf("message %d is not marked read", msg.ID)
+		}
+	}
+
+	if _, _, err := st.Chats.MarkRead(ctx, "c", "stranger", 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("MarkRead by a non-participant: err = %v, want ErrNotFound", err)
+	}
+	if _, err := st.Chats.UnreadCount(ctx, "c", "stranger"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UnreadCount of a non-participant: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUnreadCountsSkipOwnAndDeleted(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	newChat(t, st, "c", 3, "author", "reader")
+	if _, err := st.Messages.Create(ctx, store.Message{ChatID: "c", SenderID: "reader", Text: "own"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Messages.Delete(ctx, "c", 1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := st.Chats.UnreadCounts(ctx, "reader")
+	if err != nil || counts["c"] != 2 {
+		t.Errorf("UnreadCounts = %v, %v; want c: 2", counts, err)
+	}
+}
+
+func TestListForUserOrdersByActivity(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	newChat(t, st, "quiet", 1, "me", "a")
+	newChat(t, st, "empty", 0, "me", "b")
+	newChat(t, st, "busy", 1, "me", "c")
+	newChat(t, st, "other", 1, "x", "y")
+
+	chats, err := st.Chats.ListForUser(ctx, "me", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range chats {
+		got = append(got, c.ID)
+	}
+	// Пустой чат активен с момента вступления, то есть позже первого сообщения в quiet
+	want := []string{"busy", "empty", "quiet"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("ListForUser order = %v, want %v", got, want)
+	}
+	if chats[0].LastMessage == nil || chats[1].LastMessage != nil {
+		t.Errorf("last messages: busy %+v, empty %+v", chats[0].LastMessage, chats[1].LastMessage)
+	}
+
+	page, err := st.Chats.ListForUser(ctx, "me", 1, 1)
+	if err != nil || len(page) != 1 || page[0].ID != "empty" {
+		t.Errorf("ListForUser(limit 1, offset 1) = %+v, %v", page, err)
+	}
+}
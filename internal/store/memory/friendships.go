@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+)
+
+type friendshipStore struct{ *db }
+
+func (s *friendshipStore) Get(_ context.Context, userID, friendID string) (store.Friendship, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.friendships[[2]string{userID, friendID}]
+	if !ok {
+		return store.Friendship{}, store.ErrNotFound
+	}
+	return f, nil
+}
+
+func (s *friendshipStore) Create(_ context.Context, f store.Friendship) (store.Friendship, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{f.UserID, f.FriendID}
+	if _, ok := s.friendships[key]; ok {
+		return store.Friendship{}, store.ErrConflict
+	}
+	s.friendships[key] = f
+	return f, nil
+}
+
+func (s *friendshipStore) UpdateStatus(_ context.Context, userID, friendID string, status store.FriendStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{userID, friendID}
+	f, ok := s.friendships[key]
+	if !ok {
+		return store.ErrNotFound
+	}
+	f.Status = status
+	s.friendships[key] = f
+	return nil
+}
+
+func (s *friendshipStore) ListNames(_ context.Context, userID string, status store.FriendStatus) ([]store.FriendName, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var friends []store.FriendName
+	for key, f := range s.friendships {
+		if key[0] != userID || f.Status != status {
+			continue
+		}
+		if u, ok := s.users[key[1]]; ok {
+			friends = append(friends, store.FriendName{Name: u.Name, Status: f.Status})
+		}
+	}
+	return friends, nil
+}
+
+func (s *friendshipStore) ListAccepted(_ context.Context, userID string) ([]store.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var friends []store.User
+	for key, f := range s.friendships {
+		if f.Status != store.FriendStatusAccepted {
+			continue
+		}
+
+		var otherID string
+		switch userID {
+		case key[0]:
+			otherID = key[1]
+		case key[1]:
+			otherID = key[0]
+		default:
+			continue
+		}
+
+		if u, ok := s.users[otherID]; ok && !seen[otherID] {
+			seen[otherID] = true
+			friends = append(friends, store.User{ID: u.ID, Name: u.Name})
+		}
+	}
+	return friends, nil
+}
+
+func (s *friendshipStore) AreFriends(_ context.Context, userID, friendID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.areFriends(userID, friendID), nil
+}
+
+// areFriends — дружба подтверждена в любую сторону; вызывается под s.mu
+func (d *db) areFriends(userID, friendID string) bool {
+	for _, key := range [][2]string{{userID, friendID}, {friendID, userID}} {
+		if f, ok := d.friendships[key]; ok && f.Status == store.FriendStatusAccepted {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"server/internal/store"
+)
+
+func TestAreFriends(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	for _, f := range []store.Friendship{
+		{UserID: "1", FriendID: "2", Status: store.FriendStatusAccepted},
+		{UserID: "1", FriendID: "3", Status: store.FriendStatusPending},
+	} {
+		if _, err := st.Friendships.Create(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.Friendships.Create(ctx, store.Friendship{UserID: "1", FriendID: "2"}); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("duplicate Create: err = %v, want ErrConflict", err)
+	}
+
+	tests := []struct {
+		name             string
+		userID, friendID string
+		want             bool
+	}{
+		{"accepted", "1", "2", true},
+		{"accepted, other side", "2", "1", true},
+		{"pending", "1", "3", false},
+		{"strangers", "2", "3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := st.Friendships.AreFriends(ctx, tt.userID, tt.friendID)
+			if err != nil || got != tt.want {
+				t.Errorf("AreFriends = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	if err := st.Friendships.UpdateStatus(ctx, "1", "3", store.FriendStatusAccepted); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := st.Friendships.AreFriends(ctx, "3", "1"); !ok {
+		t.Error("friendship is not accepted after UpdateStatus")
+	}
+	if err := st.Friendships.UpdateStatus(ctx, "2", "3", store.FriendStatusAccepted); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateStatus of a missing friendship: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+	"sort"
+	"time"
+)
+
+type inviteStore struct{ *db }
+
+func (s *inviteStore) Create(_ context.Context, inv store.RoomInvite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invites[inv.Code]; ok {
+		return store.ErrConflict
+	}
+	inv.Uses = 0
+	inv.CreatedAt = time.Now()
+	inv.RevokedAt = nil
+	s.invites[inv.Code] = inv
+	return nil
+}
+
+func (s *inviteStore) Get(_ context.Context, code string) (store.RoomInvite, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	inv, ok := s.invites[code]
+	if !ok {
+		return store.RoomInvite{}, store.ErrNotFound
+	}
+	return inv, nil
+}
+
+func (s *inviteStore) ListByRoom(_ context.Context, roomID string) ([]store.RoomInvite, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var invites []store.RoomInvite
+	for _, inv := range s.invites {
+		if inv.RoomID == roomID {
+			invites = append(invites, inv)
+		}
+	}
+
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.After(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+func (s *inviteStore) Redeem(_ context.Context, code string, now time.Time) (store.RoomInvite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invites[code]
+	if !ok || inv.RevokedAt != nil ||
+		(inv.ExpiresAt != nil && !inv.ExpiresAt.After(now)) ||
+		(inv.MaxUses > 0 && inv.Uses >= inv.MaxUses) {
+		return store.RoomInvite{}, store.ErrNotFound
+	}
+
+	inv.Uses++
+	s.invites[code] = inv
+	return inv, nil
+}
+
+func (s *inviteStore) Revoke(_ context.Context, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invites[code]
+	if !ok || inv.RevokedAt != nil {
+		return store.ErrNotFound
+	}
+	now := time.Now()
+	inv.RevokedAt = &now
+	s.invites[code] = inv
+	return nil
+}
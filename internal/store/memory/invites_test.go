@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"server/internal/store"
+)
+
+func TestInviteRedeem(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	tests := []struct {
+		name    string
+		invite  store.RoomInvite
+		revoke  bool
+		redeems int // успешных использований до отказа; -1 — без отказа
+	}{
+		{"unlimited", store.RoomInvite{}, false, -1},
+		{"max uses", store.RoomInvite{MaxUses: 2}, false, 2},
+		{"expired", store.RoomInvite{ExpiresAt: &past}, false, 0},
+		{"not yet expired", store.RoomInvite{ExpiresAt: &future, MaxUses: 1}, false, 1},
+		{"revoked", store.RoomInvite{}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			st := New()
+			inv := tt.invite
+			inv.Code, inv.RoomID, inv.CreatedBy = "code", "room", "owner"
+			if err := st.Invites.Create(ctx, inv); err != nil {
+				t.Fatal(err)
+			}
+			if tt.revoke {
+				if err := st.Invites.Revoke(ctx, "code"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			attempts := tt.redeems + 1
+			if tt.redeems < 0 {
+				attempts = 5
+			}
+			for i := 1; i <= attempts; i++ {
+				redeemed, err := st.Invites.Redeem(ctx, "code", now)
+				wantOK := tt.redeems < 0 || i <= tt.redeems
+				if wantOK && (err != nil || redeemed.Uses != i) {
+					t.Fatalf("redeem %d = %+v, %v; want uses %d", i, redeemed, err, i)
+				}
+				if !wantOK && !errors.Is(err, store.ErrNotFound) {
+					t.Fatalf("redeem %d: err = %v, want ErrNotFound", i, err)
+				}
+			}
+		})
+	}
+
+	if _, err := New().Invites.Redeem(context.Background(), "missing", now); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Redeem of a missing invite: err = %v, want ErrNotFound", err)
+	}
+}
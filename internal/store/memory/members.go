@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+	"sort"
+)
+
+type memberStore struct{ *db }
+
+func (s *memberStore) Get(_ context.Context, scope store.Scope, resourceID, userID string) (store.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.members[memberKey{scope, resourceID}][userID]
+	if !ok {
+		return store.Member{}, store.ErrNotFound
+	}
+	return m, nil
+}
+
+func (s *memberStore) List(_ context.Context, scope store.Scope, resourceID string) ([]store.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var members []store.Member
+	for _, m := range s.members[memberKey{scope, resourceID}] {
+		members = append(members, m)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].JoinedAt.Equal(members[j].JoinedAt) {
+			return members[i].JoinedAt.Before(members[j].JoinedAt)
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *memberStore) Add(_ context.Context, scope store.Scope, resourceID, userID string, role store.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addMember(scope, resourceID, userID, role)
+	return nil
+}
+
+func (s *memberStore) SetRole(_ context.Context, scope store.Scope, resourceID, userID string, role store.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := s.members[memberKey{scope, resourceID}]
+	m, ok := members[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	m.Role = role
+	members[userID] = m
+	return nil
+}
+
+func (s *memberStore) Remove(_ context.Context, scope store.Scope, resourceID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := s.members[memberKey{scope, resourceID}]
+	if _, ok := members[userID]; !ok {
+		return store.ErrNotFound
+	}
+	delete(members, userID)
+	return nil
+}
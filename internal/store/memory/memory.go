@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"server/internal/store"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// db — общее состояние всех in-memory хранилищ, защищенное одним мьютексом
+type db struct {
+	mu sync.RWMutex
+
+	users        map[string]store.User
+	presence     map[string]store.UserPresence // userID -> статус и последний визит
+	friendships  map[[2]string]store.Friendship
+	chats        map[string]store.Chat
+	members      map[memberKey]map[string]store.Member // (scope, id) -> userID -> участник
+	messages     map[string][]store.Message            // chatID -> сообщения по возрастанию id
+	revisions    map[int][]store.MessageRevision       // messageID -> правки по возрастанию id
+	readCursors  map[string]map[string]int             // chatID -> userID -> последнее прочитанное
+	rooms        map[string]store.Room
+	roomMessages map[string][]store.RoomMessage
+	invites      map[string]store.RoomInvite
+	sanctions    map[sanctionKey]store.Sanction
+	refresh      map[string]store.RefreshToken
+	revoked      map[string]time.Time // jti -> expires_at
+
+	nextUserID    int
+	nextMessageID int
+	nextRevision  int
+	nextRoomID    int
+}
+
+type memberKey struct {
+	scope store.Scope
+	id    string
+}
+
+type sanctionKey struct {
+	memberKey
+	userID string
+	kind   store.SanctionKind
+}
+
+// addMember добавляет участника, если его еще нет; вызывается под s.mu
+func (d *db) addMember(scope store.Scope, resourceID, userID string, role store.Role) {
+	key := memberKey{scope, resourceID}
+	if d.members[key] == nil {
+		d.members[key] = make(map[string]store.Member)
+	}
+	if _, ok := d.members[key][userID]; !ok {
+		d.members[key][userID] = store.Member{UserID: userID, Role: role, JoinedAt: time.Now()}
+	}
+}
+
+// New возвращает хранилище в памяти — для тестов и локального запуска без базы
+func New() *store.Store {
+	d := &db{
+		users:        make(map[string]store.User),
+		presence:     make(map[string]store.UserPresence),
+		friendships:  make(map[[2]string]store.Friendship),
+		chats:        make(map[string]store.Chat),
+		members:      make(map[memberKey]map[string]store.Member),
+		messages:     make(map[string][]store.Message),
+		revisions:    make(map[int][]store.MessageRevision),
+		readCursors:  make(map[string]map[string]int),
+		rooms:        make(map[string]store.Room),
+		roomMessages: make(map[string][]store.RoomMessage),
+		invites:      make(map[string]store.RoomInvite),
+		sanctions:    make(map[sanctionKey]store.Sanction),
+		refresh:      make(map[string]store.RefreshToken),
+		revoked:      make(map[string]time.Time),
+	}
+
+	return &store.Store{
+		Users:       &userStore{d},
+		Friendships: &friendshipStore{d},
+		Chats:       &chatStore{d},
+		Messages:    &messageStore{d},
+		Rooms:       &roomStore{d},
+		Invites:     &inviteStore{d},
+		Members:     &memberStore{d},
+		Sanctions:   &sanctionStore{d},
+		Tokens:      &tokenStore{d},
+	}
+}
+
+// idLess сравнивает числовые строковые ID, чтобы сортировать как ORDER BY id
+func idLess(a, b string) bool {
+	ai, _ := strconv.Atoi(a)
+	bi, _ := strconv.Atoi(b)
+	return ai < bi
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+	"sort"
+	"time"
+)
+
+type messageStore struct{ *db }
+
+func (s *messageStore) Create(_ context.Context, msg store.Message) (store.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chats[msg.ChatID]; !ok {
+		return store.Message{}, store.ErrNotFound
+	}
+
+	s.nextMessageID++
+	msg.ID = s.nextMessageID
+	msg.CreatedAt = time.Now()
+	s.messages[msg.ChatID] = append(s.messages[msg.ChatID], msg)
+	return msg, nil
+}
+
+func (s *messageStore) List(_ context.Context, chatID string, q store.MessageQuery) ([]store.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// [lo, hi) — сообщения между границами
+	all := s.messages[chatID]
+	lo := sort.Search(len(all), func(i int) bool { return all[i].ID > q.AfterID })
+	hi := len(all)
+	if q.BeforeID > 0 {
+		hi = sort.Search(len(all), func(i int) bool { return all[i].ID >= q.BeforeID })
+	}
+	if q.AfterID > 0 {
+		hi = min(hi, lo+q.Limit)
+	} else {
+		lo = max(lo, hi-q.Limit)
+	}
+
+	messages := []store.Message{}
+	for i := hi - 1; i >= lo; i-- {
+		messages = append(messages, all[i])
+	}
+	return messages, nil
+}
+
+func (s *messageStore) Count(_ context.Context, chatID string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.messages[chatID]), nil
+}
+
+// find возвращает индекс сообщения в s.messages[chatID]; вызывается под s.mu
+func (s *messageStore) find(chatID string, id int) (int, bool) {
+	all := s.messages[chatID]
+	i := sort.Search(len(all), func(i int) bool { return all[i].ID >= id })
+	return i, i < len(all) && all[i].ID == id
+}
+
+func (s *messageStore) Get(_ context.Context, chatID string, id int) (store.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.find(chatID, id)
+	if !ok {
+		return store.Message{}, store.ErrNotFound
+	}
+	return s.messages[chatID][i], nil
+}
+
+func (s *messageStore) Edit(_ context.Context, chatID string, id int, text, editorID string, at time.Time) (store.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.find(chatID, id)
+	if !ok || s.messages[chatID][i].DeletedAt != nil {
+		return store.Message{}, store.ErrNotFound
+	}
+
+	msg := &s.messages[chatID][i]
+	s.nextRevision++
+	s.revisions[id] = append(s.revisions[id], store.MessageRevision{
+		ID:        s.nextRevision,
+		MessageID: id,
+		Text:      msg.Text,
+		EditedBy:  editorID,
+		CreatedAt: at,
+	})
+	msg.Text = text
+	msg.EditedAt = &at
+	return *msg, nil
+}
+
+func (s *messageStore) Delete(_ context.Context, chatID string, id int, at time.Time) (store.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.find(chatID, id)
+	if !ok || s.messages[chatID][i].DeletedAt != nil {
+		return store.Message{}, store.ErrNotFound
+	}
+
+	msg := &s.messages[chatID][i]
+	msg.Text = ""
+	msg.DeletedAt = &at
+	delete(s.revisions, id)
+	return *msg, nil
+}
+
+func (s *messageStore) Revisions(_ context.Context, chatID string, id int) ([]store.MessageRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.find(chatID, id); !ok {
+		return nil, store.ErrNotFound
+	}
+	return append([]store.MessageRevision{}, s.revisions[id]...), nil
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"server/internal/store"
+)
+
+// newChat создает групповой чат с участниками и n сообщениями от первого из
+// них (он же владелец); ID сообщений — 1..n
+func newChat(t *testing.T, st *store.Store, chatID string, n int, members ...string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := st.Chats.Create(ctx, store.Chat{ID: chatID, Type: store.ChatTypeGroup}, members[0], members[1:]...); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := st.Messages.Create(ctx, store.Message{ChatID: chatID, SenderID: members[0], Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func ids(messages []store.Message) []int {
+	result := []int{}
+	for _, msg := range messages {
+		result = append(result, msg.ID)
+	}
+	return result
+}
+
+func TestMessageListBounds(t *testing.T) {
+	st := New()
+	newChat(t, st, "c", 10, "1")
+
+	tests := []struct {
+		name string
+		q    store.MessageQuery
+		want []int
+	}{
+		{"latest", store.MessageQuery{Limit: 3}, []int{10, 9, 8}},
+		{"all", store.MessageQuery{Limit: 50}, []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}},
+		{"before", store.MessageQuery{BeforeID: 5, Limit: 3}, []int{4, 3, 2}},
+		{"before near start", store.MessageQuery{BeforeID: 3, Limit: 5}, []int{2, 1}},
+		{"before first", store.MessageQuery{BeforeID: 1, Limit: 5}, []int{}},
+		{"after", store.MessageQuery{AfterID: 5, Limit: 3}, []int{8, 7, 6}},
+		{"after near end", store.MessageQuery{AfterID: 8, Limit: 5}, []int{10, 9}},
+		{"after last", store.MessageQuery{AfterID: 10, Limit: 5}, []int{}},
+		{"between", store.MessageQuery{AfterID: 2, BeforeID: 6, Limit: 10}, []int{5, 4, 3}},
+		{"between limited from after", store.MessageQuery{AfterID: 2, BeforeID: 9, Limit: 2}, []int{4, 3}},
+		{"before beyond end", store.MessageQuery{BeforeID: 100, Limit: 2}, []int{10, 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := st.Messages.List(context.Background(), "c", tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids(got), tt.want) {
+				t.Errorf("List(%+v) = %v, want %v", tt.q, ids(got), tt.want)
+			}
+		})
+	}
+
+	n, err := st.Messages.Count(context.Background(), "c")
+	if err != nil || n != 10 {
+		t.Errorf("Count = %d, %v; want 10", n, err)
+	}
+}
+
+func TestMessageCreateInMissingChat(t *testing.T) {
+	_, err := New().Messages.Create(context.Background(), store.Message{ChatID: "missing", SenderID: "1", Text: "hi"})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMessageEditAndDelete(t *testing.T) {
+	ctx := context.Background()
+	st := New()
+	newChat(t, st, "c", 1, "1")
+
+	if _, err := st.Messages.Edit(ctx, "c", 1, "edited", "1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := st.Messages.Revisions(ctx, "c", 1)
+	if err != nil || len(revisions) != 1 || revisions[0].Text != "hi" {
+		t.Fatalf("Revisions = %+v, %v; want the original text", revisions, err)
+	}
+
+	deleted, err := st.Messages.Delete(ctx, "c", 1, time.Now())
+	if err != nil || deleted.Text != "" || deleted.DeletedAt == nil {
+		t.Fatalf("Delete = %+v, %v; want a tombstone", deleted, err)
+	}
+	if revisions, _ := st.Messages.Revisions(ctx, "c", 1); len(revisions) != 0 {
+		t.Errorf("revisions kept after delete: %+v", revisions)
+	}
+	if _, err := st.Messages.Edit(ctx, "c", 1, "again", "1", time.Now()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Edit of a deleted message: err = %v, want ErrNotFound", err)
+	}
+	if _, err := st.Messages.Delete(ctx, "c", 1, time.Now()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Delete: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type roomStore struct{ *db }
+
+func (s *roomStore) Create(_ context.Context, room store.Room) (store.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextRoomID++
+	room.ID = strconv.Itoa(s.nextRoomID)
+	s.rooms[room.ID] = room
+	s.addMember(store.ScopeRoom, room.ID, room.CreatedBy, store.RoleOwner)
+	return room, nil
+}
+
+func (s *roomStore) Get(_ context.Context, roomID string) (store.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return store.Room{}, store.ErrNotFound
+	}
+	return room, nil
+}
+
+func (s *roomStore) ListByMember(_ context.Context, userID string) ([]store.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rooms []store.Room
+	for _, room := range s.rooms {
+		if _, ok := s.members[memberKey{store.ScopeRoom, room.ID}][userID]; ok {
+			rooms = append(rooms, room)
+		}
+	}
+
+	sort.Slice(rooms, func(i, j int) bool { return idLess(rooms[j].ID, rooms[i].ID) })
+	return rooms, nil
+}
+
+func (s *roomStore) ListJoinable(_ context.Context, userID string, limit, offset int) ([]store.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rooms []store.Room
+	for _, room := range s.rooms {
+		if room.Visibility != store.RoomPublic || room.ArchivedAt != nil {
+			continue
+		}
+		if _, ok := s.members[memberKey{store.ScopeRoom, room.ID}][userID]; !ok {
+			rooms = append(rooms, room)
+		}
+	}
+
+	sort.Slice(rooms, func(i, j int) bool { return idLess(rooms[j].ID, rooms[i].ID) })
+	if offset >= len(rooms) {
+		return nil, nil
+	}
+	rooms = rooms[offset:]
+	if limit < len(rooms) {
+		rooms = rooms[:limit]
+	}
+	return rooms, nil
+}
+
+func (s *roomStore) Update(_ context.Context, roomID string, upd store.RoomUpdate) (store.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return store.Room{}, store.ErrNotFound
+	}
+
+	if upd.Name != nil {
+		room.Name = *upd.Name
+	}
+	if upd.Visibility != nil {
+		room.Visibility = *upd.Visibility
+	}
+	if upd.MaxParticipants != nil {
+		room.MaxParticipants = *upd.MaxParticipants
+	}
+	if upd.Lobby != nil {
+		room.Lobby = *upd.Lobby
+	}
+	if upd.MediaMode != nil {
+		room.MediaMode = *upd.MediaMode
+	}
+	if upd.Archived != nil {
+		switch {
+		case *upd.Archived && room.ArchivedAt == nil:
+			now := time.Now()
+			room.ArchivedAt = &now
+		case !*upd.Archived:
+			room.ArchivedAt = nil
+		}
+	}
+
+	s.rooms[roomID] = room
+	return room, nil
+}
+
+func (s *roomStore) Delete(_ context.Context, roomID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rooms[roomID]; !ok {
+		return store.ErrNotFound
+	}
+
+	delete(s.rooms, roomID)
+	delete(s.roomMessages, roomID)
+	delete(s.members, memberKey{store.ScopeRoom, roomID})
+	for code, inv := range s.invites {
+		if inv.RoomID == roomID {
+			delete(s.invites, code)
+		}
+	}
+	for key := range s.sanctions {
+		if key.scope == store.ScopeRoom && key.id == roomID {
+			delete(s.sanctions, key)
+		}
+	}
+	return nil
+}
+
+func (s *roomStore) AppendMessage(_ context.Context, roomID string, msg store.RoomMessage) ([]store.RoomMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rooms[roomID]; !ok {
+		return nil, store.ErrNotFound
+	}
+
+	s.roomMessages[roomID] = append(s.roomMessages[roomID], msg)
+	return append([]store.RoomMessage(nil), s.roomMessages[roomID]...), nil
+}
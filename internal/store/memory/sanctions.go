@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+	"sort"
+	"time"
+)
+
+type sanctionStore struct{ *db }
+
+func (s *sanctionStore) Put(_ context.Context, sn store.Sanction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sn.CreatedAt = time.Now()
+	s.sanctions[sanctionKey{memberKey{sn.Scope, sn.ResourceID}, sn.UserID, sn.Kind}] = sn
+	return nil
+}
+
+func (s *sanctionStore) Lift(_ context.Context, scope store.Scope, resourceID, userID string, kind store.SanctionKind) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := sanctionKey{memberKey{scope, resourceID}, userID, kind}
+	if _, ok := s.sanctions[key]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.sanctions, key)
+	return nil
+}
+
+func (s *sanctionStore) Active(_ context.Context, scope store.Scope, resourceID, userID string, now time.Time) ([]store.Sanction, error) {
+	return s.filter(now, func(sn store.Sanction) bool {
+		return sn.Scope == scope && sn.ResourceID == resourceID && sn.UserID == userID
+	}), nil
+}
+
+func (s *sanctionStore) List(_ context.Context, scope store.Scope, resourceID string, now time.Time) ([]store.Sanction, error) {
+	return s.filter(now, func(sn store.Sanction) bool {
+		return sn.Scope == scope && sn.ResourceID == resourceID
+	}), nil
+}
+
+func (s *sanctionStore) filter(now time.Time, match func(store.Sanction) bool) []store.Sanction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sanctions []store.Sanction
+	for _, sn := range s.sanctions {
+		if match(sn) && (sn.ExpiresAt == nil || sn.ExpiresAt.After(now)) {
+			sanctions = append(sanctions, sn)
+		}
+	}
+
+	sort.Slice(sanctions, func(i, j int) bool { return sanctions[i].CreatedAt.Before(sanctions[j].CreatedAt) })
+	return sanctions
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+	"time"
+)
+
+type tokenStore struct{ *db }
+
+func (s *tokenStore) CreateRefresh(_ context.Context, t store.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.refresh[t.Hash]; ok {
+		return store.ErrConflict
+	}
+	s.refresh[t.Hash] = t
+	return nil
+}
+
+func (s *tokenStore) GetRefresh(_ context.Context, hash string) (store.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.refresh[hash]
+	if !ok {
+		return store.RefreshToken{}, store.ErrNotFound
+	}
+	return t, nil
+}
+
+func (s *tokenStore) RevokeRefresh(_ context.Context, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refresh[hash]
+	if !ok || t.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.RevokedAt = &now
+	s.refresh[hash] = t
+	return true, nil
+}
+
+func (s *tokenStore) RevokeFamily(_ context.Context, familyID string) error {
+	s.revokeWhere(func(t store.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (s *tokenStore) RevokeAllRefresh(_ context.Context, userID string) error {
+	s.revokeWhere(func(t store.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func (s *tokenStore) revokeWhere(match func(store.RefreshToken) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for hash, t := range s.refresh {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &now
+			s.refresh[hash] = t
+		}
+	}
+}
+
+func (s *tokenStore) RevokeAccess(_ context.Context, jti, _ string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *tokenStore) IsAccessRevoked(_ context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *tokenStore) DeleteExpired(_ context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for jti, expiresAt := range s.revoked {
+		if expiresAt.Before(now) {
+			delete(s.revoked, jti)
+		}
+	}
+	for hash, t := range s.refresh {
+		if t.ExpiresAt.Before(now) {
+			delete(s.refresh, hash)
+		}
+	}
+	return nil
+}
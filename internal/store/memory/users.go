@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"server/internal/store"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type userStore struct{ *db }
+
+func (s *userStore) Create(_ context.Context, name, passwordHash string) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Name == name {
+			return store.User{}, store.ErrConflict
+		}
+	}
+
+	s.nextUserID++
+	user := store.User{ID: strconv.Itoa(s.nextUserID), Name: name, PasswordHash: passwordHash}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *userStore) GetByID(_ context.Context, id string) (store.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.User{}, store.ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *userStore) GetByName(_ context.Context, name string) (store.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Name == name {
+			return u, nil
+		}
+	}
+	return store.User{}, store.ErrNotFound
+}
+
+func (s *userStore) ListOthers(_ context.Context, viewerID string) ([]store.UserWithStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var users []store.UserWithStatus
+	for id, u := range s.users {
+		if id == viewerID {
+			continue
+		}
+
+		item := store.UserWithStatus{User: store.User{ID: u.ID, Name: u.Name}}
+		if f, ok := s.friendships[[2]string{viewerID, id}]; ok {
+			status := string(f.Status)
+			item.FriendStatus = &status
+		} else if f, ok := s.friendships[[2]string{id, viewerID}]; ok {
+			status := string(f.Status)
+			item.FriendStatus = &status
+		}
+		users = append(users, item)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return idLess(users[j].ID, users[i].ID) })
+	return users, nil
+}
+
+func (s *userStore) SetLastSeen(_ context.Context, userID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return store.ErrNotFound
+	}
+	p := s.presence[userID]
+	p.LastSeenAt = &at
+	s.presence[userID] = p
+	return nil
+}
+
+func (s *userStore) SetPresenceStatus(_ context.Context, userID string, status store.PresenceStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return store.ErrNotFound
+	}
+	p := s.presence[userID]
+	p.Status = status
+	s.presence[userID] = p
+	return nil
+}
+
+func (s *userStore) ListPresence(_ context.Context, ids []string) ([]store.UserPresence, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []store.UserPresence
+	for _, id := range ids {
+		if _, ok := s.users[id]; !ok {
+			continue
+		}
+		p := s.presence[id]
+		p.UserID = id
+		result = append(result, p)
+	}
+	return result, nil
+}
@@ -0,0 +1,367 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"server/internal/store"
+	"time"
+)
+
+type chatStore struct {
+	db *sql.DB
+}
+
+func (s *chatStore) Get(ctx context.Context, chatID string) (store.Chat, error) {
+	var chat store.Chat
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, type, name FROM chats WHERE id = $1`,
+		chatID,
+	).Scan(&chat.ID, &chat.Type, &chat.Name)
+	return chat, notFound(err)
+}
+
+func (s *chatStore) Create(ctx context.Context, chat store.Chat, ownerID string, memberIDs ...string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertChat(ctx, tx, chat, ownerID, memberIDs...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *chatStore) CreatePrivate(ctx context.Context, chat store.Chat, userID, friendID string) (store.Chat, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return store.Chat{}, false, err
+	}
+	defer tx.Rollback()
+
+	// Строка дружбы блокируется до конца транзакции: параллельный запрос
+	// для той же пары ждет здесь и затем находит уже созданный чат
+	var friends bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM friendship
+			WHERE ((user_id = $1 AND friend_id = $2)
+			   OR (user_id = $2 AND friend_id = $1))
+			AND status = 'accepted'
+			FOR UPDATE
+		)
+	`, userID, friendID).Scan(&friends)
+	if err != nil {
+		return store.Chat{}, false, err
+	}
+	if !friends {
+		return store.Chat{}, false, store.ErrNotFound
+	}
+
+	var existing store.Chat
+	err = tx.QueryRowContext(ctx, `
+		SELECT c.id, c.type, c.name FROM chats c
+		JOIN chat_participants cp1 ON c.id = cp1.chat_id
+		JOIN chat_participants cp2 ON c.id = cp2.chat_id
+		WHERE c.type = 'private'
+		AND cp1.user_id = $1
+		AND cp2.user_id = $2
+		AND cp1.user_id != cp2.user_id
+		LIMIT 1
+	`, userID, friendID).Scan(&existing.ID, &existing.Type, &existing.Name)
+	if err == nil {
+		return existing, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return store.Chat{}, false, err
+	}
+
+	chat.Type = store.ChatTypePrivate
+	if err := insertChat(ctx, tx, chat, "", userID, friendID); err != nil {
+		return store.Chat{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return store.Chat{}, false, err
+	}
+	return chat, true, nil
+}
+
+// insertChat создает чат с владельцем и участниками в транзакции tx
+func insertChat(ctx context.Context, tx *sql.Tx, chat store.Chat, ownerID string, memberIDs ...string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO chats (id, type, name)
+		VALUES ($1, $2, $3)
+	`, chat.ID, chat.Type, chat.Name)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return store.ErrConflict
+		}
+		return err
+	}
+
+	if ownerID != "" {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO chat_participants (chat_id, user_id, role, joined_at)
+			VALUES ($1, $2, $3, NOW())
+		`, chat.ID, ownerID, store.RoleOwner)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, userID := range memberIDs {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO chat_participants (chat_id, user_id, role, joined_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (chat_id, user_id) DO NOTHING
+		`, chat.ID, userID, store.RoleMember)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *chatStore) AddParticipant(ctx context.Context, chatID, userID string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO chat_participants (chat_id, user_id, joined_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (chat_id, user_id) DO NOTHING
+	`, chatID, userID)
+	return err
+}
+
+func (s *chatStore) IsParticipant(ctx context.Context, chatID, userID string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM chat_participants
+			WHERE chat_id = $1 AND user_id = $2
+		)
+	`, chatID, userID).Scan(&exists)
+	return exists, err
+}
+
+func (s *chatStore) SharingChats(ctx context.Context, userID string, others []string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT other.user_id
+		FROM chat_participants me
+		JOIN chat_participants other ON other.chat_id = me.chat_id
+		WHERE me.user_id = $1 AND other.user_id = ANY($2)
+	`, userID, pq.Array(others))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shared []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		shared = append(shared, id)
+	}
+
+	return shared, rows.Err()
+}
+
+func (s *chatStore) MarkRead(ctx context.Context, chatID, userID string, messageID int) (int, bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	// Курсор не уходит назад и не заходит дальше последнего сообщения чата
+	var cursor, previous int
+	err = tx.QueryRowContext(ctx, `
+		WITH old AS (
+			SELECT last_read_message_id FROM chat_participants
+			WHERE chat_id = $1 AND user_id = $2
+			FOR UPDATE
+		)
+		UPDATE chat_participants cp
+		SET last_read_message_id = GREATEST(old.last_read_message_id,
+			LEAST($3, (SELECT COALESCE(MAX(id), 0) FROM messages WHERE chat_id = $1)))
+		FROM old
+		WHERE cp.chat_id = $1 AND cp.user_id = $2
+		RETURNING cp.last_read_message_id, old.last_read_message_id
+	`, chatID, userID, messageID).Scan(&cursor, &previous)
+	if err != nil {
+		return 0, false, notFound(err)
+	}
+	if cursor == previous {
+		return cursor, false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE messages SET is_read = TRUE
+		WHERE chat_id = $1 AND id > $3 AND id <= $4 AND sender_id <> $2 AND NOT is_read
+	`, chatID, userID, previous, cursor)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return cursor, true, tx.Commit()
+}
+
+func (s *chatStore) ReadCursors(ctx context.Context, chatID string) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, last_read_message_id FROM chat_participants WHERE chat_id = $1
+	`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cursors := make(map[string]int)
+	for rows.Next() {
+		var userID string
+		var cursor int
+		if err := rows.Scan(&userID, &cursor); err != nil {
+			return nil, err
+		}
+		cursors[userID] = cursor
+	}
+
+	return cursors, rows.Err()
+}
+
+func (s *chatStore) UnreadCount(ctx context.Context, chatID, userID string) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(m.id)
+		FROM chat_participants cp
+		LEFT JOIN messages m ON m.chat_id = cp.chat_id
+			AND m.id > cp.last_read_message_id
+			AND m.sender_id <> cp.user_id
+			AND m.deleted_at IS NULL
+		WHERE cp.chat_id = $1 AND cp.user_id = $2
+		GROUP BY cp.chat_id
+	`, chatID, userID).Scan(&n)
+	return n, notFound(err)
+}
+
+func (s *chatStore) UnreadCounts(ctx context.Context, userID string) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT cp.chat_id, COUNT(m.id)
+		FROM chat_participants cp
+		LEFT JOIN messages m ON m.chat_id = cp.chat_id
+			AND m.id > cp.last_read_message_id
+			AND m.sender_id <> cp.user_id
+			AND m.deleted_at IS NULL
+		WHERE cp.user_id = $1
+		GROUP BY cp.chat_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var chatID string
+		var n int
+		if err := rows.Scan(&chatID, &n); err != nil {
+			return nil, err
+		}
+		counts[chatID] = n
+	}
+
+	return counts, rows.Err()
+}
+
+func (s *chatStore) ListForUser(ctx context.Context, userID string, limit, offset int) ([]store.ChatSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.id, c.type, c.name,
+			COALESCE(lm.created_at, cp.joined_at) AS activity,
+			(SELECT COUNT(*) FROM messages m
+				WHERE m.chat_id = c.id AND m.id > cp.last_read_message_id
+				AND m.sender_id <> cp.user_id AND m.deleted_at IS NULL),
+			lm.id, lm.sender_id, lm.message_text, lm."message_type ", lm.is_read,
+			lm.created_at, lm.edited_at, lm.deleted_at
+		FROM chat_participants cp
+		JOIN chats c ON c.id = cp.chat_id
+		LEFT JOIN LATERAL (
+			SELECT * FROM messages WHERE chat_id = c.id ORDER BY id DESC LIMIT 1
+		) lm ON TRUE
+		WHERE cp.user_id = $1
+		ORDER BY activity DESC, c.id
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []store.ChatSummary
+	index := make(map[string]int)
+	for rows.Next() {
+		var c store.ChatSummary
+		var (
+			id                      sql.NullInt64
+			senderID, text, msgType sql.NullString
+			isRead                  sql.NullBool
+			createdAt               sql.NullTime
+			editedAt, deletedAt     *time.Time
+		)
+		err := rows.Scan(&c.ID, &c.Type, &c.Name, &c.LastActivityAt, &c.Unread,
+			&id, &senderID, &text, &msgType, &isRead, &createdAt, &editedAt, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		if id.Valid {
+			c.LastMessage = &store.Message{
+				ID:          int(id.Int64),
+				ChatID:      c.ID,
+				SenderID:    senderID.String,
+				Text:        text.String,
+				MessageType: msgType.String,
+				IsRead:      isRead.Bool,
+				CreatedAt:   createdAt.Time,
+				EditedAt:    editedAt,
+				DeletedAt:   deletedAt,
+			}
+		}
+		index[c.ID] = len(chats)
+		chats = append(chats, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(chats) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(chats))
+	for _, c := range chats {
+		ids = append(ids, c.ID)
+	}
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT cp.chat_id, cp.user_id, COALESCE(u.name, '')
+		FROM chat_participants cp
+		LEFT JOIN users u ON u.id::text = cp.user_id
+		WHERE cp.chat_id = ANY($1)
+		ORDER BY cp.joined_at, cp.user_id
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chatID string
+		var p store.ChatParticipant
+		if err := rows.Scan(&chatID, &p.UserID, &p.Name); err != nil {
+			return nil, err
+		}
+		i := index[chatID]
+		chats[i].Participants = append(chats[i].Participants, p)
+	}
+
+	return chats, rows.Err()
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"server/internal/store"
+)
+
+type friendshipStore struct {
+	db *sql.DB
+}
+
+func (s *friendshipStore) Get(ctx context.Context, userID, friendID string) (store.Friendship, error) {
+	f := store.Friendship{UserID: userID, FriendID: friendID}
+	err := s.db.QueryRowContext(ctx, `
+    SELECT status FROM friendship 
+    WHERE user_id = $1 AND friend_id = $2
+`, userID, friendID).Scan(&f.Status)
+	return f, notFound(err)
+}
+
+func (s *friendshipStore) Create(ctx context.Context, f store.Friendship) (store.Friendship, error) {
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO friendship (user_id, friend_id, status) VALUES ($1, $2, $3) RETURNING status`,
+		f.UserID, f.FriendID, f.Status,
+	).Scan(&f.Status)
+	if isUniqueViolation(err) {
+		return store.Friendship{}, store.ErrConflict
+	}
+	return f, err
+}
+
+func (s *friendshipStore) UpdateStatus(ctx context.Context, userID, friendID string, status store.FriendStatus) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE friendship SET status = $1 WHERE user_id = $2 AND friend_id = $3`,
+		status, userID, friendID,
+	)
+	return affected(result, err)
+}
+
+func (s *friendshipStore) ListNames(ctx context.Context, userID string, status store.FriendStatus) ([]store.FriendName, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT u.name, f.status FROM friendship f JOIN users u ON f.friend_id::text = u.id::text WHERE f.user_id = $1 AND f.status = $2`,
+		userID, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var friends []store.FriendName
+	for rows.Next() {
+		var friend store.FriendName
+		if err := rows.Scan(&friend.Name, &friend.Status); err != nil {
+			return nil, err
+		}
+		friends = append(friends, friend)
+	}
+
+	return friends, rows.Err()
+}
+
+func (s *friendshipStore) ListAccepted(ctx context.Context, userID string) ([]store.User, error) {
+	rows, err := s.db.QueryContext(ctx, `
+   SELECT friends.id::text, friends.name
+   FROM users friends
+   WHERE EXISTS (
+     SELECT 1 FROM friendship f
+     WHERE ((f.user_id = $1 AND f.friend_id = friends.id::text) OR 
+            (f.friend_id = $1 AND f.user_id = friends.id::text))
+       AND f.status = 'accepted'
+   )`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var friends []store.User
+	for rows.Next() {
+		var friend store.User
+		if err := rows.Scan(&friend.ID, &friend.Name); err != nil {
+			return nil, err
+		}
+		friends = append(friends, friend)
+	}
+
+	return friends, rows.Err()
+}
+
+func (s *friendshipStore) AreFriends(ctx context.Context, userID, friendID string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM friendship 
+			WHERE ((user_id = $1 AND friend_id = $2) 
+			   OR (user_id = $2 AND friend_id = $1))
+			AND status = 'accepted'
+		)
+	`, userID, friendID).Scan(&exists)
+	return exists, err
+}
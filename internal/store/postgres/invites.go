@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"server/internal/store"
+	"time"
+)
+
+const inviteColumns = "code, room_id, created_by, max_uses, uses, expires_at, created_at, revoked_at"
+
+type inviteStore struct {
+	db *sql.DB
+}
+
+func scanInvite(row rowScanner) (store.RoomInvite, error) {
+	var inv store.RoomInvite
+	err := row.Scan(&inv.Code, &inv.RoomID, &inv.CreatedBy, &inv.MaxUses, &inv.Uses,
+		&inv.ExpiresAt, &inv.CreatedAt, &inv.RevokedAt)
+	return inv, err
+}
+
+func (s *inviteStore) Create(ctx context.Context, inv store.RoomInvite) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO room_invites (code, room_id, created_by, max_uses, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, inv.Code, inv.RoomID, inv.CreatedBy, inv.MaxUses, inv.ExpiresAt)
+	if isUniqueViolation(err) {
+		return store.ErrConflict
+	}
+	return err
+}
+
+func (s *inviteStore) Get(ctx context.Context, code string) (store.RoomInvite, error) {
+	inv, err := scanInvite(s.db.QueryRowContext(ctx,
+		"SELECT "+inviteColumns+" FROM room_invites WHERE code = $1", code))
+	return inv, notFound(err)
+}
+
+func (s *inviteStore) ListByRoom(ctx context.Context, roomID string) ([]store.RoomInvite, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+inviteColumns+" FROM room_invites WHERE room_id = $1 ORDER BY created_at DESC", roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []store.RoomInvite
+	for rows.Next() {
+		inv, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, inv)
+	}
+
+	return invites, rows.Err()
+}
+
+func (s *inviteStore) Redeem(ctx context.Context, code string, now time.Time) (store.RoomInvite, error) {
+	inv, err := scanInvite(s.db.QueryRowContext(ctx, `
+		UPDATE room_invites
+		SET uses = uses + 1
+		WHERE code = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > $2)
+		AND (max_uses = 0 OR uses < max_uses)
+		RETURNING `+inviteColumns,
+		code, now,
+	))
+	return inv, notFound(err)
+}
+
+func (s *inviteStore) Revoke(ctx context.Context, code string) error {
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE room_invites SET revoked_at = NOW() WHERE code = $1 AND revoked_at IS NULL", code)
+	return affected(res, err)
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"server/internal/store"
+)
+
+type memberStore struct {
+	db *sql.DB
+}
+
+// memberTable — таблица и колонка ресурса для участников заданного вида
+func memberTable(scope store.Scope) (table, column string, err error) {
+	switch scope {
+	case store.ScopeChat:
+		return "chat_participants", "chat_id", nil
+	case store.ScopeRoom:
+		return "room_members", "room_id", nil
+	default:
+		return "", "", fmt.Errorf("unknown member scope %q", scope)
+	}
+}
+
+func (s *memberStore) Get(ctx context.Context, scope store.Scope, resourceID, userID string) (store.Member, error) {
+	table, column, err := memberTable(scope)
+	if err != nil {
+		return store.Member{}, err
+	}
+
+	var m store.Member
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT user_id, role, joined_at FROM %s WHERE %s = $1 AND user_id = $2`, table, column),
+		resourceID, userID,
+	).Scan(&m.UserID, &m.Role, &m.JoinedAt)
+	return m, notFound(err)
+}
+
+func (s *memberStore) List(ctx context.Context, scope store.Scope, resourceID string) ([]store.Member, error) {
+	table, column, err := memberTable(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT user_id, role, joined_at FROM %s WHERE %s = $1 ORDER BY joined_at, user_id`, table, column),
+		resourceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []store.Member
+	for rows.Next() {
+		var m store.Member
+		if err := rows.Scan(&m.UserID, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (s *memberStore) Add(ctx context.Context, scope store.Scope, resourceID, userID string, role store.Role) error {
+	table, column, err := memberTable(scope)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (%s, user_id, role, joined_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (%s, user_id) DO NOTHING
+	`, table, column, column), resourceID, userID, role)
+	return err
+}
+
+func (s *memberStore) SetRole(ctx context.Context, scope store.Scope, resourceID, userID string, role store.Role) error {
+	table, column, err := memberTable(scope)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		`UPDATE %s SET role = $3 WHERE %s = $1 AND user_id = $2`, table, column),
+		resourceID, userID, role,
+	)
+	return affected(res, err)
+}
+
+func (s *memberStore) Remove(ctx context.Context, scope store.Scope, resourceID, userID string) error {
+	table, column, err := memberTable(scope)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		`DELETE FROM %s WHERE %s = $1 AND user_id = $2`, table, column),
+		resourceID, userID,
+	)
+	return affected(res, err)
+}
@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"server/internal/store"
+	"slices"
+	"time"
+)
+
+type messageStore struct {
+	db *sql.DB
+}
+
+func (s *messageStore) Create(ctx context.Context, msg store.Message) (store.Message, error) {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO messages (chat_id, sender_id, message_text, "message_type ", is_read)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, msg.ChatID, msg.SenderID, msg.Text, msg.MessageType, msg.IsRead).Scan(&msg.ID, &msg.CreatedAt)
+	return msg, err
+}
+
+// messageColumns — столбцы сообщения в порядке scanMessage
+const messageColumns = `id, chat_id, sender_id, message_text, "message_type ", is_read, created_at, edited_at, deleted_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row scanner) (store.Message, error) {
+	var msg store.Message
+	err := row.Scan(&msg.ID, &msg.ChatID, &msg.SenderID, &msg.Text, &msg.MessageType, &msg.IsRead,
+		&msg.CreatedAt, &msg.EditedAt, &msg.DeletedAt)
+	return msg, err
+}
+
+func (s *messageStore) List(ctx context.Context, chatID string, q store.MessageQuery) ([]store.Message, error) {
+	// Окно выбирается по индексу (chat_id, id) от границы, без OFFSET;
+	// окно после AfterID выбирается к новым и затем разворачивается
+	order := "DESC"
+	if q.AfterID > 0 {
+		order = "ASC"
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE chat_id = $1 AND id > $2 AND ($3 = 0 OR id < $3)
+		ORDER BY id `+order+`
+		LIMIT $4
+	`, chatID, q.AfterID, q.BeforeID, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []store.Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if q.AfterID > 0 {
+		slices.Reverse(messages)
+	}
+	return messages, nil
+}
+
+func (s *messageStore) Count(ctx context.Context, chatID string) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE chat_id = $1`, chatID).Scan(&n)
+	return n, err
+}
+
+func (s *messageStore) Get(ctx context.Context, chatID string, id int) (store.Message, error) {
+	msg, err := scanMessage(s.db.QueryRowContext(ctx,
+		`SELECT `+messageColumns+` FROM messages WHERE chat_id = $1 AND id = $2`,
+		chatID, id,
+	))
+	return msg, notFound(err)
+}
+
+func (s *messageStore) Edit(ctx context.Context, chatID string, id int, text, editorID string, at time.Time) (store.Message, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return store.Message{}, err
+	}
+	defer tx.Rollback()
+
+	// Строка блокируется до конца правки: параллельное удаление дождется
+	// ее и сотрет и новый текст, и только что записанную правку
+	var previous string
+	err = tx.QueryRowContext(ctx, `
+		SELECT message_text FROM messages
+		WHERE chat_id = $1 AND id = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, chatID, id).Scan(&previous)
+	if err != nil {
+		return store.Message{}, notFound(err)
+	}
+
+	// Прежний текст уходит в историю в той же транзакции, что и правка
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO message_revisions (message_id, message_text, edited_by, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, id, previous, editorID, at)
+	if err != nil {
+		return store.Message{}, err
+	}
+
+	msg, err := scanMessage(tx.QueryRowContext(ctx, `
+		UPDATE messages SET message_text = $3, edited_at = $4
+		WHERE chat_id = $1 AND id = $2 AND deleted_at IS NULL
+		RETURNING `+messageColumns,
+		chatID, id, text, at,
+	))
+	if err != nil {
+		return store.Message{}, notFound(err)
+	}
+
+	return msg, tx.Commit()
+}
+
+func (s *messageStore) Delete(ctx context.Context, chatID string, id int, at time.Time) (store.Message, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return store.Message{}, err
+	}
+	defer tx.Rollback()
+
+	msg, err := scanMessage(tx.QueryRowContext(ctx, `
+		UPDATE messages SET message_text = '', deleted_at = $3
+		WHERE chat_id = $1 AND id = $2 AND deleted_at IS NULL
+		RETURNING `+messageColumns,
+		chatID, id, at,
+	))
+	if err != nil {
+		return store.Message{}, notFound(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM message_revisions WHERE message_id = $1", id); err != nil {
+		return store.Message{}, err
+	}
+
+	return msg, tx.Commit()
+}
+
+func (s *messageStore) Revisions(ctx context.Context, chatID string, id int) ([]store.MessageRevision, error) {
+	if _, err := s.Get(ctx, chatID, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, message_id, message_text, edited_by, created_at
+		FROM message_revisions
+		WHERE message_id = $1
+		ORDER BY id ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []store.MessageRevision{}
+	for rows.Next() {
+		var rev store.MessageRevision
+		if err := rows.Scan(&rev.ID, &rev.MessageID, &rev.Text, &rev.EditedBy, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"server/internal/store"
+)
+
+// New собирает реализацию всех хранилищ поверх Postgres
+func New(db *sql.DB) *store.Store {
+	return &store.Store{
+		Users:       &userStore{db: db},
+		Friendships: &friendshipStore{db: db},
+		Chats:       &chatStore{db: db},
+		Messages:    &messageStore{db: db},
+		Rooms:       &roomStore{db: db},
+		Invites:     &inviteStore{db: db},
+		Members:     &memberStore{db: db},
+		Sanctions:   &sanctionStore{db: db},
+		Tokens:      &tokenStore{db: db},
+	}
+}
+
+// notFound превращает sql.ErrNoRows в store.ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// affected возвращает ErrNotFound, если запрос не затронул ни одной строки
+func affected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// isUniqueViolation — нарушение уникального индекса (код 23505)
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"server/internal/store"
+)
+
+// roomColumns — колонки, которые читает scanRoom, в том же порядке
+const roomColumns = "id, name, created_by, visibility, max_participants, lobby, media_mode, archived_at"
+
+type roomStore struct {
+	db *sql.DB
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRoom(row rowScanner) (store.Room, error) {
+	var room store.Room
+	err := row.Scan(&room.ID, &room.Name, &room.CreatedBy, &room.Visibility,
+		&room.MaxParticipants, &room.Lobby, &room.MediaMode, &room.ArchivedAt)
+	return room, err
+}
+
+func (s *roomStore) Create(ctx context.Context, room store.Room) (store.Room, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return store.Room{}, err
+	}
+	defer tx.Rollback()
+
+	created, err := scanRoom(tx.QueryRowContext(ctx,
+		`INSERT INTO rooms (name, created_by, visibility, max_participants, lobby, media_mode)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+roomColumns,
+		room.Name, room.CreatedBy, room.Visibility, room.MaxParticipants, room.Lobby, room.MediaMode,
+	))
+	if err != nil {
+		return store.Room{}, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO room_members (room_id, user_id, role) VALUES ($1, $2, $3)",
+		created.ID, created.CreatedBy, store.RoleOwner,
+	)
+	if err != nil {
+		return store.Room{}, err
+	}
+
+	return created, tx.Commit()
+}
+
+func (s *roomStore) Get(ctx context.Context, roomID string) (store.Room, error) {
+	room, err := scanRoom(s.db.QueryRowContext(ctx,
+		"SELECT "+roomColumns+" FROM rooms WHERE id::text = $1",
+		roomID,
+	))
+	return room, notFound(err)
+}
+
+func (s *roomStore) ListByMember(ctx context.Context, userID string) ([]store.Room, error) {
+	return s.list(ctx, `
+		SELECT r.id, r.name, r.created_by, r.visibility, r.max_participants, r.lobby, r.media_mode, r.archived_at
+		FROM rooms r
+		JOIN room_members m ON m.room_id = r.id::text
+		WHERE m.user_id = $1
+		ORDER BY r.id DESC
+	`, userID)
+}
+
+func (s *roomStore) ListJoinable(ctx context.Context, userID string, limit, offset int) ([]store.Room, error) {
+	return s.list(ctx, `
+		SELECT r.id, r.name, r.created_by, r.visibility, r.max_participants, r.lobby, r.media_mode, r.archived_at
+		FROM rooms r
+		WHERE r.visibility = $1
+		AND r.archived_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM room_members m
+			WHERE m.room_id = r.id::text AND m.user_id = $2
+		)
+		ORDER BY r.id DESC
+		LIMIT $3 OFFSET $4
+	`, store.RoomPublic, userID, limit, offset)
+}
+
+func (s *roomStore) Update(ctx context.Context, roomID string, upd store.RoomUpdate) (store.Room, error) {
+	// archived_at меняется только при смене состояния, чтобы сохранить время архивации
+	room, err := scanRoom(s.db.QueryRowContext(ctx, `
+		UPDATE rooms SET
+			name = COALESCE($2, name),
+			visibility = COALESCE($3, visibility),
+			max_participants = COALESCE($4, max_participants),
+			lobby = COALESCE($5, lobby),
+			media_mode = COALESCE($6, media_mode),
+			archived_at = CASE
+				WHEN $7::boolean IS NULL THEN archived_at
+				WHEN $7::boolean THEN COALESCE(archived_at, NOW())
+				ELSE NULL
+			END
+		WHERE id::text = $1
+		RETURNING `+roomColumns,
+		roomID, upd.Name, upd.Visibility, upd.MaxParticipants, upd.Lobby, upd.MediaMode, upd.Archived,
+	))
+	return room, notFound(err)
+}
+
+func (s *roomStore) Delete(ctx context.Context, roomID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM rooms WHERE id::text = $1", roomID)
+	if err := affected(res, err); err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM room_members WHERE room_id = $1",
+		"DELETE FROM room_invites WHERE room_id = $1",
+		"DELETE FROM sanctions WHERE scope = 'room' AND resource_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, roomID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *roomStore) list(ctx context.Context, query string, args ...interface{}) ([]store.Room, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []store.Room
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+
+	return rooms, rows.Err()
+}
+
+func (s *roomStore) AppendMessage(ctx context.Context, roomID string, msg store.RoomMessage) ([]store.RoomMessage, error) {
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Дописываем в JSON-массив одним UPDATE, чтобы параллельные сообщения не терялись
+	var messagesJSON []byte
+	err = s.db.QueryRowContext(ctx, `
+		UPDATE rooms
+		SET chat_messages = (COALESCE(chat_messages::jsonb, '[]'::jsonb) || jsonb_build_array($1::jsonb))::json
+		WHERE id::text = $2
+		RETURNING chat_messages
+	`, string(msgJSON), roomID).Scan(&messagesJSON)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	var messages []store.RoomMessage
+	if err := json.Unmarshal(messagesJSON, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"server/internal/store"
+	"time"
+)
+
+type sanctionStore struct {
+	db *sql.DB
+}
+
+func (s *sanctionStore) Put(ctx context.Context, sn store.Sanction) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sanctions (scope, resource_id, user_id, kind, issued_by, reason, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (scope, resource_id, user_id, kind) DO UPDATE
+		SET issued_by = EXCLUDED.issued_by,
+			reason = EXCLUDED.reason,
+			expires_at = EXCLUDED.expires_at,
+			created_at = EXCLUDED.created_at
+	`, sn.Scope, sn.ResourceID, sn.UserID, sn.Kind, sn.IssuedBy, sn.Reason, sn.ExpiresAt)
+	return err
+}
+
+func (s *sanctionStore) Lift(ctx context.Context, scope store.Scope, resourceID, userID string, kind store.SanctionKind) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM sanctions
+		WHERE scope = $1 AND resource_id = $2 AND user_id = $3 AND kind = $4
+	`, scope, resourceID, userID, kind)
+	return affected(res, err)
+}
+
+func (s *sanctionStore) Active(ctx context.Context, scope store.Scope, resourceID, userID string, now time.Time) ([]store.Sanction, error) {
+	return s.query(ctx, `
+		SELECT scope, resource_id, user_id, kind, issued_by, reason, expires_at, created_at
+		FROM sanctions
+		WHERE scope = $1 AND resource_id = $2 AND user_id = $3
+		AND (expires_at IS NULL OR expires_at > $4)
+	`, scope, resourceID, userID, now)
+}
+
+func (s *sanctionStore) List(ctx context.Context, scope store.Scope, resourceID string, now time.Time) ([]store.Sanction, error) {
+	return s.query(ctx, `
+		SELECT scope, resource_id, user_id, kind, issued_by, reason, expires_at, created_at
+		FROM sanctions
+		WHERE scope = $1 AND resource_id = $2
+		AND (expires_at IS NULL OR expires_at > $3)
+		ORDER BY created_at
+	`, scope, resourceID, now)
+}
+
+func (s *sanctionStore) query(ctx context.Context, query string, args ...interface{}) ([]store.Sanction, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sanctions []store.Sanction
+	for rows.Next() {
+		var sn store.Sanction
+		err := rows.Scan(&sn.Scope, &sn.ResourceID, &sn.UserID, &sn.Kind,
+			&sn.IssuedBy, &sn.Reason, &sn.ExpiresAt, &sn.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, sn)
+	}
+
+	return sanctions, rows.Err()
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"server/internal/store"
+	"time"
+)
+
+type tokenStore struct {
+	db *sql.DB
+}
+
+func (s *tokenStore) CreateRefresh(ctx context.Context, t store.RefreshToken) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, user_id, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, t.Hash, t.UserID, t.FamilyID, t.ExpiresAt)
+	return err
+}
+
+func (s *tokenStore) GetRefresh(ctx context.Context, hash string) (store.RefreshToken, error) {
+	t := store.RefreshToken{Hash: hash}
+	var revokedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, family_id, expires_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1
+	`, hash).Scan(&t.UserID, &t.FamilyID, &t.ExpiresAt, &revokedAt)
+	if err != nil {
+		return store.RefreshToken{}, notFound(err)
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return t, nil
+}
+
+func (s *tokenStore) RevokeRefresh(ctx context.Context, hash string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL
+	`, hash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+func (s *tokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	return err
+}
+
+func (s *tokenStore) RevokeAllRefresh(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return err
+}
+
+func (s *tokenStore) RevokeAccess(ctx context.Context, jti, userID string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, userID, expiresAt)
+	return err
+}
+
+func (s *tokenStore) IsAccessRevoked(ctx context.Context, jti string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)`,
+		jti,
+	).Scan(&exists)
+	return exists, err
+}
+
+func (s *tokenStore) DeleteExpired(ctx context.Context, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, now); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1`, now)
+	return err
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"server/internal/store"
+	"time"
+)
+
+type userStore struct {
+	db *sql.DB
+}
+
+func (s *userStore) Create(ctx context.Context, name, passwordHash string) (store.User, error) {
+	user := store.User{PasswordHash: passwordHash}
+	err := s.db.QueryRowContext(ctx,
+		"INSERT INTO users (name, password) VALUES ($1, $2) RETURNING id, name",
+		name, passwordHash,
+	).Scan(&user.ID, &user.Name)
+	if isUniqueViolation(err) {
+		return store.User{}, store.ErrConflict
+	}
+	return user, err
+}
+
+func (s *userStore) GetByID(ctx context.Context, id string) (store.User, error) {
+	var user store.User
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, name, password FROM users WHERE id = $1",
+		id,
+	).Scan(&user.ID, &user.Name, &user.PasswordHash)
+	return user, notFound(err)
+}
+
+func (s *userStore) GetByName(ctx context.Context, name string) (store.User, error) {
+	var user store.User
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, name, password FROM users WHERE name = $1",
+		name,
+	).Scan(&user.ID, &user.Name, &user.PasswordHash)
+	return user, notFound(err)
+}
+
+func (s *userStore) ListOthers(ctx context.Context, viewerID string) ([]store.UserWithStatus, error) {
+	query := `
+    SELECT u.id, u.name, 
+           (
+               SELECT f.status 
+               FROM friendship f 
+               WHERE ((f.user_id = $1 AND f.friend_id = u.id::text) OR 
+                      (f.user_id = u.id::text AND f.friend_id = $2) AND (f.status = 'accepted' OR f.status = 'pending'))
+               LIMIT 1
+           ) as is_friend
+    FROM users u
+    WHERE u.id != $3::integer
+    ORDER BY u.id DESC
+`
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, viewerID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []store.UserWithStatus
+	for rows.Next() {
+		var user store.UserWithStatus
+		if err := rows.Scan(&user.ID, &user.Name, &user.FriendStatus); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *userStore) SetLastSeen(ctx context.Context, userID string, at time.Time) error {
+	return affected(s.db.ExecContext(ctx,
+		"UPDATE users SET last_seen_at = $2 WHERE id = $1",
+		userID, at,
+	))
+}
+
+func (s *userStore) SetPresenceStatus(ctx context.Context, userID string, status store.PresenceStatus) error {
+	return affected(s.db.ExecContext(ctx,
+		"UPDATE users SET presence_status = NULLIF($2, '') WHERE id = $1",
+		userID, string(status),
+	))
+}
+
+func (s *userStore) ListPresence(ctx context.Context, ids []string) ([]store.UserPresence, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id::text, COALESCE(presence_status, ''), last_seen_at FROM users WHERE id::text = ANY($1)",
+		pq.Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []store.UserPresence
+	for rows.Next() {
+		var p store.UserPresence
+		if err := rows.Scan(&p.UserID, &p.Status, &p.LastSeenAt); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,403 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("store: not found")
+	ErrConflict = errors.New("store: already exists")
+)
+
+type FriendStatus string
+
+const (
+	FriendStatusPending  FriendStatus = "pending"
+	FriendStatusAccepted FriendStatus = "accepted"
+)
+
+type ChatType string
+
+const (
+	ChatTypePrivate ChatType = "private"
+	ChatTypeGroup   ChatType = "group"
+)
+
+// Role — роль участника комнаты или чата
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleModerator Role = "moderator"
+	RoleMember    Role = "member"
+	RoleGuest     Role = "guest"
+)
+
+// Scope — вид ресурса, к которому относятся участие и санкции
+type Scope string
+
+const (
+	ScopeRoom Scope = "room"
+	ScopeChat Scope = "chat"
+)
+
+// RoomVisibility — кто видит комнату в списке и может в нее войти
+type RoomVisibility string
+
+const (
+	// RoomPublic — в списке, войти может любой
+	RoomPublic RoomVisibility = "public"
+	// RoomInviteOnly — в списке, вход по приглашению
+	RoomInviteOnly RoomVisibility = "invite_only"
+	// RoomPrivate — не в списке, вход по приглашению
+	RoomPrivate RoomVisibility = "private"
+)
+
+// MediaMode — как участники комнаты обмениваются медиа
+type MediaMode string
+
+const (
+	// MediaMesh — клиенты соединяются друг с другом напрямую
+	MediaMesh MediaMode = "mesh"
+	// MediaSFU — каждый клиент соединяется с сервером, сервер пересылает потоки
+	MediaSFU MediaMode = "sfu"
+)
+
+type SanctionKind string
+
+const (
+	SanctionMute SanctionKind = "mute"
+	SanctionBan  SanctionKind = "ban"
+)
+
+type User struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	PasswordHash string `json:"-"`
+}
+
+// PresenceStatus — статус присутствия пользователя
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceAway    PresenceStatus = "away"
+	PresenceDND     PresenceStatus = "dnd"
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// UserPresence — сохраненная часть присутствия: ручной статус (пустой —
+// не задан) и время, когда закрылось последнее подключение пользователя
+type UserPresence struct {
+	UserID     string
+	Status     PresenceStatus
+	LastSeenAt *time.Time
+}
+
+// UserWithStatus — пользователь в списке вместе со статусом дружбы относительно того, кто смотрит
+type UserWithStatus struct {
+	User
+	FriendStatus *string `json:"is_friend,omitempty"`
+}
+
+type Friendship struct {
+	UserID   string       `json:"user_id"`
+	FriendID string       `json:"friend_id"`
+	Status   FriendStatus `json:"status"`
+}
+
+// FriendName — друг в списке GetFriends
+type FriendName struct {
+	Name   string       `json:"name"`
+	Status FriendStatus `json:"status"`
+}
+
+type Chat struct {
+	ID   string   `json:"id"`
+	Type ChatType `json:"type"`
+	Name string   `json:"name"`
+}
+
+// ChatParticipant — участник в списке чатов
+type ChatParticipant struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// ChatSummary — чат в списке чатов пользователя
+type ChatSummary struct {
+	Chat
+	Participants []ChatParticipant `json:"participants"`
+	// LastMessage — последнее сообщение чата, удаленное — без текста; nil — сообщений нет
+	LastMessage *Message `json:"last_message,omitempty"`
+	Unread      int      `json:"unread"`
+	// LastActivityAt — время последнего сообщения, а в пустом чате — вступления пользователя
+	LastActivityAt time.Time `json:"last_activity_at"`
+}
+
+// MessageTypeText — тип обычного текстового сообщения чата
+const MessageTypeText = "text"
+
+type Message struct {
+	ID          int    `json:"id"`
+	ChatID      string `json:"chat_id"`
+	SenderID    string `json:"sender_id"`
+	Text        string `json:"message_text"`
+	MessageType string `json:"message_type"`
+	// IsRead — сообщение прочитал кто-то из участников, кроме автора
+	IsRead    bool      `json:"is_read"`
+	CreatedAt time.Time `json:"created_at"`
+	// EditedAt — время последней правки
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// DeletedAt — сообщение удалено; текст стерт, остается только отметка
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// SeenBy — кто из участников, кроме автора, прочитал сообщение; заполняется
+	// только в истории групповых чатов
+	SeenBy []string `json:"seen_by,omitempty"`
+}
+
+// MessageQuery — окно истории чата из Limit сообщений. С AfterID окно
+// начинается сразу после него и идет к новым, иначе — сразу перед BeforeID
+// и идет к старым; без границ — самые новые сообщения. Границы в окно не
+// входят и, заданные вместе, обе его ограничивают.
+type MessageQuery struct {
+	BeforeID int
+	AfterID  int
+	Limit    int
+}
+
+// MessageRevision — прежний текст сообщения, замененный правкой
+type MessageRevision struct {
+	ID        int       `json:"id"`
+	MessageID int       `json:"message_id"`
+	Text      string    `json:"message_text"`
+	EditedBy  string    `json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Room struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	CreatedBy  string         `json:"created_by"`
+	Visibility RoomVisibility `json:"visibility"`
+	// MaxParticipants — лимит участников сессии; 0 — общий лимит сервера
+	MaxParticipants int `json:"max_participants"`
+	// Lobby — новые участники ждут допуска от владельца или модератора
+	Lobby bool `json:"lobby"`
+	// MediaMode применяется к следующей сессии комнаты
+	MediaMode  MediaMode  `json:"media_mode"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// RoomUpdate — изменения комнаты; nil-поля остаются как есть
+type RoomUpdate struct {
+	Name            *string
+	Visibility      *RoomVisibility
+	MaxParticipants *int
+	Lobby           *bool
+	MediaMode       *MediaMode
+	Archived        *bool
+}
+
+// RoomInvite — ссылка-приглашение в комнату. MaxUses == 0 — без ограничения.
+type RoomInvite struct {
+	Code      string     `json:"code"`
+	RoomID    string     `json:"room_id"`
+	CreatedBy string     `json:"created_by"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RoomMessage — сообщение текстового чата внутри видеокомнаты
+type RoomMessage struct {
+	Text string `json:"text"`
+	From string `json:"from"`
+}
+
+// Member — участник комнаты или чата
+type Member struct {
+	UserID   string    `json:"user_id"`
+	Role     Role      `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Sanction — ограничение, наложенное модератором; ExpiresAt == nil — бессрочно
+type Sanction struct {
+	Scope      Scope        `json:"scope"`
+	ResourceID string       `json:"resource_id"`
+	UserID     string       `json:"user_id"`
+	Kind       SanctionKind `json:"kind"`
+	IssuedBy   string       `json:"issued_by"`
+	Reason     string       `json:"reason,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// RefreshToken — серверная запись refresh-токена; сам токен не хранится, только хэш
+type RefreshToken struct {
+	Hash      string
+	UserID    string
+	FamilyID  string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+type UserStore interface {
+	// Create возвращает ErrConflict, если имя уже занято
+	Create(ctx context.Context, name, passwordHash string) (User, error)
+	GetByID(ctx context.Context, id string) (User, error)
+	GetByName(ctx context.Context, name string) (User, error)
+	// ListOthers возвращает всех пользователей, кроме viewerID, со статусом дружбы
+	ListOthers(ctx context.Context, viewerID string) ([]UserWithStatus, error)
+	// SetLastSeen запоминает время последнего визита; ErrNotFound — нет пользователя
+	SetLastSeen(ctx context.Context, userID string, at time.Time) error
+	// SetPresenceStatus сохраняет ручной статус; "" — сбросить.
+	// ErrNotFound — нет пользователя.
+	SetPresenceStatus(ctx context.Context, userID string, status PresenceStatus) error
+	// ListPresence возвращает сохраненное присутствие существующих пользователей из ids
+	ListPresence(ctx context.Context, ids []string) ([]UserPresence, error)
+}
+
+type FriendshipStore interface {
+	Get(ctx context.Context, userID, friendID string) (Friendship, error)
+	Create(ctx context.Context, f Friendship) (Friendship, error)
+	// UpdateStatus возвращает ErrNotFound, если заявки нет
+	UpdateStatus(ctx context.Context, userID, friendID string, status FriendStatus) error
+	ListNames(ctx context.Context, userID string, status FriendStatus) ([]FriendName, error)
+	// ListAccepted возвращает подтвержденных друзей в обе стороны
+	ListAccepted(ctx context.Context, userID string) ([]User, error)
+	AreFriends(ctx context.Context, userID, friendID string) (bool, error)
+}
+
+type ChatStore interface {
+	Get(ctx context.Context, chatID string) (Chat, error)
+	// Create создает чат, добавляет владельца и участников одной транзакцией.
+	// ownerID == "" — чат без владельца (приватный).
+	Create(ctx context.Context, chat Chat, ownerID string, memberIDs ...string) error
+	// CreatePrivate возвращает приватный чат двух друзей, создавая chat, если
+	// такого еще нет (created). Проверка дружбы, поиск чата и добавление обоих
+	// участников выполняются атомарно. ErrNotFound — пользователи не друзья.
+	CreatePrivate(ctx context.Context, chat Chat, userID, friendID string) (result Chat, created bool, err error)
+	// AddParticipant ничего не делает, если пользователь уже участник
+	AddParticipant(ctx context.Context, chatID, userID string) error
+	IsParticipant(ctx context.Context, chatID, userID string) (bool, error)
+	// SharingChats возвращает тех из others, кто состоит хотя бы в одном чате с userID
+	SharingChats(ctx context.Context, userID string, others []string) ([]string, error)
+	// MarkRead сдвигает курсор прочтения участника вперед до messageID, но не
+	// дальше последнего сообщения чата, и отмечает прочитанными чужие сообщения
+	// до курсора. Возвращает курсор и признак, что он сдвинулся;
+	// ErrNotFound — пользователь не участник.
+	MarkRead(ctx context.Context, chatID, userID string, messageID int) (cursor int, moved bool, err error)
+	// ReadCursors — курсоры участников чата: userID -> ID последнего прочитанного сообщения
+	ReadCursors(ctx context.Context, chatID string) (map[string]int, error)
+	// UnreadCounts — сколько чужих неудаленных сообщений после курсора в каждом
+	// чате пользователя: chatID -> количество
+	UnreadCounts(ctx context.Context, userID string) (map[string]int, error)
+	// UnreadCount — то же для одного чата; ErrNotFound — пользователь не участник
+	UnreadCount(ctx context.Context, chatID, userID string) (int, error)
+	// ListForUser возвращает чаты пользователя от недавно активных к давним
+	ListForUser(ctx context.Context, userID string, limit, offset int) ([]ChatSummary, error)
+}
+
+type MessageStore interface {
+	Create(ctx context.Context, msg Message) (Message, error)
+	// List возвращает окно истории от новых к старым, включая удаленные
+	// сообщения — без текста, с DeletedAt
+	List(ctx context.Context, chatID string, q MessageQuery) ([]Message, error)
+	// Count — число сообщений в истории чата, включая удаленные
+	Count(ctx context.Context, chatID string) (int, error)
+	// Get возвращает ErrNotFound, если в чате нет такого сообщения
+	Get(ctx context.Context, chatID string, id int) (Message, error)
+	// Edit заменяет текст, сохраняя прежний в истории правок.
+	// ErrNotFound — нет сообщения или оно удалено.
+	Edit(ctx context.Context, chatID string, id int, text, editorID string, at time.Time) (Message, error)
+	// Delete помечает сообщение удаленным, стирает текст и историю правок.
+	// ErrNotFound — нет сообщения или оно уже удалено.
+	Delete(ctx context.Context, chatID string, id int, at time.Time) (Message, error)
+	// Revisions — история правок сообщения, от старых к новым
+	Revisions(ctx context.Context, chatID string, id int) ([]MessageRevision, error)
+}
+
+type RoomStore interface {
+	// Create создает комнату и делает room.CreatedBy ее владельцем
+	Create(ctx context.Context, room Room) (Room, error)
+	Get(ctx context.Context, roomID string) (Room, error)
+	// ListByMember возвращает комнаты, в которых пользователь состоит
+	ListByMember(ctx context.Context, userID string) ([]Room, error)
+	// ListJoinable возвращает публичные неархивные комнаты, в которых пользователь еще не состоит
+	ListJoinable(ctx context.Context, userID string, limit, offset int) ([]Room, error)
+	// Update возвращает ErrNotFound, если комнаты нет
+	Update(ctx context.Context, roomID string, upd RoomUpdate) (Room, error)
+	// Delete удаляет комнату вместе с участниками, приглашениями и санкциями
+	Delete(ctx context.Context, roomID string) error
+	// AppendMessage добавляет сообщение в историю комнаты и возвращает всю историю
+	AppendMessage(ctx context.Context, roomID string, msg RoomMessage) ([]RoomMessage, error)
+}
+
+type InviteStore interface {
+	Create(ctx context.Context, inv RoomInvite) error
+	Get(ctx context.Context, code string) (RoomInvite, error)
+	ListByRoom(ctx context.Context, roomID string) ([]RoomInvite, error)
+	// Redeem атомарно засчитывает использование. ErrNotFound — приглашения нет,
+	// оно отозвано, истекло или исчерпано.
+	Redeem(ctx context.Context, code string, now time.Time) (RoomInvite, error)
+	// Revoke возвращает ErrNotFound, если приглашения нет
+	Revoke(ctx context.Context, code string) error
+}
+
+// MemberStore — участники и их роли. Для ScopeChat это участники чата,
+// для ScopeRoom — участники видеокомнаты.
+type MemberStore interface {
+	// Get возвращает ErrNotFound, если пользователь не участник
+	Get(ctx context.Context, scope Scope, resourceID, userID string) (Member, error)
+	List(ctx context.Context, scope Scope, resourceID string) ([]Member, error)
+	// Add ничего не делает, если пользователь уже участник
+	Add(ctx context.Context, scope Scope, resourceID, userID string, role Role) error
+	// SetRole возвращает ErrNotFound, если пользователь не участник
+	SetRole(ctx context.Context, scope Scope, resourceID, userID string, role Role) error
+	// Remove возвращает ErrNotFound, если пользователь не участник
+	Remove(ctx context.Context, scope Scope, resourceID, userID string) error
+}
+
+type SanctionStore interface {
+	// Put создает санкцию или заменяет действующую того же вида
+	Put(ctx context.Context, s Sanction) error
+	// Lift снимает санкцию; ErrNotFound, если ее нет
+	Lift(ctx context.Context, scope Scope, resourceID, userID string, kind SanctionKind) error
+	// Active возвращает санкции пользователя, действующие на момент now
+	Active(ctx context.Context, scope Scope, resourceID, userID string, now time.Time) ([]Sanction, error)
+	List(ctx context.Context, scope Scope, resourceID string, now time.Time) ([]Sanction, error)
+}
+
+type TokenStore interface {
+	CreateRefresh(ctx context.Context, t RefreshToken) error
+	GetRefresh(ctx context.Context, hash string) (RefreshToken, error)
+	// RevokeRefresh атомарно отзывает токен; false — токен уже был отозван раньше
+	RevokeRefresh(ctx context.Context, hash string) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllRefresh(ctx context.Context, userID string) error
+
+	RevokeAccess(ctx context.Context, jti, userID string, expiresAt time.Time) error
+	IsAccessRevoked(ctx context.Context, jti string) (bool, error)
+
+	// DeleteExpired удаляет записи, срок действия которых истек
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// Store — набор всех хранилищ, которые передаются в обработчики и хабы
+type Store struct {
+	Users       UserStore
+	Friendships FriendshipStore
+	Chats       ChatStore
+	Messages    MessageStore
+	Rooms       RoomStore
+	Invites     InviteStore
+	Members     MemberStore
+	Sanctions   SanctionStore
+	Tokens      TokenStore
+}